@@ -0,0 +1,334 @@
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+
+    "minibank-go/config"
+    "minibank-go/database"
+    "minibank-go/handlers"
+    "minibank-go/kms"
+    "minibank-go/models"
+    "minibank-go/utils"
+
+    "golang.org/x/term"
+    "gorm.io/gorm"
+    "gorm.io/gorm/logger"
+)
+
+// runCommand handles the command line subcommands:
+//
+//     minibank migrate up|down [steps]|status
+//     minibank admin create -email EMAIL -phone PHONE -first-name NAME -last-name NAME [-role ROLE]
+//     minibank keys list|generate [-alg RS256|EdDSA]|activate KID|rotate [-alg RS256|EdDSA]|retire KID
+//     minibank kms list|rotate|reencrypt
+func runCommand(cfg *config.Config, args []string) {
+    switch args[0] {
+    case "migrate":
+        runMigrate(cfg, args[1:])
+    case "admin":
+        runAdmin(cfg, args[1:])
+    case "keys":
+        runKeys(cfg, args[1:])
+    case "kms":
+        runKMS(cfg, args[1:])
+    default:
+        log.Fatalf("Unknown command %q", args[0])
+    }
+}
+
+func runMigrate(cfg *config.Config, args []string) {
+    if len(args) == 0 {
+        log.Fatal("Usage: minibank migrate up|down [steps]|status")
+    }
+
+    db, err := database.Open(cfg.DatabaseURL, cfg.DatabasePool)
+    if err != nil {
+        log.Fatal("Failed to open database:", err)
+    }
+    db = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Warn)})
+
+    switch args[0] {
+    case "up":
+        if err := database.MigrateUp(db); err != nil {
+            log.Fatal(err)
+        }
+        log.Printf("Schema is at version %d", database.LatestVersion())
+    case "down":
+        steps := 1
+        if len(args) > 1 {
+            steps, err = strconv.Atoi(args[1])
+            if err != nil || steps < 1 {
+                log.Fatalf("Invalid number of steps %q", args[1])
+            }
+        }
+        if err := database.MigrateDown(db, steps); err != nil {
+            log.Fatal(err)
+        }
+    case "status":
+        states, err := database.MigrationStatus(db)
+        if err != nil {
+            log.Fatal(err)
+        }
+        w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+        fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+        for _, state := range states {
+            appliedAt := "pending"
+            if state.AppliedAt != nil {
+                appliedAt = state.AppliedAt.Format("2006-01-02 15:04:05")
+            }
+            fmt.Fprintf(w, "%04d\t%s\t%s\n", state.Version, state.Name, appliedAt)
+        }
+        w.Flush()
+    default:
+        log.Fatalf("Unknown migrate command %q", args[0])
+    }
+}
+
+// runAdmin creates staff users. This is the only way to create the first
+// superadmin; after that, superadmins can invite further staff.
+func runAdmin(cfg *config.Config, args []string) {
+    if len(args) == 0 || args[0] != "create" {
+        log.Fatal("Usage: minibank admin create -email EMAIL -phone PHONE -first-name NAME -last-name NAME [-role ROLE]")
+    }
+
+    flags := flag.NewFlagSet("admin create", flag.ExitOnError)
+    email := flags.String("email", "", "email address to log in with")
+    phone := flags.String("phone", "", "phone number")
+    firstName := flags.String("first-name", "", "first name")
+    lastName := flags.String("last-name", "", "last name")
+    role := flags.String("role", models.RoleSuperadmin, "staff role to grant")
+    flags.Parse(args[1:])
+
+    if !models.IsStaffRole(*role) {
+        log.Fatalf("Role %q is not a staff role", *role)
+    }
+
+    password, err := readPassword()
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    req := models.RegisterRequest{
+        Email:     *email,
+        Phone:     *phone,
+        Password:  password,
+        FirstName: *firstName,
+        LastName:  *lastName,
+    }
+    if err := utils.ValidateStruct(req); err != nil {
+        log.Fatalf("Invalid admin details: %v", utils.FormatValidationError(err))
+    }
+
+    db, err := database.Initialize(cfg.DatabaseURL, cfg.DatabasePool)
+    if err != nil {
+        log.Fatal("Failed to initialize database:", err)
+    }
+    db = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Warn)})
+
+    var existing int64
+    if err := db.Model(&models.User{}).Where("email = ? OR phone = ?", req.Email, req.Phone).Count(&existing).Error; err != nil {
+        log.Fatal("Failed to check for an existing user:", err)
+    }
+    if existing > 0 {
+        log.Fatalf("A user with email %s or phone %s already exists", req.Email, req.Phone)
+    }
+
+    hashedPassword, err := utils.HashPassword(req.Password)
+    if err != nil {
+        log.Fatal("Failed to hash password:", err)
+    }
+
+    user := models.User{
+        Email:     req.Email,
+        Phone:     req.Phone,
+        Password:  hashedPassword,
+        FirstName: req.FirstName,
+        LastName:  req.LastName,
+        IsActive:  true,
+        Role:      *role,
+        KYCStatus: "pending",
+    }
+    err = db.Transaction(func(tx *gorm.DB) error {
+        return handlers.CreateUser(tx, &user)
+    })
+    if err != nil {
+        log.Fatal("Failed to create user:", err)
+    }
+
+    db.Create(&models.AuditLog{
+        UserID:    &user.ID,
+        Action:    "CREATE",
+        Resource:  "USER",
+        Details:   "Staff user created as " + user.Role + " from the command line",
+        UserAgent: "cli",
+    })
+
+    log.Printf("Created %s %s (user %d)", user.Role, user.Email, user.ID)
+}
+
+// runKeys manages the JWT signing keys in JWT_KEYS_DIR. Running servers
+// reload the directory every minute.
+func runKeys(cfg *config.Config, args []string) {
+    const usage = "Usage: minibank keys list|generate [-alg RS256|EdDSA]|activate KID|rotate [-alg RS256|EdDSA]|retire KID"
+    if len(args) == 0 {
+        log.Fatal(usage)
+    }
+    if cfg.JWTKeysDir == "" {
+        log.Fatal("JWT_KEYS_DIR is not set")
+    }
+    dir := cfg.JWTKeysDir
+
+    switch args[0] {
+    case "list":
+        keys, err := utils.ListJWTKeys(dir)
+        if err != nil {
+            log.Fatal(err)
+        }
+        w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+        fmt.Fprintln(w, "KID\tALG\tSTATUS\tCREATED AT")
+        for _, key := range keys {
+            status := "verify"
+            switch {
+            case key.Active:
+                status = "active"
+            case key.CanSign():
+                status = "standby"
+            }
+            fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key.ID, key.Algorithm, status, key.CreatedAt.Format("2006-01-02 15:04:05"))
+        }
+        w.Flush()
+    case "generate", "rotate":
+        flags := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+        algorithm := flags.String("alg", utils.AlgorithmEdDSA, "signing algorithm, RS256 or EdDSA")
+        flags.Parse(args[1:])
+
+        key, err := utils.GenerateJWTKey(dir, *algorithm)
+        if err != nil {
+            log.Fatal(err)
+        }
+        if args[0] == "generate" {
+            log.Printf("Generated %s key %s; it verifies tokens now and signs them once activated", key.Algorithm, key.ID)
+            return
+        }
+        if err := utils.ActivateJWTKey(dir, key.ID); err != nil {
+            log.Fatal(err)
+        }
+        log.Printf("Generated %s key %s and made it the signing key; retire older keys once tokens they signed have expired", key.Algorithm, key.ID)
+    case "activate":
+        if len(args) != 2 {
+            log.Fatal(usage)
+        }
+        if err := utils.ActivateJWTKey(dir, args[1]); err != nil {
+            log.Fatal(err)
+        }
+        log.Printf("Key %s now signs new tokens", args[1])
+    case "retire":
+        if len(args) != 2 {
+            log.Fatal(usage)
+        }
+        if err := utils.RetireJWTKey(dir, args[1]); err != nil {
+            log.Fatal(err)
+        }
+        log.Printf("Retired key %s; tokens it signed are no longer accepted", args[1])
+    default:
+        log.Fatalf("Unknown keys command %q", args[0])
+    }
+}
+
+// runKMS manages the master keys of the file KMS in KMS_DIR and moves
+// encrypted KYC records onto the current one
+func runKMS(cfg *config.Config, args []string) {
+    const usage = "Usage: minibank kms list|rotate|reencrypt"
+    if len(args) != 1 {
+        log.Fatal(usage)
+    }
+
+    switch args[0] {
+    case "list", "rotate":
+        if cfg.KMS.Provider != "" && cfg.KMS.Provider != "file" {
+            log.Fatalf("Master keys of the %s KMS provider are managed by the provider", cfg.KMS.Provider)
+        }
+        keys, err := kms.NewFileKMS(cfg.KMS.Dir)
+        if err != nil {
+            log.Fatal(err)
+        }
+        if args[0] == "rotate" {
+            id, err := keys.Rotate()
+            if err != nil {
+                log.Fatal(err)
+            }
+            log.Printf("Master key %s now encrypts new data; running servers move existing KYC records onto it, or run minibank kms reencrypt", id)
+            return
+        }
+
+        ids, err := keys.KeyIDs()
+        if err != nil {
+            log.Fatal(err)
+        }
+        current, err := keys.CurrentKeyID()
+        if err != nil {
+            log.Fatal(err)
+        }
+        w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+        fmt.Fprintln(w, "KEY ID\tSTATUS")
+        for _, id := range ids {
+            status := "decrypt"
+            if id == current {
+                status = "active"
+            }
+            fmt.Fprintf(w, "%s\t%s\n", id, status)
+        }
+        w.Flush()
+    case "reencrypt":
+        db, err := database.Open(cfg.DatabaseURL, cfg.DatabasePool)
+        if err != nil {
+            log.Fatal("Failed to open database:", err)
+        }
+        db = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Warn)})
+
+        rewritten, err := handlers.ReencryptKYC(db)
+        if err != nil {
+            log.Fatal(err)
+        }
+        log.Printf("Re-encrypted %d KYC records under the current master key", rewritten)
+    default:
+        log.Fatalf("Unknown kms command %q", args[0])
+    }
+}
+
+// readPassword asks for the new user's password without echoing it. When
+// stdin is not a terminal, the first line of stdin is used instead.
+func readPassword() (string, error) {
+    fd := int(os.Stdin.Fd())
+    if !term.IsTerminal(fd) {
+        line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+        if err != nil && line == "" {
+            return "", fmt.Errorf("failed to read password from stdin: %v", err)
+        }
+        return strings.TrimRight(line, "\r\n"), nil
+    }
+
+    fmt.Fprint(os.Stderr, "Password: ")
+    password, err := term.ReadPassword(fd)
+    fmt.Fprintln(os.Stderr)
+    if err != nil {
+        return "", fmt.Errorf("failed to read password: %v", err)
+    }
+    fmt.Fprint(os.Stderr, "Confirm password: ")
+    confirm, err := term.ReadPassword(fd)
+    fmt.Fprintln(os.Stderr)
+    if err != nil {
+        return "", fmt.Errorf("failed to read password: %v", err)
+    }
+    if string(password) != string(confirm) {
+        return "", fmt.Errorf("passwords do not match")
+    }
+    return string(password), nil
+}
@@ -3,51 +3,211 @@ package config
 import (
     "log"
     "os"
+    "strconv"
+    "time"
+
+    "minibank-go/money"
 )
 
+// defaultBlindIndexKey lets development run without setting BLIND_INDEX_KEY.
+// It is public, so it is refused in any other environment.
+const defaultBlindIndexKey = "MiniBankGo2025BlindIndexKeyChangeMe"
+
 type TransactionLimits struct {
-    DailyDepositLimit  float64
-    DailyWithdrawLimit float64
-    DailyTransferLimit float64
+    DailyDepositLimit  money.Amount
+    DailyWithdrawLimit money.Amount
+    DailyTransferLimit money.Amount
 }
 
 type AMLRules struct {
-    MonthlyThreshold         float64
+    MonthlyThreshold         money.Amount
     DailyTransactionLimit   int
 }
 
+// SchedulerConfig controls how scheduled transfers are executed
+type SchedulerConfig struct {
+    Interval   time.Duration // how often due transfers are looked for
+    RetryLimit int           // retries of a failed occurrence before it is given up
+    RetryDelay time.Duration // wait before the first retry, doubled for each one after
+}
+
+// BeneficiaryRules limit transfers to payees outside the user's own accounts
+type BeneficiaryRules struct {
+    CoolingOff      time.Duration // how long a newly saved beneficiary stays restricted
+    CoolingOffLimit money.Amount  // total that can be sent to it in that time
+    Required        bool          // only saved beneficiaries can be paid
+}
+
+// TwoFactorConfig controls TOTP two-factor authentication
+type TwoFactorConfig struct {
+    ChallengeTTL     time.Duration // how long a login has to present its second factor
+    MaxAttempts      int           // wrong codes before a login challenge is spent
+    UserLimit        int           // second-factor attempts per minute for one user
+    RequireForAdmins bool          // admin endpoints refuse tokens from logins without 2FA
+}
+
+// LoginProtection guards /api/login against password guessing
+type LoginProtection struct {
+    MaxAttempts     int           // consecutive failures before the email is locked out
+    LockoutDuration time.Duration // how long a lockout lasts
+    DelayBase       time.Duration // wait enforced after the first failure, doubled for each further one
+    DelayMax        time.Duration // longest wait between attempts short of a lockout
+    IPLimit         int           // login attempts per minute from one IP address
+    EmailLimit      int           // login attempts per minute for one email
+}
+
+// StepUpConfig controls the transaction PIN or TOTP code that large
+// withdrawals and transfers need on top of the access token
+type StepUpConfig struct {
+    Threshold       money.Amount  // payments above this need step-up
+    MaxAttempts     int           // failed PINs and codes before step-up is locked
+    LockoutDuration time.Duration // how long a step-up lockout lasts
+}
+
+// KMSConfig selects where the master keys that wrap data keys are kept
+type KMSConfig struct {
+    Provider          string        // file
+    Dir               string        // where the file provider keeps master keys
+    ReencryptInterval time.Duration // how often records under older keys are re-encrypted
+}
+
+// NotificationConfig selects how emails to users are delivered
+type NotificationConfig struct {
+    Provider     string // file or smtp
+    File         string // where the file provider writes; empty means stdout
+    From         string
+    SMTPHost     string
+    SMTPPort     int
+    SMTPUsername string
+    SMTPPassword string
+    AppURL       string // base URL of the web app, for links in emails
+}
+
+// DatabasePool holds the connection pool settings. Zero means the
+// database/sql default.
+type DatabasePool struct {
+    MaxOpenConns    int
+    MaxIdleConns    int
+    ConnMaxLifetime time.Duration
+    ConnMaxIdleTime time.Duration
+}
+
 type Config struct {
     DatabaseURL        string
+    DatabasePool       DatabasePool
     JWTSecret          string
-    EncryptionKey      string
-    AdminCode          string
+    JWTKeysDir         string // PEM signing keys; when empty tokens are signed with JWTSecret
+    AccessTokenTTL     time.Duration
+    RefreshTokenTTL    time.Duration
+    TwoFactor          TwoFactorConfig
+    LoginProtection    LoginProtection
+    StepUp             StepUpConfig
+    InvitationTTL      time.Duration
+    PasswordResetTTL   time.Duration
+    VerificationTTL    time.Duration // how long an email verification link works
+    Notifications      NotificationConfig
+    ApprovalTTL        time.Duration // how long a maker-checker request waits for a decision
+    APIKeyTTL          time.Duration // lifetime of API keys created without an expiry
+    APIKeyRateLimit    int           // requests per minute for API keys created without a limit
+    EncryptionKey      string        // reads data encrypted before envelope encryption
+    KMS                KMSConfig
+    BlindIndexKey      string        // keys the hashes that find identity documents without decrypting them
     Port               string
     Environment        string
     TransactionLimits  TransactionLimits
     AMLRules           AMLRules
-    MaxTransferAmount  float64
-    DailyTransferLimit float64
+    MaxTransferAmount  money.Amount
+    DailyTransferLimit money.Amount
+    PayeeLookupLimit   int // payee lookups per minute for one user
+    IdempotencyWindow  time.Duration
+    IdempotencyLease   time.Duration // how long an unfinished request holds its key
+    HoldExpiry         time.Duration
+    Scheduler          SchedulerConfig
+    Beneficiaries      BeneficiaryRules
 }
 
 func Load() *Config {
     return &Config{
         DatabaseURL:        getEnv("DATABASE_URL", "minibank.db"),
+        DatabasePool: DatabasePool{
+            MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+            MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 5),
+            ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+            ConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+        },
         JWTSecret:          getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+        JWTKeysDir:         os.Getenv("JWT_KEYS_DIR"),
+        AccessTokenTTL:     getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+        RefreshTokenTTL:    getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+        TwoFactor: TwoFactorConfig{
+            ChallengeTTL:     getEnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+            MaxAttempts:      getEnvInt("MFA_MAX_ATTEMPTS", 3),
+            UserLimit:        getEnvInt("MFA_USER_LIMIT", 10),
+            RequireForAdmins: getEnvBool("REQUIRE_ADMIN_2FA", false),
+        },
+        LoginProtection: LoginProtection{
+            MaxAttempts:     getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+            LockoutDuration: getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+            DelayBase:       getEnvDuration("LOGIN_DELAY_BASE", time.Second),
+            DelayMax:        getEnvDuration("LOGIN_DELAY_MAX", 30*time.Second),
+            IPLimit:         getEnvInt("LOGIN_IP_LIMIT", 20),
+            EmailLimit:      getEnvInt("LOGIN_EMAIL_LIMIT", 10),
+        },
+        StepUp: StepUpConfig{
+            Threshold:       getEnvAmount("STEP_UP_THRESHOLD", money.FromMajor(1000)),
+            MaxAttempts:     getEnvInt("STEP_UP_MAX_ATTEMPTS", 5),
+            LockoutDuration: getEnvDuration("STEP_UP_LOCKOUT_DURATION", 30*time.Minute),
+        },
+        InvitationTTL:      getEnvDuration("INVITATION_TTL", 72*time.Hour),
+        ApprovalTTL:        getEnvDuration("APPROVAL_TTL", 72*time.Hour),
+        APIKeyTTL:          getEnvDuration("API_KEY_TTL", 90*24*time.Hour),
+        APIKeyRateLimit:    getEnvInt("API_KEY_RATE_LIMIT", 60),
+        PasswordResetTTL:   getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+        VerificationTTL:    getEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+        Notifications: NotificationConfig{
+            Provider:     getEnv("NOTIFY_PROVIDER", "file"),
+            File:         os.Getenv("NOTIFY_FILE"),
+            From:         getEnv("NOTIFY_FROM", "MiniBank <no-reply@minibank.local>"),
+            SMTPHost:     os.Getenv("SMTP_HOST"),
+            SMTPPort:     getEnvInt("SMTP_PORT", 587),
+            SMTPUsername: os.Getenv("SMTP_USERNAME"),
+            SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+            AppURL:       getEnv("APP_BASE_URL", "http://localhost:3000"),
+        },
         EncryptionKey:      getEnv("ENCRYPTION_KEY", "MiniBankGo2025SecureKey123456789"),
-        AdminCode:          getEnv("ADMIN_CODE", "MINIBANK_ADMIN_2025"),
+        KMS: KMSConfig{
+            Provider:          getEnv("KMS_PROVIDER", "file"),
+            Dir:               getEnv("KMS_DIR", "master-keys"),
+            ReencryptInterval: getEnvDuration("REENCRYPT_INTERVAL", time.Hour),
+        },
+        BlindIndexKey:      getEnv("BLIND_INDEX_KEY", defaultBlindIndexKey),
         Port:               getEnv("PORT", "8080"),
         Environment:        getEnv("ENVIRONMENT", "development"),
         TransactionLimits: TransactionLimits{
-            DailyDepositLimit:  10000.0,
-            DailyWithdrawLimit: 5000.0,
-            DailyTransferLimit: 50000.0,
+            DailyDepositLimit:  money.FromMajor(10000),
+            DailyWithdrawLimit: money.FromMajor(5000),
+            DailyTransferLimit: money.FromMajor(50000),
         },
         AMLRules: AMLRules{
-            MonthlyThreshold:        100000.0,
+            MonthlyThreshold:        money.FromMajor(100000),
             DailyTransactionLimit:   10,
         },
-        MaxTransferAmount:  10000.0,
-        DailyTransferLimit: 50000.0,
+        MaxTransferAmount:  money.FromMajor(10000),
+        DailyTransferLimit: money.FromMajor(50000),
+        PayeeLookupLimit:   getEnvInt("PAYEE_LOOKUP_LIMIT", 10),
+        IdempotencyWindow:  getEnvDuration("IDEMPOTENCY_WINDOW", 24*time.Hour),
+        IdempotencyLease:   getEnvDuration("IDEMPOTENCY_LEASE", time.Minute),
+        HoldExpiry:         getEnvDuration("HOLD_EXPIRY", 7*24*time.Hour),
+        Scheduler: SchedulerConfig{
+            Interval:   getEnvDuration("SCHEDULER_INTERVAL", time.Minute),
+            RetryLimit: getEnvInt("SCHEDULED_RETRY_LIMIT", 3),
+            RetryDelay: getEnvDuration("SCHEDULED_RETRY_DELAY", time.Hour),
+        },
+        Beneficiaries: BeneficiaryRules{
+            CoolingOff:      getEnvDuration("BENEFICIARY_COOLING_OFF", 24*time.Hour),
+            CoolingOffLimit: getEnvAmount("BENEFICIARY_COOLING_OFF_LIMIT", money.FromMajor(10000)),
+            Required:        getEnvBool("REQUIRE_BENEFICIARY", false),
+        },
     }
 }
 
@@ -58,14 +218,69 @@ func getEnv(key, defaultValue string) string {
     return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil || n < 0 {
+        log.Printf("WARNING: invalid %s %q, using %d", key, value, defaultValue)
+        return defaultValue
+    }
+    return n
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+    d, err := time.ParseDuration(value)
+    if err != nil || d <= 0 {
+        log.Printf("WARNING: invalid %s %q, using %s", key, value, defaultValue)
+        return defaultValue
+    }
+    return d
+}
+
 func ValidateConfig(cfg *Config) {
     if len(cfg.EncryptionKey) != 32 {
         log.Fatalf("ENCRYPTION_KEY must be exactly 32 characters, got %d", len(cfg.EncryptionKey))
     }
-    if len(cfg.JWTSecret) < 32 {
+    if len(cfg.BlindIndexKey) < 32 {
+        log.Fatalf("BLIND_INDEX_KEY must be at least 32 characters, got %d", len(cfg.BlindIndexKey))
+    }
+    if cfg.Environment != "development" && cfg.BlindIndexKey == defaultBlindIndexKey {
+        log.Fatalf("BLIND_INDEX_KEY must be set to a secret of your own when ENVIRONMENT is %q", cfg.Environment)
+    }
+    if cfg.JWTKeysDir == "" && len(cfg.JWTSecret) < 32 {
         log.Printf("WARNING: JWT_SECRET should be at least 32 characters for security")
     }
-    if cfg.Environment == "production" && cfg.AdminCode == "MINIBANK_ADMIN_2025" {
-        log.Printf("WARNING: Change ADMIN_CODE in production environment")
+}
+
+func getEnvAmount(key string, defaultValue money.Amount) money.Amount {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+    amount, err := money.Parse(value)
+    if err != nil || amount.IsNegative() {
+        log.Printf("WARNING: invalid %s %q, using %s", key, value, defaultValue)
+        return defaultValue
     }
-}
\ No newline at end of file
+    return amount
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+    b, err := strconv.ParseBool(value)
+    if err != nil {
+        log.Printf("WARNING: invalid %s %q, using %t", key, value, defaultValue)
+        return defaultValue
+    }
+    return b
+}
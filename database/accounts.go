@@ -0,0 +1,203 @@
+package database
+
+import (
+    "fmt"
+    "log"
+    "time"
+
+    "minibank-go/money"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// legacyUser is the slice of the pre-account users table that carried a balance
+type legacyUser struct {
+    ID        uint
+    Balance   money.Amount
+    Currency  money.Currency
+    CreatedAt time.Time
+}
+
+// These stand in for the legacy tables while they are reshaped; the migrator
+// needs a model rather than a table name to alter tables on sqlite and to
+// inspect them on mysql.
+type legacyUsersTable struct{}
+
+func (legacyUsersTable) TableName() string { return "users" }
+
+type legacyTransactionsTable struct {
+    AccountID     *uint
+    ToAccountID   *uint
+    FromAccountID *uint
+}
+
+func (legacyTransactionsTable) TableName() string { return "transactions" }
+
+type legacyLedgerAccountsTable struct {
+    AccountID *uint
+}
+
+func (legacyLedgerAccountsTable) TableName() string { return "ledger_accounts" }
+
+// migrateBalancesToAccounts moves balances from users onto a savings account
+// per user and repoints transactions and ledger accounts at those accounts.
+// It only runs against schemas where users still has a balance column.
+func migrateBalancesToAccounts(db *gorm.DB) error {
+    m := db.Migrator()
+    if !m.HasTable(&legacyUsersTable{}) || !m.HasColumn(&legacyUsersTable{}, "balance") {
+        return nil
+    }
+
+    return db.Transaction(func(tx *gorm.DB) error {
+        m := tx.Migrator()
+        if err := m.AutoMigrate(&v1Account{}); err != nil {
+            return fmt.Errorf("failed to create accounts table: %w", err)
+        }
+
+        columns := "id, balance, created_at"
+        if m.HasColumn(&legacyUsersTable{}, "currency") {
+            columns += ", currency"
+        }
+        var users []legacyUser
+        if err := tx.Table("users").Select(columns).Scan(&users).Error; err != nil {
+            return fmt.Errorf("failed to read legacy balances: %w", err)
+        }
+
+        for _, u := range users {
+            number, err := utils.GenerateAccountNumber()
+            if err != nil {
+                return err
+            }
+            currency := u.Currency
+            if currency == "" {
+                currency = money.DefaultCurrency
+            }
+            account := v1Account{
+                UserID:        u.ID,
+                AccountNumber: number,
+                Type:          "savings",
+                Currency:      currency,
+                Balance:       u.Balance,
+                Status:        "active",
+                OpenedAt:      u.CreatedAt,
+            }
+            if err := tx.Create(&account).Error; err != nil {
+                return fmt.Errorf("failed to open account for user %d: %w", u.ID, err)
+            }
+        }
+
+        if m.HasTable(&legacyTransactionsTable{}) && m.HasColumn(&legacyTransactionsTable{}, "user_id") {
+            for _, c := range []struct{ from, to string }{
+                {"user_id", "account_id"},
+                {"to_user_id", "to_account_id"},
+                {"from_user_id", "from_account_id"},
+            } {
+                if !m.HasColumn(&legacyTransactionsTable{}, c.to) {
+                    if err := m.AddColumn(&legacyTransactionsTable{}, c.to); err != nil {
+                        return fmt.Errorf("failed to add transactions.%s: %w", c.to, err)
+                    }
+                }
+                backfill := fmt.Sprintf("UPDATE transactions SET %s = (SELECT accounts.id FROM accounts WHERE accounts.user_id = transactions.%s) WHERE %s IS NOT NULL",
+                    c.to, c.from, c.from)
+                if err := tx.Exec(backfill).Error; err != nil {
+                    return fmt.Errorf("failed to backfill transactions.%s: %w", c.to, err)
+                }
+            }
+
+            for _, fk := range []string{"fk_transactions_user", "fk_transactions_to_user", "fk_transactions_from_user"} {
+                if m.HasConstraint(&legacyTransactionsTable{}, fk) {
+                    if err := m.DropConstraint(&legacyTransactionsTable{}, fk); err != nil {
+                        return fmt.Errorf("failed to drop %s: %w", fk, err)
+                    }
+                }
+            }
+            for _, column := range []string{"user_id", "to_user_id", "from_user_id"} {
+                if err := m.DropColumn(&legacyTransactionsTable{}, column); err != nil {
+                    return fmt.Errorf("failed to drop transactions.%s: %w", column, err)
+                }
+            }
+        }
+
+        if m.HasTable(&legacyLedgerAccountsTable{}) && m.HasColumn(&legacyLedgerAccountsTable{}, "user_id") {
+            if !m.HasColumn(&legacyLedgerAccountsTable{}, "account_id") {
+                if err := m.AddColumn(&legacyLedgerAccountsTable{}, "account_id"); err != nil {
+                    return fmt.Errorf("failed to add ledger_accounts.account_id: %w", err)
+                }
+            }
+
+            var ledgerAccounts []struct {
+                ID     uint
+                UserID uint
+            }
+            if err := tx.Table("ledger_accounts").Select("id, user_id").Where("user_id IS NOT NULL").Scan(&ledgerAccounts).Error; err != nil {
+                return fmt.Errorf("failed to read customer ledger accounts: %w", err)
+            }
+            for _, la := range ledgerAccounts {
+                var account v1Account
+                if err := tx.Where("user_id = ?", la.UserID).First(&account).Error; err != nil {
+                    return fmt.Errorf("failed to find account for ledger account %d: %w", la.ID, err)
+                }
+                if err := tx.Table("ledger_accounts").Where("id = ?", la.ID).Updates(map[string]interface{}{
+                    "account_id": account.ID,
+                    "code":       fmt.Sprintf("account:%d", account.ID),
+                    "name":       fmt.Sprintf("Customer account %s", account.AccountNumber),
+                }).Error; err != nil {
+                    return fmt.Errorf("failed to repoint ledger account %d: %w", la.ID, err)
+                }
+            }
+
+            if m.HasIndex(&legacyLedgerAccountsTable{}, "idx_ledger_accounts_user_id") {
+                if err := m.DropIndex(&legacyLedgerAccountsTable{}, "idx_ledger_accounts_user_id"); err != nil {
+                    return fmt.Errorf("failed to drop ledger_accounts user index: %w", err)
+                }
+            }
+            if err := m.DropColumn(&legacyLedgerAccountsTable{}, "user_id"); err != nil {
+                return fmt.Errorf("failed to drop ledger_accounts.user_id: %w", err)
+            }
+        }
+
+        for _, column := range []string{"balance", "currency"} {
+            if m.HasColumn(&legacyUsersTable{}, column) {
+                if err := m.DropColumn(&legacyUsersTable{}, column); err != nil {
+                    return fmt.Errorf("failed to drop users.%s: %w", column, err)
+                }
+            }
+        }
+
+        log.Printf("Moved %d user balances onto accounts", len(users))
+        return nil
+    })
+}
+
+// reissueAccountNumbers replaces account numbers that predate check digits
+func reissueAccountNumbers(db *gorm.DB) error {
+    var accounts []v1Account
+    if err := db.Unscoped().Find(&accounts).Error; err != nil {
+        return fmt.Errorf("failed to load accounts: %w", err)
+    }
+
+    for _, account := range accounts {
+        if utils.ValidateAccountNumber(account.AccountNumber) {
+            continue
+        }
+
+        number, err := utils.GenerateAccountNumber()
+        if err != nil {
+            return err
+        }
+        err = db.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Unscoped().Model(&v1Account{}).Where("id = ?", account.ID).
+                Update("account_number", number).Error; err != nil {
+                return err
+            }
+            return tx.Model(&v1LedgerAccount{}).Where("account_id = ?", account.ID).
+                Update("name", fmt.Sprintf("Customer account %s", number)).Error
+        })
+        if err != nil {
+            return fmt.Errorf("failed to reissue number for account %d: %w", account.ID, err)
+        }
+        log.Printf("Reissued account number %s as %s", account.AccountNumber, number)
+    }
+    return nil
+}
@@ -1,31 +1,50 @@
 package database
 
 import (
-    "minibank-go/models"
+    "minibank-go/config"
 
-    "gorm.io/driver/sqlite"
     "gorm.io/gorm"
     "gorm.io/gorm/logger"
 )
 
-func Initialize(databaseURL string) (*gorm.DB, error) {
-    db, err := gorm.Open(sqlite.Open(databaseURL), &gorm.Config{
+// Open connects to the database named by DATABASE_URL without touching the
+// schema
+func Open(databaseURL string, pool config.DatabasePool) (*gorm.DB, error) {
+    dialector, err := dialectorFor(databaseURL)
+    if err != nil {
+        return nil, err
+    }
+
+    db, err := gorm.Open(dialector, &gorm.Config{
         Logger: logger.Default.LogMode(logger.Info),
     })
     if err != nil {
         return nil, err
     }
 
-    // Auto-migrate models
-    err = db.AutoMigrate(
-        &models.User{},
-        &models.KYC{},
-        &models.Transaction{},
-        &models.AuditLog{},
-    )
+    sqlDB, err := db.DB()
     if err != nil {
         return nil, err
     }
+    sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+    sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+    sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+    sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+    return db, nil
+}
+
+// Initialize opens the database for serving. It refuses to continue if the
+// schema is behind this binary; run `minibank migrate up` first.
+func Initialize(databaseURL string, pool config.DatabasePool) (*gorm.DB, error) {
+    db, err := Open(databaseURL, pool)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := CheckSchema(db); err != nil {
+        return nil, err
+    }
 
     return db, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "time"
+
+    "gorm.io/gorm"
+)
+
+// Migration is one versioned schema change: the statements of its up and
+// down scripts for one dialect, plus any data steps written in Go. A
+// migration runs inside a transaction together with the schema_migrations
+// bookkeeping, so a failed migration leaves no trace (except on MySQL, where
+// DDL commits implicitly).
+type Migration struct {
+    Version uint
+    Name    string
+    Up      []string
+    Down    []string
+
+    data dataMigration
+}
+
+// dataMigration holds the Go steps of a migration. They must only use
+// snapshot structs defined next to them, never the types in models, so they
+// keep describing the schema as it was when they were written.
+type dataMigration struct {
+    BeforeUp   func(tx *gorm.DB) error
+    AfterUp    func(tx *gorm.DB) error
+    BeforeDown func(tx *gorm.DB) error
+}
+
+func (m Migration) up(tx *gorm.DB) error {
+    if m.data.BeforeUp != nil {
+        if err := m.data.BeforeUp(tx); err != nil {
+            return err
+        }
+    }
+    if err := execStatements(tx, m.Up); err != nil {
+        return err
+    }
+    if m.data.AfterUp != nil {
+        return m.data.AfterUp(tx)
+    }
+    return nil
+}
+
+func (m Migration) down(tx *gorm.DB) error {
+    if m.data.BeforeDown != nil {
+        if err := m.data.BeforeDown(tx); err != nil {
+            return err
+        }
+    }
+    return execStatements(tx, m.Down)
+}
+
+func execStatements(tx *gorm.DB, statements []string) error {
+    for _, statement := range statements {
+        if err := tx.Exec(statement).Error; err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// schemaMigration records an applied migration
+type schemaMigration struct {
+    Version   uint      `gorm:"primaryKey;autoIncrement:false"`
+    Name      string    `gorm:"size:255;not null"`
+    AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+// MigrationState is the status of one known migration
+type MigrationState struct {
+    Version   uint
+    Name      string
+    AppliedAt *time.Time
+}
+
+var ErrSchemaBehind = errors.New("database schema is behind this binary")
+
+// LatestVersion is the schema version this binary expects
+func LatestVersion() uint {
+    set := migrationSets[dialects[0]]
+    return set[len(set)-1].Version
+}
+
+func appliedMigrations(db *gorm.DB) (map[uint]schemaMigration, error) {
+    applied := map[uint]schemaMigration{}
+    if !db.Migrator().HasTable(&schemaMigration{}) {
+        return applied, nil
+    }
+
+    var rows []schemaMigration
+    if err := db.Order("version ASC").Find(&rows).Error; err != nil {
+        return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+    }
+    for _, row := range rows {
+        applied[row.Version] = row
+    }
+    return applied, nil
+}
+
+// MigrateUp applies every pending migration in version order
+func MigrateUp(db *gorm.DB) error {
+    if err := db.Migrator().AutoMigrate(&schemaMigration{}); err != nil {
+        return fmt.Errorf("failed to create schema_migrations: %w", err)
+    }
+
+    migrations, err := migrationsFor(db)
+    if err != nil {
+        return err
+    }
+    applied, err := appliedMigrations(db)
+    if err != nil {
+        return err
+    }
+
+    for _, migration := range migrations {
+        if _, ok := applied[migration.Version]; ok {
+            continue
+        }
+
+        migration := migration
+        err := db.Transaction(func(tx *gorm.DB) error {
+            if err := migration.up(tx); err != nil {
+                return err
+            }
+            return tx.Create(&schemaMigration{
+                Version:   migration.Version,
+                Name:      migration.Name,
+                AppliedAt: time.Now(),
+            }).Error
+        })
+        if err != nil {
+            return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+        }
+        log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+    }
+    return nil
+}
+
+// MigrateDown reverts the given number of most recently applied migrations
+func MigrateDown(db *gorm.DB, steps int) error {
+    migrations, err := migrationsFor(db)
+    if err != nil {
+        return err
+    }
+    applied, err := appliedMigrations(db)
+    if err != nil {
+        return err
+    }
+
+    for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+        migration := migrations[i]
+        if _, ok := applied[migration.Version]; !ok {
+            continue
+        }
+
+        err := db.Transaction(func(tx *gorm.DB) error {
+            if err := migration.down(tx); err != nil {
+                return err
+            }
+            return tx.Delete(&schemaMigration{}, migration.Version).Error
+        })
+        if err != nil {
+            return fmt.Errorf("rollback of %04d_%s failed: %w", migration.Version, migration.Name, err)
+        }
+        log.Printf("Rolled back migration %04d_%s", migration.Version, migration.Name)
+        steps--
+    }
+    return nil
+}
+
+// MigrationStatus lists every known migration and when it was applied
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+    migrations, err := migrationsFor(db)
+    if err != nil {
+        return nil, err
+    }
+    applied, err := appliedMigrations(db)
+    if err != nil {
+        return nil, err
+    }
+
+    states := make([]MigrationState, 0, len(migrations))
+    for _, migration := range migrations {
+        state := MigrationState{Version: migration.Version, Name: migration.Name}
+        if row, ok := applied[migration.Version]; ok {
+            appliedAt := row.AppliedAt
+            state.AppliedAt = &appliedAt
+        }
+        states = append(states, state)
+    }
+    return states, nil
+}
+
+// CheckSchema returns ErrSchemaBehind if any migration has not been applied
+func CheckSchema(db *gorm.DB) error {
+    migrations, err := migrationsFor(db)
+    if err != nil {
+        return err
+    }
+    applied, err := appliedMigrations(db)
+    if err != nil {
+        return err
+    }
+
+    pending := 0
+    for _, migration := range migrations {
+        if _, ok := applied[migration.Version]; !ok {
+            pending++
+        }
+    }
+    if pending > 0 {
+        return fmt.Errorf("%w: %d pending migration(s), run `minibank migrate up`", ErrSchemaBehind, pending)
+    }
+
+    for version, row := range applied {
+        if version > LatestVersion() {
+            log.Printf("WARNING: database has migration %04d_%s which this binary does not know about", version, row.Name)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,262 @@
+package database
+
+import (
+    "fmt"
+    "log"
+    "time"
+
+    "minibank-go/money"
+
+    "gorm.io/gorm"
+)
+
+// Schema as of migration 0001, used to adopt databases that predate
+// versioned migrations. These mirror the models at the time and must not be
+// changed; later migrations define their own snapshots.
+
+type v1User struct {
+    ID        uint           `gorm:"primaryKey"`
+    Email     string         `gorm:"uniqueIndex;size:255;not null"`
+    Phone     string         `gorm:"uniqueIndex;size:32;not null"`
+    Password  string         `gorm:"not null"`
+    FirstName string         `gorm:"not null"`
+    LastName  string         `gorm:"not null"`
+    IsActive  bool           `gorm:"default:true"`
+    IsAdmin   bool           `gorm:"default:false"`
+    KYCStatus string         `gorm:"default:pending"`
+    Verified  bool           `gorm:"default:false"`
+    CreatedAt time.Time
+    UpdatedAt time.Time
+    DeletedAt gorm.DeletedAt `gorm:"index"`
+    Accounts  []v1Account    `gorm:"foreignKey:UserID"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1Account struct {
+    ID            uint           `gorm:"primaryKey"`
+    UserID        uint           `gorm:"index;not null"`
+    User          *v1User        `gorm:"foreignKey:UserID"`
+    AccountNumber string         `gorm:"uniqueIndex;size:34;not null"`
+    Type          string         `gorm:"not null"`
+    Nickname      string
+    Currency      money.Currency `gorm:"size:3;not null;default:INR"`
+    Balance       money.Amount   `gorm:"not null;default:0"`
+    Status        string         `gorm:"not null;default:active"`
+    Version       uint           `gorm:"not null;default:1"`
+    OpenedAt      time.Time      `gorm:"not null"`
+    ClosedAt      *time.Time
+    CreatedAt     time.Time
+    UpdatedAt     time.Time
+    DeletedAt     gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Account) TableName() string { return "accounts" }
+
+type v1KYC struct {
+    ID              uint       `gorm:"primaryKey"`
+    UserID          uint       `gorm:"not null"`
+    User            v1User     `gorm:"foreignKey:UserID"`
+    PAN             string     `gorm:"not null"`
+    AadhaarNumber   string
+    PassportNumber  string
+    DateOfBirth     time.Time  `gorm:"not null"`
+    Address         string     `gorm:"not null"`
+    City            string     `gorm:"not null"`
+    State           string     `gorm:"not null"`
+    PinCode         string     `gorm:"not null"`
+    Status          string     `gorm:"default:pending"`
+    RejectionReason string
+    VerifiedBy      uint
+    VerifiedAt      *time.Time
+    CreatedAt       time.Time
+    UpdatedAt       time.Time
+    DeletedAt       gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1KYC) TableName() string { return "kycs" }
+
+type v1Transaction struct {
+    ID             uint           `gorm:"primaryKey"`
+    AccountID      uint           `gorm:"index;not null"`
+    Account        *v1Account     `gorm:"foreignKey:AccountID"`
+    Type           string         `gorm:"not null"`
+    Amount         money.Amount   `gorm:"not null"`
+    Currency       money.Currency `gorm:"size:3;not null;default:INR"`
+    BalanceBefore  money.Amount   `gorm:"not null"`
+    BalanceAfter   money.Amount   `gorm:"not null"`
+    ToAccountID    *uint
+    ToAccount      *v1Account     `gorm:"foreignKey:ToAccountID"`
+    FromAccountID  *uint
+    FromAccount    *v1Account     `gorm:"foreignKey:FromAccountID"`
+    Description    string
+    Reference      string
+    JournalEntryID *uint
+    Status         string         `gorm:"default:completed"`
+    IPAddress      string
+    CreatedAt      time.Time
+    UpdatedAt      time.Time
+    DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Transaction) TableName() string { return "transactions" }
+
+type v1AuditLog struct {
+    ID        uint           `gorm:"primaryKey"`
+    UserID    *uint
+    User      *v1User        `gorm:"foreignKey:UserID"`
+    Action    string         `gorm:"not null"`
+    Resource  string         `gorm:"not null"`
+    Details   string
+    IPAddress string
+    UserAgent string
+    CreatedAt time.Time
+    UpdatedAt time.Time
+    DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1AuditLog) TableName() string { return "audit_logs" }
+
+type v1LedgerAccount struct {
+    ID            uint           `gorm:"primaryKey"`
+    Code          string         `gorm:"uniqueIndex;size:100;not null"`
+    Name          string         `gorm:"not null"`
+    Type          string         `gorm:"not null"`
+    NormalBalance string         `gorm:"not null"`
+    AccountID     *uint          `gorm:"index"`
+    Currency      money.Currency `gorm:"size:3;not null;default:INR"`
+    CreatedAt     time.Time
+    UpdatedAt     time.Time
+}
+
+func (v1LedgerAccount) TableName() string { return "ledger_accounts" }
+
+type v1JournalEntry struct {
+    ID          uint        `gorm:"primaryKey"`
+    Reference   string      `gorm:"index;not null"`
+    Type        string      `gorm:"not null"`
+    Description string
+    PostedAt    time.Time   `gorm:"not null"`
+    Postings    []v1Posting `gorm:"foreignKey:JournalEntryID"`
+    CreatedAt   time.Time
+}
+
+func (v1JournalEntry) TableName() string { return "journal_entries" }
+
+type v1Posting struct {
+    ID              uint             `gorm:"primaryKey"`
+    JournalEntryID  uint             `gorm:"index;not null"`
+    LedgerAccountID uint             `gorm:"index;not null"`
+    LedgerAccount   *v1LedgerAccount `gorm:"foreignKey:LedgerAccountID"`
+    Amount          money.Amount     `gorm:"not null"`
+    CreatedAt       time.Time
+}
+
+func (v1Posting) TableName() string { return "postings" }
+
+type v1IdempotencyKey struct {
+    ID           uint      `gorm:"primaryKey"`
+    UserID       uint      `gorm:"uniqueIndex:idx_idempotency_user_key;not null"`
+    Key          string    `gorm:"column:idempotency_key;uniqueIndex:idx_idempotency_user_key;size:255;not null"`
+    Method       string    `gorm:"not null"`
+    Path         string    `gorm:"not null"`
+    RequestHash  string    `gorm:"not null"`
+    Status       string    `gorm:"not null;default:in_progress"`
+    ResponseCode int
+    ResponseBody string
+    ContentType  string
+    ExpiresAt    time.Time `gorm:"index;not null"`
+    CreatedAt    time.Time
+    UpdatedAt    time.Time
+}
+
+func (v1IdempotencyKey) TableName() string { return "idempotency_keys" }
+
+// adoptLegacySchema brings a database that predates versioned migrations up
+// to the schema of 0001_initial_schema.up.sql, whose statements then have
+// nothing left to create: float balances are converted to minor units, user
+// balances move onto accounts, missing tables and columns are added and
+// account numbers without check digits are reissued. A new database is left
+// to the script.
+func adoptLegacySchema(tx *gorm.DB) error {
+    if !tx.Migrator().HasTable("users") {
+        return nil
+    }
+
+    if err := convertMoneyColumns(tx); err != nil {
+        return err
+    }
+    if err := migrateBalancesToAccounts(tx); err != nil {
+        return err
+    }
+
+    if err := tx.Migrator().AutoMigrate(
+        &v1User{},
+        &v1Account{},
+        &v1KYC{},
+        &v1Transaction{},
+        &v1AuditLog{},
+        &v1LedgerAccount{},
+        &v1JournalEntry{},
+        &v1Posting{},
+        &v1IdempotencyKey{},
+    ); err != nil {
+        return err
+    }
+
+    return reissueAccountNumbers(tx)
+}
+
+// postOpeningBalances gives every account with a balance but no ledger
+// account an opening entry against suspense, so balances that predate the
+// ledger are backed by postings.
+func postOpeningBalances(tx *gorm.DB) error {
+    var accounts []v1Account
+    if err := tx.Where("balance <> 0").
+        Where("NOT EXISTS (SELECT 1 FROM ledger_accounts WHERE ledger_accounts.account_id = accounts.id)").
+        Find(&accounts).Error; err != nil {
+        return fmt.Errorf("failed to find accounts without ledger accounts: %w", err)
+    }
+
+    for _, account := range accounts {
+        account := account
+        suspense := v1LedgerAccount{
+            Code:          fmt.Sprintf("system:suspense:%s", account.Currency),
+            Name:          "Suspense",
+            Type:          "asset",
+            NormalBalance: "debit",
+            Currency:      account.Currency,
+        }
+        if err := tx.Where("code = ?", suspense.Code).FirstOrCreate(&suspense).Error; err != nil {
+            return fmt.Errorf("failed to load %s: %w", suspense.Code, err)
+        }
+
+        customer := v1LedgerAccount{
+            Code:          fmt.Sprintf("account:%d", account.ID),
+            Name:          fmt.Sprintf("Customer account %s", account.AccountNumber),
+            Type:          "liability",
+            NormalBalance: "credit",
+            AccountID:     &account.ID,
+            Currency:      account.Currency,
+        }
+        if err := tx.Create(&customer).Error; err != nil {
+            return fmt.Errorf("failed to create %s: %w", customer.Code, err)
+        }
+
+        entry := v1JournalEntry{
+            Reference:   fmt.Sprintf("opening-balance-%d", account.ID),
+            Type:        "opening_balance",
+            Description: "Opening balance carried over from pre-ledger balance",
+            PostedAt:    time.Now(),
+            Postings: []v1Posting{
+                {LedgerAccountID: suspense.ID, Amount: account.Balance},
+                {LedgerAccountID: customer.ID, Amount: -account.Balance},
+            },
+        }
+        if err := tx.Create(&entry).Error; err != nil {
+            return fmt.Errorf("failed to post opening balance for account %d: %w", account.ID, err)
+        }
+        log.Printf("Posted opening ledger balance %s for account %d", account.Balance, account.ID)
+    }
+    return nil
+}
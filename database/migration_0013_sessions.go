@@ -0,0 +1,83 @@
+package database
+
+import (
+    "net"
+    "time"
+
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+type v13Session struct {
+    ID         uint `gorm:"primaryKey"`
+    UserID     uint
+    FamilyID   string
+    DeviceName string
+    UserAgent  string
+    IPAddress  string
+    MFA        bool
+    LastSeenAt time.Time
+    ExpiresAt  time.Time
+    RevokedAt  *time.Time
+    CreatedAt  time.Time
+}
+
+func (v13Session) TableName() string { return "sessions" }
+
+// v13RefreshToken is the part of a refresh token a session is built from
+type v13RefreshToken struct {
+    UserID    uint
+    FamilyID  string
+    MFA       bool
+    IPAddress string
+    UserAgent string
+    ExpiresAt time.Time
+    CreatedAt time.Time
+}
+
+func (v13RefreshToken) TableName() string { return "refresh_tokens" }
+
+// backfillSessions creates a session for every refresh token family that can
+// still be refreshed, so existing logins show up and can be revoked
+func backfillSessions(tx *gorm.DB) error {
+    live := tx.Model(&v13RefreshToken{}).Select("family_id").Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+    var tokens []v13RefreshToken
+    if err := tx.Where("family_id IN (?)", live).Order("created_at, id").Find(&tokens).Error; err != nil {
+        return err
+    }
+
+    // The first token of a family comes from the login, the last from the
+    // latest refresh
+    sessions := make(map[string]*v13Session)
+    var order []string
+    for _, token := range tokens {
+        session, ok := sessions[token.FamilyID]
+        if !ok {
+            ip, _, err := net.SplitHostPort(token.IPAddress)
+            if err != nil {
+                ip = token.IPAddress
+            }
+            session = &v13Session{
+                UserID:     token.UserID,
+                FamilyID:   token.FamilyID,
+                DeviceName: utils.DeviceName(token.UserAgent),
+                UserAgent:  token.UserAgent,
+                IPAddress:  ip,
+                MFA:        token.MFA,
+                CreatedAt:  token.CreatedAt,
+            }
+            sessions[token.FamilyID] = session
+            order = append(order, token.FamilyID)
+        }
+        session.LastSeenAt = token.CreatedAt
+        session.ExpiresAt = token.ExpiresAt
+    }
+
+    for _, family := range order {
+        if err := tx.Create(sessions[family]).Error; err != nil {
+            return err
+        }
+    }
+    return nil
+}
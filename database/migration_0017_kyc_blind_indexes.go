@@ -0,0 +1,102 @@
+package database
+
+import (
+    "fmt"
+
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// v17KYC holds the document numbers of a KYC record. Passport numbers were
+// stored in plaintext until this migration.
+type v17KYC struct {
+    ID             uint `gorm:"primaryKey"`
+    PAN            string
+    AadhaarNumber  string
+    PassportNumber string
+}
+
+func (v17KYC) TableName() string { return "kycs" }
+
+// v17BlindIndexes computes the blind indexes of a record's document numbers
+func v17BlindIndexes(pan, aadhaar, passport string) (map[string]interface{}, error) {
+    columns := make(map[string]interface{})
+    for _, document := range []struct{ column, kind, number string }{
+        {"pan_index", "pan", pan},
+        {"aadhaar_index", "aadhaar", aadhaar},
+        {"passport_index", "passport", passport},
+    } {
+        index, err := utils.BlindIndex(document.kind, document.number)
+        if err != nil {
+            return nil, err
+        }
+        columns[document.column] = index
+    }
+    return columns, nil
+}
+
+// eachV17KYC calls fn on every KYC record, a batch at a time
+func eachV17KYC(tx *gorm.DB, fn func(kyc *v17KYC) error) error {
+    var lastID uint
+    for {
+        var records []v17KYC
+        if err := tx.Where("id > ?", lastID).Order("id").Limit(100).Find(&records).Error; err != nil {
+            return err
+        }
+        if len(records) == 0 {
+            return nil
+        }
+        for i := range records {
+            lastID = records[i].ID
+            if err := fn(&records[i]); err != nil {
+                return fmt.Errorf("KYC record %d: %v", records[i].ID, err)
+            }
+        }
+    }
+}
+
+// backfillKYCBlindIndexes fills in the blind index columns. Passport numbers
+// are encrypted, and PAN and Aadhaar numbers re-encrypted alongside so that
+// key_id covers all three. This needs the encryption and blind index keys,
+// and fails on a record that cannot be decrypted.
+func backfillKYCBlindIndexes(tx *gorm.DB) error {
+    return eachV17KYC(tx, func(kyc *v17KYC) error {
+        pan, err := utils.DecryptSensitiveData(kyc.PAN)
+        if err != nil {
+            return err
+        }
+        aadhaar, err := utils.DecryptSensitiveData(kyc.AadhaarNumber)
+        if err != nil {
+            return err
+        }
+
+        columns, err := v17BlindIndexes(pan, aadhaar, kyc.PassportNumber)
+        if err != nil {
+            return err
+        }
+        for column, plaintext := range map[string]string{
+            "pan":             pan,
+            "aadhaar_number":  aadhaar,
+            "passport_number": kyc.PassportNumber,
+        } {
+            if columns[column], err = utils.EncryptSensitiveData(plaintext); err != nil {
+                return err
+            }
+        }
+        columns["key_id"] = utils.EncryptionKeyID(columns["pan"].(string))
+        return tx.Model(&v17KYC{}).Where("id = ?", kyc.ID).UpdateColumns(columns).Error
+    })
+}
+
+// decryptPassportNumbers stores passport numbers in plaintext again before
+// the blind indexes are dropped
+func decryptPassportNumbers(tx *gorm.DB) error {
+    return eachV17KYC(tx, func(kyc *v17KYC) error {
+        passport, err := utils.DecryptSensitiveData(kyc.PassportNumber)
+        if err != nil {
+            return err
+        }
+        return tx.Model(&v17KYC{}).Where("id = ?", kyc.ID).UpdateColumn("passport_number", passport).Error
+    })
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+    "embed"
+    "fmt"
+    "io/fs"
+    "path"
+    "sort"
+    "strconv"
+    "strings"
+
+    "gorm.io/gorm"
+)
+
+// The schema changes live in migrations/<dialect>/NNNN_name.up.sql and
+// NNNN_name.down.sql, one pair per version and dialect. Add a new migration
+// to every dialect with the next version number; never edit or renumber one
+// that has shipped.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// dialects are the databases migrations are written for, named as GORM
+// names their dialectors
+var dialects = []string{"sqlite", "postgres", "mysql"}
+
+// dataMigrations are the steps of a migration that SQL cannot express, keyed
+// by version. They run in the same transaction as the migration's scripts.
+var dataMigrations = map[uint]dataMigration{
+    1:  {BeforeUp: adoptLegacySchema, AfterUp: postOpeningBalances},
+    13: {AfterUp: backfillSessions},
+    17: {AfterUp: backfillKYCBlindIndexes, BeforeDown: decryptPassportNumbers},
+}
+
+// migrationSets holds the migrations of each dialect in version order
+var migrationSets = loadMigrations()
+
+func loadMigrations() map[string][]Migration {
+    sets := make(map[string][]Migration, len(dialects))
+    for _, dialect := range dialects {
+        set, err := readMigrations(dialect)
+        if err != nil {
+            panic(fmt.Sprintf("invalid %s migrations: %v", dialect, err))
+        }
+        sets[dialect] = set
+    }
+
+    // Every dialect must describe the same versions
+    reference := sets[dialects[0]]
+    for _, dialect := range dialects[1:] {
+        set := sets[dialect]
+        if len(set) != len(reference) {
+            panic(fmt.Sprintf("%s has %d migrations but %s has %d", dialect, len(set), dialects[0], len(reference)))
+        }
+        for i := range set {
+            if set[i].Name != reference[i].Name {
+                panic(fmt.Sprintf("%s migration %04d is %q but %s has %q", dialect, set[i].Version, set[i].Name, dialects[0], reference[i].Name))
+            }
+        }
+    }
+    return sets
+}
+
+// readMigrations parses the scripts of one dialect
+func readMigrations(dialect string) ([]Migration, error) {
+    dir := path.Join("migrations", dialect)
+    entries, err := fs.ReadDir(migrationFiles, dir)
+    if err != nil {
+        return nil, err
+    }
+
+    byVersion := make(map[uint]*Migration)
+    scripts := make(map[uint]int)
+    for _, entry := range entries {
+        base, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+        if !ok || (direction != "up" && direction != "down") || !strings.HasSuffix(entry.Name(), ".sql") {
+            return nil, fmt.Errorf("%s is not named NNNN_name.up.sql or NNNN_name.down.sql", entry.Name())
+        }
+        number, name, ok := strings.Cut(base, "_")
+        version, err := strconv.ParseUint(number, 10, 32)
+        if !ok || err != nil || len(number) != 4 {
+            return nil, fmt.Errorf("%s does not start with a four digit version", entry.Name())
+        }
+
+        script, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+        if err != nil {
+            return nil, err
+        }
+        statements, err := splitStatements(string(script))
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+        }
+
+        migration, ok := byVersion[uint(version)]
+        if !ok {
+            migration = &Migration{Version: uint(version), Name: name}
+            byVersion[uint(version)] = migration
+        } else if migration.Name != name {
+            return nil, fmt.Errorf("version %04d is named both %q and %q", version, migration.Name, name)
+        }
+        if direction == "up" {
+            migration.Up = statements
+        } else {
+            migration.Down = statements
+        }
+        scripts[uint(version)]++
+    }
+
+    set := make([]Migration, 0, len(byVersion))
+    for _, migration := range byVersion {
+        if scripts[migration.Version] != 2 {
+            return nil, fmt.Errorf("%04d_%s needs both an up and a down script", migration.Version, migration.Name)
+        }
+        migration.data = dataMigrations[migration.Version]
+        set = append(set, *migration)
+    }
+    sort.Slice(set, func(i, j int) bool { return set[i].Version < set[j].Version })
+    for i, migration := range set {
+        if migration.Version != uint(i+1) {
+            return nil, fmt.Errorf("migration %04d is missing", i+1)
+        }
+    }
+    return set, nil
+}
+
+// splitStatements splits a script into statements. A statement ends with a
+// semicolon at the end of a line; lines starting with -- are comments.
+func splitStatements(script string) ([]string, error) {
+    var statements []string
+    var current []string
+    for _, line := range strings.Split(script, "\n") {
+        trimmed := strings.TrimSpace(line)
+        if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+            continue
+        }
+        current = append(current, strings.TrimRight(line, " \t\r"))
+        if strings.HasSuffix(trimmed, ";") {
+            statement := strings.Join(current, "\n")
+            statements = append(statements, strings.TrimSuffix(statement, ";"))
+            current = nil
+        }
+    }
+    if len(current) > 0 {
+        return nil, fmt.Errorf("statement %q is missing its terminating semicolon", strings.Join(current, " "))
+    }
+    return statements, nil
+}
+
+// migrationsFor returns the migrations written for the database's dialect
+func migrationsFor(db *gorm.DB) ([]Migration, error) {
+    dialect := db.Dialector.Name()
+    set, ok := migrationSets[dialect]
+    if !ok {
+        return nil, fmt.Errorf("no migrations for %s databases", dialect)
+    }
+    return set, nil
+}
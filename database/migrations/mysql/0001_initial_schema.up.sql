@@ -0,0 +1,169 @@
+-- Tables and indexes are only created when missing, because a database
+-- that predates versioned migrations already has some of them. Those
+-- databases are first brought up to this schema in Go; see
+-- migration_0001_initial_schema.go.
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `email` varchar(255) NOT NULL,
+    `phone` varchar(32) NOT NULL,
+    `password` longtext NOT NULL,
+    `first_name` longtext NOT NULL,
+    `last_name` longtext NOT NULL,
+    `is_active` boolean DEFAULT true,
+    `is_admin` boolean DEFAULT false,
+    `kyc_status` varchar(191) DEFAULT 'pending',
+    `verified` boolean DEFAULT false,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_users_email` (`email`),
+    UNIQUE INDEX `idx_users_phone` (`phone`),
+    INDEX `idx_users_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `accounts` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `account_number` varchar(34) NOT NULL,
+    `type` longtext NOT NULL,
+    `nickname` longtext,
+    `currency` varchar(3) NOT NULL DEFAULT 'INR',
+    `balance` bigint NOT NULL DEFAULT 0,
+    `status` varchar(191) NOT NULL DEFAULT 'active',
+    `version` bigint unsigned NOT NULL DEFAULT 1,
+    `opened_at` datetime(3) NOT NULL,
+    `closed_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_accounts_user_id` (`user_id`),
+    UNIQUE INDEX `idx_accounts_account_number` (`account_number`),
+    INDEX `idx_accounts_deleted_at` (`deleted_at`),
+    CONSTRAINT `fk_users_accounts` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `kycs` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `pan` longtext NOT NULL,
+    `aadhaar_number` longtext,
+    `passport_number` longtext,
+    `date_of_birth` datetime(3) NOT NULL,
+    `address` longtext NOT NULL,
+    `city` longtext NOT NULL,
+    `state` longtext NOT NULL,
+    `pin_code` longtext NOT NULL,
+    `status` varchar(191) DEFAULT 'pending',
+    `rejection_reason` longtext,
+    `verified_by` bigint unsigned,
+    `verified_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_kycs_deleted_at` (`deleted_at`),
+    CONSTRAINT `fk_kycs_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `transactions` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `account_id` bigint unsigned NOT NULL,
+    `type` longtext NOT NULL,
+    `amount` bigint NOT NULL,
+    `currency` varchar(3) NOT NULL DEFAULT 'INR',
+    `balance_before` bigint NOT NULL,
+    `balance_after` bigint NOT NULL,
+    `to_account_id` bigint unsigned,
+    `from_account_id` bigint unsigned,
+    `description` longtext,
+    `reference` longtext,
+    `journal_entry_id` bigint unsigned,
+    `status` varchar(191) DEFAULT 'completed',
+    `ip_address` longtext,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_transactions_account_id` (`account_id`),
+    INDEX `idx_transactions_deleted_at` (`deleted_at`),
+    CONSTRAINT `fk_transactions_account` FOREIGN KEY (`account_id`) REFERENCES `accounts`(`id`),
+    CONSTRAINT `fk_transactions_to_account` FOREIGN KEY (`to_account_id`) REFERENCES `accounts`(`id`),
+    CONSTRAINT `fk_transactions_from_account` FOREIGN KEY (`from_account_id`) REFERENCES `accounts`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `audit_logs` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned,
+    `action` longtext NOT NULL,
+    `resource` longtext NOT NULL,
+    `details` longtext,
+    `ip_address` longtext,
+    `user_agent` longtext,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_audit_logs_deleted_at` (`deleted_at`),
+    CONSTRAINT `fk_audit_logs_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `ledger_accounts` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `code` varchar(100) NOT NULL,
+    `name` longtext NOT NULL,
+    `type` longtext NOT NULL,
+    `normal_balance` longtext NOT NULL,
+    `account_id` bigint unsigned,
+    `currency` varchar(3) NOT NULL DEFAULT 'INR',
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_ledger_accounts_code` (`code`),
+    INDEX `idx_ledger_accounts_account_id` (`account_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `journal_entries` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `reference` varchar(191) NOT NULL,
+    `type` longtext NOT NULL,
+    `description` longtext,
+    `posted_at` datetime(3) NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_journal_entries_reference` (`reference`)
+);
+
+CREATE TABLE IF NOT EXISTS `postings` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `journal_entry_id` bigint unsigned NOT NULL,
+    `ledger_account_id` bigint unsigned NOT NULL,
+    `amount` bigint NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_postings_journal_entry_id` (`journal_entry_id`),
+    INDEX `idx_postings_ledger_account_id` (`ledger_account_id`),
+    CONSTRAINT `fk_postings_ledger_account` FOREIGN KEY (`ledger_account_id`) REFERENCES `ledger_accounts`(`id`),
+    CONSTRAINT `fk_journal_entries_postings` FOREIGN KEY (`journal_entry_id`) REFERENCES `journal_entries`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `idempotency_keys` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `idempotency_key` varchar(255) NOT NULL,
+    `method` longtext NOT NULL,
+    `path` longtext NOT NULL,
+    `request_hash` longtext NOT NULL,
+    `status` varchar(191) NOT NULL DEFAULT 'in_progress',
+    `response_code` bigint,
+    `response_body` longtext,
+    `content_type` longtext,
+    `expires_at` datetime(3) NOT NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_idempotency_user_key` (`user_id`,`idempotency_key`),
+    INDEX `idx_idempotency_keys_expires_at` (`expires_at`)
+);
@@ -0,0 +1,24 @@
+ALTER TABLE `accounts` ADD `held_amount` bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE `holds` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `account_id` bigint unsigned NOT NULL,
+    `reference` varchar(64) NOT NULL,
+    `amount` bigint NOT NULL,
+    `captured_amount` bigint NOT NULL DEFAULT 0,
+    `currency` varchar(3) NOT NULL DEFAULT 'INR',
+    `description` longtext,
+    `status` varchar(20) NOT NULL DEFAULT 'pending',
+    `transaction_id` bigint unsigned,
+    `expires_at` datetime(3) NOT NULL,
+    `captured_at` datetime(3) NULL,
+    `released_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_holds_status` (`status`),
+    INDEX `idx_holds_expires_at` (`expires_at`),
+    INDEX `idx_holds_account_id` (`account_id`),
+    UNIQUE INDEX `idx_holds_reference` (`reference`),
+    CONSTRAINT `fk_holds_account` FOREIGN KEY (`account_id`) REFERENCES `accounts`(`id`)
+);
@@ -0,0 +1,41 @@
+CREATE TABLE `scheduled_transfers` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `from_account_id` bigint unsigned NOT NULL,
+    `to_account_id` bigint unsigned,
+    `to` varchar(34),
+    `amount` bigint NOT NULL,
+    `currency` varchar(3) NOT NULL DEFAULT 'INR',
+    `description` longtext,
+    `schedule` varchar(100),
+    `start_at` datetime(3) NOT NULL,
+    `end_at` datetime(3) NULL,
+    `max_occurrences` bigint NOT NULL DEFAULT 0,
+    `occurrences` bigint NOT NULL DEFAULT 0,
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `next_run_at` datetime(3) NULL,
+    `last_run_at` datetime(3) NULL,
+    `last_error` longtext,
+    `status` varchar(20) NOT NULL DEFAULT 'active',
+    `version` bigint unsigned NOT NULL DEFAULT 1,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_scheduled_transfers_user_id` (`user_id`),
+    INDEX `idx_scheduled_transfers_next_run_at` (`next_run_at`),
+    INDEX `idx_scheduled_transfers_status` (`status`)
+);
+
+CREATE TABLE `scheduled_transfer_runs` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `scheduled_transfer_id` bigint unsigned NOT NULL,
+    `occurrence` bigint NOT NULL,
+    `attempt` bigint NOT NULL,
+    `due_at` datetime(3) NOT NULL,
+    `status` varchar(20) NOT NULL,
+    `reference` longtext,
+    `error` longtext,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_scheduled_transfer_runs_attempt` (`scheduled_transfer_id`,`occurrence`,`attempt`)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE `beneficiaries` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `account_id` bigint unsigned NOT NULL,
+    `name` varchar(100) NOT NULL,
+    `nickname` varchar(50),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_beneficiaries_user_account` (`user_id`,`account_id`),
+    CONSTRAINT `fk_beneficiaries_account` FOREIGN KEY (`account_id`) REFERENCES `accounts`(`id`)
+);
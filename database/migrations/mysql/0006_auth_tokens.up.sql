@@ -0,0 +1,32 @@
+CREATE TABLE `refresh_tokens` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `token_hash` varchar(64) NOT NULL,
+    `family_id` varchar(36) NOT NULL,
+    `access_jti` varchar(36) NOT NULL,
+    `access_expires_at` datetime(3) NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `revoked_at` datetime(3) NULL,
+    `replaced_by_id` bigint unsigned,
+    `ip_address` longtext,
+    `user_agent` longtext,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_refresh_tokens_user_id` (`user_id`),
+    UNIQUE INDEX `idx_refresh_tokens_token_hash` (`token_hash`),
+    INDEX `idx_refresh_tokens_family_id` (`family_id`),
+    INDEX `idx_refresh_tokens_access_jti` (`access_jti`)
+);
+
+CREATE TABLE `revoked_tokens` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `jti` varchar(36) NOT NULL,
+    `user_id` bigint unsigned NOT NULL,
+    `reason` varchar(100),
+    `expires_at` datetime(3) NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_revoked_tokens_jti` (`jti`),
+    INDEX `idx_revoked_tokens_user_id` (`user_id`),
+    INDEX `idx_revoked_tokens_expires_at` (`expires_at`)
+);
@@ -0,0 +1,15 @@
+ALTER TABLE `users` ADD `two_factor_enabled` boolean NOT NULL DEFAULT false;
+ALTER TABLE `users` ADD `totp_secret` longtext;
+ALTER TABLE `users` ADD `totp_last_step` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `refresh_tokens` ADD `mfa` boolean NOT NULL DEFAULT false;
+
+CREATE TABLE `recovery_codes` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `code_hash` varchar(64) NOT NULL,
+    `used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_recovery_codes_user_id` (`user_id`),
+    UNIQUE INDEX `idx_recovery_codes_code_hash` (`code_hash`)
+);
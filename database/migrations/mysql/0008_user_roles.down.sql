@@ -0,0 +1,7 @@
+-- Keeps admin rights for superadmins only; the other staff roles have no
+-- equivalent before this migration and are lost.
+
+ALTER TABLE `users` ADD `is_admin` boolean DEFAULT false;
+UPDATE users SET is_admin = true WHERE role = 'superadmin';
+DROP INDEX `idx_users_role` ON `users`;
+ALTER TABLE `users` DROP COLUMN `role`;
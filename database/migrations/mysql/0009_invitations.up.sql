@@ -0,0 +1,15 @@
+CREATE TABLE `invitations` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `email` varchar(255) NOT NULL,
+    `role` varchar(20) NOT NULL,
+    `token_hash` varchar(64) NOT NULL,
+    `invited_by_id` bigint unsigned NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `accepted_at` datetime(3) NULL,
+    `user_id` bigint unsigned,
+    `revoked_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_invitations_email` (`email`),
+    UNIQUE INDEX `idx_invitations_token_hash` (`token_hash`)
+);
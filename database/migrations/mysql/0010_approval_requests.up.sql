@@ -0,0 +1,20 @@
+CREATE TABLE `approval_requests` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `operation` varchar(50) NOT NULL,
+    `payload` longblob NOT NULL,
+    `summary` varchar(255),
+    `status` varchar(20) NOT NULL DEFAULT 'pending',
+    `maker_id` bigint unsigned NOT NULL,
+    `checker_id` bigint unsigned,
+    `comment` longtext,
+    `result` longblob,
+    `error` longtext,
+    `expires_at` datetime(3) NOT NULL,
+    `decided_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_approval_requests_operation` (`operation`),
+    INDEX `idx_approval_requests_status` (`status`),
+    INDEX `idx_approval_requests_maker_id` (`maker_id`)
+);
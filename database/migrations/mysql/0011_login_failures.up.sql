@@ -0,0 +1,10 @@
+CREATE TABLE `login_failures` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `email` varchar(255) NOT NULL,
+    `failures` bigint NOT NULL DEFAULT 0,
+    `last_failed_at` datetime(3) NOT NULL,
+    `locked_until` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_login_failures_email` (`email`),
+    INDEX `idx_login_failures_last_failed_at` (`last_failed_at`)
+);
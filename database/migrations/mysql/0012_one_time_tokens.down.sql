@@ -0,0 +1 @@
+DROP TABLE `one_time_tokens`;
@@ -0,0 +1,13 @@
+CREATE TABLE `one_time_tokens` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `purpose` varchar(30) NOT NULL,
+    `token_hash` varchar(64) NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_one_time_tokens_expires_at` (`expires_at`),
+    INDEX `idx_one_time_tokens_user_id` (`user_id`),
+    UNIQUE INDEX `idx_one_time_tokens_token_hash` (`token_hash`)
+);
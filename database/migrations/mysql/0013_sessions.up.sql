@@ -0,0 +1,19 @@
+-- Sessions for existing logins are created in Go; see migration_0013_sessions.go.
+
+CREATE TABLE `sessions` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `family_id` varchar(36) NOT NULL,
+    `device_name` varchar(100),
+    `user_agent` longtext,
+    `ip_address` varchar(45),
+    `mfa` boolean NOT NULL DEFAULT false,
+    `last_seen_at` datetime(3) NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `revoked_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_sessions_user_id` (`user_id`),
+    UNIQUE INDEX `idx_sessions_family_id` (`family_id`),
+    INDEX `idx_sessions_expires_at` (`expires_at`)
+);
@@ -0,0 +1,21 @@
+ALTER TABLE `users` ADD `service_account` boolean NOT NULL DEFAULT false;
+
+CREATE TABLE `api_keys` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `name` varchar(100) NOT NULL,
+    `prefix` varchar(16) NOT NULL,
+    `secret_hash` varchar(64) NOT NULL,
+    `scopes` text NOT NULL,
+    `rate_limit` bigint NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `last_used_at` datetime(3) NULL,
+    `last_used_ip` varchar(45),
+    `revoked_at` datetime(3) NULL,
+    `created_by_id` bigint unsigned NOT NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_api_keys_prefix` (`prefix`),
+    INDEX `idx_api_keys_user_id` (`user_id`)
+);
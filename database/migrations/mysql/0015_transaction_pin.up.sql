@@ -0,0 +1,3 @@
+ALTER TABLE `users` ADD `transaction_pin` longtext;
+ALTER TABLE `users` ADD `step_up_failures` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD `step_up_locked_until` datetime(3) NULL;
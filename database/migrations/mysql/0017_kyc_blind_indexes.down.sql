@@ -0,0 +1,8 @@
+-- Passport numbers are decrypted in Go before this runs.
+
+DROP INDEX `idx_kycs_pan_index` ON `kycs`;
+DROP INDEX `idx_kycs_aadhaar_index` ON `kycs`;
+DROP INDEX `idx_kycs_passport_index` ON `kycs`;
+ALTER TABLE `kycs` DROP COLUMN `pan_index`;
+ALTER TABLE `kycs` DROP COLUMN `aadhaar_index`;
+ALTER TABLE `kycs` DROP COLUMN `passport_index`;
@@ -0,0 +1,11 @@
+CREATE TABLE `mfa_failures` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `challenge_id` varchar(36) NOT NULL,
+    `user_id` bigint unsigned NOT NULL,
+    `failures` bigint NOT NULL DEFAULT 0,
+    `expires_at` datetime(3) NOT NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_mfa_failures_expires_at` (`expires_at`),
+    UNIQUE INDEX `idx_mfa_failures_challenge_id` (`challenge_id`),
+    INDEX `idx_mfa_failures_user_id` (`user_id`)
+);
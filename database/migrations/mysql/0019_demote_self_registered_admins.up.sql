@@ -0,0 +1,22 @@
+-- Takes staff rights away from the admins that migration 8 made
+-- superadmins. Until then anyone could register as an admin with the
+-- published admin code or an email containing "admin@", so none of them
+-- can be trusted. They become customers and are signed out, and each one
+-- is audited. Real staff among them have to be invited again.
+
+INSERT INTO audit_logs (user_id, action, resource, details, created_at, updated_at)
+SELECT id, 'ASSIGN_ROLE', 'USER', CONCAT('Self-registered admin ', email, ' demoted to customer; invite them again if they are staff'), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM users WHERE role = 'superadmin'
+    AND created_at <= (SELECT applied_at FROM schema_migrations WHERE version = 8);
+
+UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+WHERE revoked_at IS NULL AND user_id IN (SELECT id FROM users WHERE role = 'superadmin'
+        AND created_at <= (SELECT applied_at FROM schema_migrations WHERE version = 8));
+
+UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+WHERE revoked_at IS NULL AND user_id IN (SELECT id FROM users WHERE role = 'superadmin'
+        AND created_at <= (SELECT applied_at FROM schema_migrations WHERE version = 8));
+
+UPDATE users SET role = 'customer'
+WHERE role = 'superadmin'
+    AND created_at <= (SELECT applied_at FROM schema_migrations WHERE version = 8);
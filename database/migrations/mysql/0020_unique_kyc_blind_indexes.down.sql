@@ -0,0 +1,12 @@
+DROP INDEX `idx_kycs_pan_index` ON `kycs`;
+UPDATE `kycs` SET `pan_index` = '' WHERE `pan_index` IS NULL;
+ALTER TABLE `kycs` MODIFY `pan_index` varchar(64) NOT NULL DEFAULT '';
+CREATE INDEX `idx_kycs_pan_index` ON `kycs`(`pan_index`);
+DROP INDEX `idx_kycs_aadhaar_index` ON `kycs`;
+UPDATE `kycs` SET `aadhaar_index` = '' WHERE `aadhaar_index` IS NULL;
+ALTER TABLE `kycs` MODIFY `aadhaar_index` varchar(64) NOT NULL DEFAULT '';
+CREATE INDEX `idx_kycs_aadhaar_index` ON `kycs`(`aadhaar_index`);
+DROP INDEX `idx_kycs_passport_index` ON `kycs`;
+UPDATE `kycs` SET `passport_index` = '' WHERE `passport_index` IS NULL;
+ALTER TABLE `kycs` MODIFY `passport_index` varchar(64) NOT NULL DEFAULT '';
+CREATE INDEX `idx_kycs_passport_index` ON `kycs`(`passport_index`);
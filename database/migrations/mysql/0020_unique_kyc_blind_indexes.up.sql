@@ -0,0 +1,18 @@
+-- A document number can belong to only one KYC record. MySQL cannot leave
+-- empty values out of an index, so records without a number hold NULL
+-- instead, which unique indexes allow any number of times. This fails if
+-- two records already share a number; resolve those first with
+-- POST /api/admin/kyc/search.
+
+DROP INDEX `idx_kycs_pan_index` ON `kycs`;
+ALTER TABLE `kycs` MODIFY `pan_index` varchar(64) NULL DEFAULT NULL;
+UPDATE `kycs` SET `pan_index` = NULL WHERE `pan_index` = '';
+CREATE UNIQUE INDEX `idx_kycs_pan_index` ON `kycs`(`pan_index`);
+DROP INDEX `idx_kycs_aadhaar_index` ON `kycs`;
+ALTER TABLE `kycs` MODIFY `aadhaar_index` varchar(64) NULL DEFAULT NULL;
+UPDATE `kycs` SET `aadhaar_index` = NULL WHERE `aadhaar_index` = '';
+CREATE UNIQUE INDEX `idx_kycs_aadhaar_index` ON `kycs`(`aadhaar_index`);
+DROP INDEX `idx_kycs_passport_index` ON `kycs`;
+ALTER TABLE `kycs` MODIFY `passport_index` varchar(64) NULL DEFAULT NULL;
+UPDATE `kycs` SET `passport_index` = NULL WHERE `passport_index` = '';
+CREATE UNIQUE INDEX `idx_kycs_passport_index` ON `kycs`(`passport_index`);
@@ -0,0 +1,2 @@
+ALTER TABLE "transactions" ADD "reversal_of_id" bigint;
+CREATE UNIQUE INDEX "idx_transactions_reversal_of_id" ON "transactions" ("reversal_of_id");
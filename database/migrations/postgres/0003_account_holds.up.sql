@@ -0,0 +1,25 @@
+ALTER TABLE "accounts" ADD "held_amount" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE "holds" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "reference" varchar(64) NOT NULL,
+    "amount" bigint NOT NULL,
+    "captured_amount" bigint NOT NULL DEFAULT 0,
+    "currency" varchar(3) NOT NULL DEFAULT 'INR',
+    "description" text,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "transaction_id" bigint,
+    "expires_at" timestamptz NOT NULL,
+    "captured_at" timestamptz,
+    "released_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_holds_account" FOREIGN KEY ("account_id") REFERENCES "accounts"("id")
+);
+
+CREATE INDEX "idx_holds_account_id" ON "holds" ("account_id");
+CREATE INDEX "idx_holds_expires_at" ON "holds" ("expires_at");
+CREATE INDEX "idx_holds_status" ON "holds" ("status");
+CREATE UNIQUE INDEX "idx_holds_reference" ON "holds" ("reference");
@@ -0,0 +1,43 @@
+CREATE TABLE "scheduled_transfers" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "from_account_id" bigint NOT NULL,
+    "to_account_id" bigint,
+    "to" varchar(34),
+    "amount" bigint NOT NULL,
+    "currency" varchar(3) NOT NULL DEFAULT 'INR',
+    "description" text,
+    "schedule" varchar(100),
+    "start_at" timestamptz NOT NULL,
+    "end_at" timestamptz,
+    "max_occurrences" bigint NOT NULL DEFAULT 0,
+    "occurrences" bigint NOT NULL DEFAULT 0,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_run_at" timestamptz,
+    "last_run_at" timestamptz,
+    "last_error" text,
+    "status" varchar(20) NOT NULL DEFAULT 'active',
+    "version" bigint NOT NULL DEFAULT 1,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX "idx_scheduled_transfers_user_id" ON "scheduled_transfers" ("user_id");
+CREATE INDEX "idx_scheduled_transfers_status" ON "scheduled_transfers" ("status");
+CREATE INDEX "idx_scheduled_transfers_next_run_at" ON "scheduled_transfers" ("next_run_at");
+
+CREATE TABLE "scheduled_transfer_runs" (
+    "id" bigserial,
+    "scheduled_transfer_id" bigint NOT NULL,
+    "occurrence" bigint NOT NULL,
+    "attempt" bigint NOT NULL,
+    "due_at" timestamptz NOT NULL,
+    "status" varchar(20) NOT NULL,
+    "reference" text,
+    "error" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX "idx_scheduled_transfer_runs_attempt" ON "scheduled_transfer_runs" ("scheduled_transfer_id","occurrence","attempt");
@@ -0,0 +1,13 @@
+CREATE TABLE "beneficiaries" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "account_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "nickname" varchar(50),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_beneficiaries_account" FOREIGN KEY ("account_id") REFERENCES "accounts"("id")
+);
+
+CREATE UNIQUE INDEX "idx_beneficiaries_user_account" ON "beneficiaries" ("user_id","account_id");
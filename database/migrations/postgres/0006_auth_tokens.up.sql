@@ -0,0 +1,34 @@
+CREATE TABLE "refresh_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "family_id" varchar(36) NOT NULL,
+    "access_jti" varchar(36) NOT NULL,
+    "access_expires_at" timestamptz NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "revoked_at" timestamptz,
+    "replaced_by_id" bigint,
+    "ip_address" text,
+    "user_agent" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX "idx_refresh_tokens_token_hash" ON "refresh_tokens" ("token_hash");
+CREATE INDEX "idx_refresh_tokens_user_id" ON "refresh_tokens" ("user_id");
+CREATE INDEX "idx_refresh_tokens_access_jti" ON "refresh_tokens" ("access_jti");
+CREATE INDEX "idx_refresh_tokens_family_id" ON "refresh_tokens" ("family_id");
+
+CREATE TABLE "revoked_tokens" (
+    "id" bigserial,
+    "jti" varchar(36) NOT NULL,
+    "user_id" bigint NOT NULL,
+    "reason" varchar(100),
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX "idx_revoked_tokens_user_id" ON "revoked_tokens" ("user_id");
+CREATE UNIQUE INDEX "idx_revoked_tokens_jti" ON "revoked_tokens" ("jti");
+CREATE INDEX "idx_revoked_tokens_expires_at" ON "revoked_tokens" ("expires_at");
@@ -0,0 +1,16 @@
+ALTER TABLE "users" ADD "two_factor_enabled" boolean NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD "totp_secret" text;
+ALTER TABLE "users" ADD "totp_last_step" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "refresh_tokens" ADD "mfa" boolean NOT NULL DEFAULT false;
+
+CREATE TABLE "recovery_codes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "code_hash" varchar(64) NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX "idx_recovery_codes_code_hash" ON "recovery_codes" ("code_hash");
+CREATE INDEX "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
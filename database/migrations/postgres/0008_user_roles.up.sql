@@ -0,0 +1,6 @@
+-- Replaces the admin flag with a role. Admins become superadmins.
+
+ALTER TABLE "users" ADD "role" varchar(20) NOT NULL DEFAULT 'customer';
+CREATE INDEX "idx_users_role" ON "users" ("role");
+UPDATE users SET role = 'superadmin' WHERE is_admin = true;
+ALTER TABLE "users" DROP COLUMN "is_admin";
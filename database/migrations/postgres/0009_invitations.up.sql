@@ -0,0 +1,16 @@
+CREATE TABLE "invitations" (
+    "id" bigserial,
+    "email" varchar(255) NOT NULL,
+    "role" varchar(20) NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "invited_by_id" bigint NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "accepted_at" timestamptz,
+    "user_id" bigint,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX "idx_invitations_token_hash" ON "invitations" ("token_hash");
+CREATE INDEX "idx_invitations_email" ON "invitations" ("email");
@@ -0,0 +1,21 @@
+CREATE TABLE "approval_requests" (
+    "id" bigserial,
+    "operation" varchar(50) NOT NULL,
+    "payload" bytea NOT NULL,
+    "summary" varchar(255),
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "maker_id" bigint NOT NULL,
+    "checker_id" bigint,
+    "comment" text,
+    "result" bytea,
+    "error" text,
+    "expires_at" timestamptz NOT NULL,
+    "decided_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX "idx_approval_requests_maker_id" ON "approval_requests" ("maker_id");
+CREATE INDEX "idx_approval_requests_status" ON "approval_requests" ("status");
+CREATE INDEX "idx_approval_requests_operation" ON "approval_requests" ("operation");
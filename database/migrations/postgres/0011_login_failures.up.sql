@@ -0,0 +1,11 @@
+CREATE TABLE "login_failures" (
+    "id" bigserial,
+    "email" varchar(255) NOT NULL,
+    "failures" bigint NOT NULL DEFAULT 0,
+    "last_failed_at" timestamptz NOT NULL,
+    "locked_until" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX "idx_login_failures_last_failed_at" ON "login_failures" ("last_failed_at");
+CREATE UNIQUE INDEX "idx_login_failures_email" ON "login_failures" ("email");
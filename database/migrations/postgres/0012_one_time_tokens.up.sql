@@ -0,0 +1,14 @@
+CREATE TABLE "one_time_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "purpose" varchar(30) NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX "idx_one_time_tokens_expires_at" ON "one_time_tokens" ("expires_at");
+CREATE UNIQUE INDEX "idx_one_time_tokens_token_hash" ON "one_time_tokens" ("token_hash");
+CREATE INDEX "idx_one_time_tokens_user_id" ON "one_time_tokens" ("user_id");
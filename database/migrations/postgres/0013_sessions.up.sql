@@ -0,0 +1,20 @@
+-- Sessions for existing logins are created in Go; see migration_0013_sessions.go.
+
+CREATE TABLE "sessions" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "family_id" varchar(36) NOT NULL,
+    "device_name" varchar(100),
+    "user_agent" text,
+    "ip_address" varchar(45),
+    "mfa" boolean NOT NULL DEFAULT false,
+    "last_seen_at" timestamptz NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX "idx_sessions_expires_at" ON "sessions" ("expires_at");
+CREATE UNIQUE INDEX "idx_sessions_family_id" ON "sessions" ("family_id");
+CREATE INDEX "idx_sessions_user_id" ON "sessions" ("user_id");
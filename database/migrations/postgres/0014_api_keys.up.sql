@@ -0,0 +1,22 @@
+ALTER TABLE "users" ADD "service_account" boolean NOT NULL DEFAULT false;
+
+CREATE TABLE "api_keys" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "prefix" varchar(16) NOT NULL,
+    "secret_hash" varchar(64) NOT NULL,
+    "scopes" text NOT NULL,
+    "rate_limit" bigint NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "last_used_at" timestamptz,
+    "last_used_ip" varchar(45),
+    "revoked_at" timestamptz,
+    "created_by_id" bigint NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX "idx_api_keys_prefix" ON "api_keys" ("prefix");
+CREATE INDEX "idx_api_keys_user_id" ON "api_keys" ("user_id");
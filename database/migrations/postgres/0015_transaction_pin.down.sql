@@ -0,0 +1,3 @@
+ALTER TABLE "users" DROP COLUMN "step_up_locked_until";
+ALTER TABLE "users" DROP COLUMN "step_up_failures";
+ALTER TABLE "users" DROP COLUMN "transaction_pin";
@@ -0,0 +1,2 @@
+DROP INDEX "idx_kycs_key_id";
+ALTER TABLE "kycs" DROP COLUMN "key_id";
@@ -0,0 +1,2 @@
+ALTER TABLE "kycs" ADD "key_id" varchar(64) NOT NULL DEFAULT '';
+CREATE INDEX "idx_kycs_key_id" ON "kycs" ("key_id");
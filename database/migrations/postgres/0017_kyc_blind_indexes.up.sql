@@ -0,0 +1,9 @@
+-- The blind indexes are filled in and passport numbers encrypted in Go;
+-- see migration_0017_kyc_blind_indexes.go.
+
+ALTER TABLE "kycs" ADD "pan_index" varchar(64) NOT NULL DEFAULT '';
+CREATE INDEX "idx_kycs_pan_index" ON "kycs" ("pan_index");
+ALTER TABLE "kycs" ADD "aadhaar_index" varchar(64) NOT NULL DEFAULT '';
+CREATE INDEX "idx_kycs_aadhaar_index" ON "kycs" ("aadhaar_index");
+ALTER TABLE "kycs" ADD "passport_index" varchar(64) NOT NULL DEFAULT '';
+CREATE INDEX "idx_kycs_passport_index" ON "kycs" ("passport_index");
@@ -0,0 +1,12 @@
+CREATE TABLE "mfa_failures" (
+    "id" bigserial,
+    "challenge_id" varchar(36) NOT NULL,
+    "user_id" bigint NOT NULL,
+    "failures" bigint NOT NULL DEFAULT 0,
+    "expires_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX "idx_mfa_failures_expires_at" ON "mfa_failures" ("expires_at");
+CREATE INDEX "idx_mfa_failures_user_id" ON "mfa_failures" ("user_id");
+CREATE UNIQUE INDEX "idx_mfa_failures_challenge_id" ON "mfa_failures" ("challenge_id");
@@ -0,0 +1 @@
+-- The demoted users do not get their rights back.
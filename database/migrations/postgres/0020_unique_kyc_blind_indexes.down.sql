@@ -0,0 +1,6 @@
+DROP INDEX "idx_kycs_pan_index";
+CREATE INDEX "idx_kycs_pan_index" ON "kycs" ("pan_index");
+DROP INDEX "idx_kycs_aadhaar_index";
+CREATE INDEX "idx_kycs_aadhaar_index" ON "kycs" ("aadhaar_index");
+DROP INDEX "idx_kycs_passport_index";
+CREATE INDEX "idx_kycs_passport_index" ON "kycs" ("passport_index");
@@ -0,0 +1,9 @@
+DROP TABLE `idempotency_keys`;
+DROP TABLE `postings`;
+DROP TABLE `journal_entries`;
+DROP TABLE `ledger_accounts`;
+DROP TABLE `audit_logs`;
+DROP TABLE `transactions`;
+DROP TABLE `kycs`;
+DROP TABLE `accounts`;
+DROP TABLE `users`;
@@ -0,0 +1,169 @@
+-- Tables and indexes are only created when missing, because a database
+-- that predates versioned migrations already has some of them. Those
+-- databases are first brought up to this schema in Go; see
+-- migration_0001_initial_schema.go.
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `email` text NOT NULL,
+    `phone` text NOT NULL,
+    `password` text NOT NULL,
+    `first_name` text NOT NULL,
+    `last_name` text NOT NULL,
+    `is_active` numeric DEFAULT true,
+    `is_admin` numeric DEFAULT false,
+    `kyc_status` text DEFAULT 'pending',
+    `verified` numeric DEFAULT false,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+
+CREATE INDEX IF NOT EXISTS `idx_users_deleted_at` ON `users`(`deleted_at`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_phone` ON `users`(`phone`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_email` ON `users`(`email`);
+
+CREATE TABLE IF NOT EXISTS `accounts` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `account_number` text NOT NULL,
+    `type` text NOT NULL,
+    `nickname` text,
+    `currency` text NOT NULL DEFAULT 'INR',
+    `balance` integer NOT NULL DEFAULT 0,
+    `status` text NOT NULL DEFAULT 'active',
+    `version` integer NOT NULL DEFAULT 1,
+    `opened_at` datetime NOT NULL,
+    `closed_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `fk_users_accounts` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE INDEX IF NOT EXISTS `idx_accounts_deleted_at` ON `accounts`(`deleted_at`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_accounts_account_number` ON `accounts`(`account_number`);
+CREATE INDEX IF NOT EXISTS `idx_accounts_user_id` ON `accounts`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `kycs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `pan` text NOT NULL,
+    `aadhaar_number` text,
+    `passport_number` text,
+    `date_of_birth` datetime NOT NULL,
+    `address` text NOT NULL,
+    `city` text NOT NULL,
+    `state` text NOT NULL,
+    `pin_code` text NOT NULL,
+    `status` text DEFAULT 'pending',
+    `rejection_reason` text,
+    `verified_by` integer,
+    `verified_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `fk_kycs_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE INDEX IF NOT EXISTS `idx_kycs_deleted_at` ON `kycs`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `transactions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `type` text NOT NULL,
+    `amount` integer NOT NULL,
+    `currency` text NOT NULL DEFAULT 'INR',
+    `balance_before` integer NOT NULL,
+    `balance_after` integer NOT NULL,
+    `to_account_id` integer,
+    `from_account_id` integer,
+    `description` text,
+    `reference` text,
+    `journal_entry_id` integer,
+    `status` text DEFAULT 'completed',
+    `ip_address` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `fk_transactions_to_account` FOREIGN KEY (`to_account_id`) REFERENCES `accounts`(`id`),
+    CONSTRAINT `fk_transactions_from_account` FOREIGN KEY (`from_account_id`) REFERENCES `accounts`(`id`),
+    CONSTRAINT `fk_transactions_account` FOREIGN KEY (`account_id`) REFERENCES `accounts`(`id`)
+);
+
+CREATE INDEX IF NOT EXISTS `idx_transactions_deleted_at` ON `transactions`(`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_transactions_account_id` ON `transactions`(`account_id`);
+
+CREATE TABLE IF NOT EXISTS `audit_logs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer,
+    `action` text NOT NULL,
+    `resource` text NOT NULL,
+    `details` text,
+    `ip_address` text,
+    `user_agent` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `fk_audit_logs_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE INDEX IF NOT EXISTS `idx_audit_logs_deleted_at` ON `audit_logs`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `ledger_accounts` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `code` text NOT NULL,
+    `name` text NOT NULL,
+    `type` text NOT NULL,
+    `normal_balance` text NOT NULL,
+    `account_id` integer,
+    `currency` text NOT NULL DEFAULT 'INR',
+    `created_at` datetime,
+    `updated_at` datetime
+);
+
+CREATE INDEX IF NOT EXISTS `idx_ledger_accounts_account_id` ON `ledger_accounts`(`account_id`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_ledger_accounts_code` ON `ledger_accounts`(`code`);
+
+CREATE TABLE IF NOT EXISTS `journal_entries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `reference` text NOT NULL,
+    `type` text NOT NULL,
+    `description` text,
+    `posted_at` datetime NOT NULL,
+    `created_at` datetime
+);
+
+CREATE INDEX IF NOT EXISTS `idx_journal_entries_reference` ON `journal_entries`(`reference`);
+
+CREATE TABLE IF NOT EXISTS `postings` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `journal_entry_id` integer NOT NULL,
+    `ledger_account_id` integer NOT NULL,
+    `amount` integer NOT NULL,
+    `created_at` datetime,
+    CONSTRAINT `fk_postings_ledger_account` FOREIGN KEY (`ledger_account_id`) REFERENCES `ledger_accounts`(`id`),
+    CONSTRAINT `fk_journal_entries_postings` FOREIGN KEY (`journal_entry_id`) REFERENCES `journal_entries`(`id`)
+);
+
+CREATE INDEX IF NOT EXISTS `idx_postings_ledger_account_id` ON `postings`(`ledger_account_id`);
+CREATE INDEX IF NOT EXISTS `idx_postings_journal_entry_id` ON `postings`(`journal_entry_id`);
+
+CREATE TABLE IF NOT EXISTS `idempotency_keys` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `idempotency_key` text NOT NULL,
+    `method` text NOT NULL,
+    `path` text NOT NULL,
+    `request_hash` text NOT NULL,
+    `status` text NOT NULL DEFAULT 'in_progress',
+    `response_code` integer,
+    `response_body` text,
+    `content_type` text,
+    `expires_at` datetime NOT NULL,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_idempotency_user_key` ON `idempotency_keys`(`user_id`,`idempotency_key`);
+CREATE INDEX IF NOT EXISTS `idx_idempotency_keys_expires_at` ON `idempotency_keys`(`expires_at`);
@@ -0,0 +1,2 @@
+DROP INDEX `idx_transactions_reversal_of_id`;
+ALTER TABLE `transactions` DROP COLUMN `reversal_of_id`;
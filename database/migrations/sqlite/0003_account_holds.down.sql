@@ -0,0 +1,2 @@
+DROP TABLE `holds`;
+ALTER TABLE `accounts` DROP COLUMN `held_amount`;
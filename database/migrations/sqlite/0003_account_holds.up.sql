@@ -0,0 +1,24 @@
+ALTER TABLE `accounts` ADD `held_amount` integer NOT NULL DEFAULT 0;
+
+CREATE TABLE `holds` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `reference` text NOT NULL,
+    `amount` integer NOT NULL,
+    `captured_amount` integer NOT NULL DEFAULT 0,
+    `currency` text NOT NULL DEFAULT 'INR',
+    `description` text,
+    `status` text NOT NULL DEFAULT 'pending',
+    `transaction_id` integer,
+    `expires_at` datetime NOT NULL,
+    `captured_at` datetime,
+    `released_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_holds_account` FOREIGN KEY (`account_id`) REFERENCES `accounts`(`id`)
+);
+
+CREATE INDEX `idx_holds_expires_at` ON `holds`(`expires_at`);
+CREATE INDEX `idx_holds_status` ON `holds`(`status`);
+CREATE UNIQUE INDEX `idx_holds_reference` ON `holds`(`reference`);
+CREATE INDEX `idx_holds_account_id` ON `holds`(`account_id`);
@@ -0,0 +1,41 @@
+CREATE TABLE `scheduled_transfers` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `from_account_id` integer NOT NULL,
+    `to_account_id` integer,
+    `to` text,
+    `amount` integer NOT NULL,
+    `currency` text NOT NULL DEFAULT 'INR',
+    `description` text,
+    `schedule` text,
+    `start_at` datetime NOT NULL,
+    `end_at` datetime,
+    `max_occurrences` integer NOT NULL DEFAULT 0,
+    `occurrences` integer NOT NULL DEFAULT 0,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `next_run_at` datetime,
+    `last_run_at` datetime,
+    `last_error` text,
+    `status` text NOT NULL DEFAULT 'active',
+    `version` integer NOT NULL DEFAULT 1,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+
+CREATE INDEX `idx_scheduled_transfers_status` ON `scheduled_transfers`(`status`);
+CREATE INDEX `idx_scheduled_transfers_next_run_at` ON `scheduled_transfers`(`next_run_at`);
+CREATE INDEX `idx_scheduled_transfers_user_id` ON `scheduled_transfers`(`user_id`);
+
+CREATE TABLE `scheduled_transfer_runs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `scheduled_transfer_id` integer NOT NULL,
+    `occurrence` integer NOT NULL,
+    `attempt` integer NOT NULL,
+    `due_at` datetime NOT NULL,
+    `status` text NOT NULL,
+    `reference` text,
+    `error` text,
+    `created_at` datetime
+);
+
+CREATE UNIQUE INDEX `idx_scheduled_transfer_runs_attempt` ON `scheduled_transfer_runs`(`scheduled_transfer_id`,`occurrence`,`attempt`);
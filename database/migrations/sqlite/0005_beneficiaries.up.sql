@@ -0,0 +1,12 @@
+CREATE TABLE `beneficiaries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `account_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `nickname` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_beneficiaries_account` FOREIGN KEY (`account_id`) REFERENCES `accounts`(`id`)
+);
+
+CREATE UNIQUE INDEX `idx_beneficiaries_user_account` ON `beneficiaries`(`user_id`,`account_id`);
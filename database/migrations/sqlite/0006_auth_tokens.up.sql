@@ -0,0 +1,32 @@
+CREATE TABLE `refresh_tokens` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `token_hash` text NOT NULL,
+    `family_id` text NOT NULL,
+    `access_jti` text NOT NULL,
+    `access_expires_at` datetime NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `revoked_at` datetime,
+    `replaced_by_id` integer,
+    `ip_address` text,
+    `user_agent` text,
+    `created_at` datetime
+);
+
+CREATE INDEX `idx_refresh_tokens_access_jti` ON `refresh_tokens`(`access_jti`);
+CREATE INDEX `idx_refresh_tokens_family_id` ON `refresh_tokens`(`family_id`);
+CREATE UNIQUE INDEX `idx_refresh_tokens_token_hash` ON `refresh_tokens`(`token_hash`);
+CREATE INDEX `idx_refresh_tokens_user_id` ON `refresh_tokens`(`user_id`);
+
+CREATE TABLE `revoked_tokens` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `jti` text NOT NULL,
+    `user_id` integer NOT NULL,
+    `reason` text,
+    `expires_at` datetime NOT NULL,
+    `created_at` datetime
+);
+
+CREATE INDEX `idx_revoked_tokens_expires_at` ON `revoked_tokens`(`expires_at`);
+CREATE INDEX `idx_revoked_tokens_user_id` ON `revoked_tokens`(`user_id`);
+CREATE UNIQUE INDEX `idx_revoked_tokens_jti` ON `revoked_tokens`(`jti`);
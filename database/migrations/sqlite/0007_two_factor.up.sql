@@ -0,0 +1,15 @@
+ALTER TABLE `users` ADD `two_factor_enabled` numeric NOT NULL DEFAULT false;
+ALTER TABLE `users` ADD `totp_secret` text;
+ALTER TABLE `users` ADD `totp_last_step` integer NOT NULL DEFAULT 0;
+ALTER TABLE `refresh_tokens` ADD `mfa` numeric NOT NULL DEFAULT false;
+
+CREATE TABLE `recovery_codes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `code_hash` text NOT NULL,
+    `used_at` datetime,
+    `created_at` datetime
+);
+
+CREATE UNIQUE INDEX `idx_recovery_codes_code_hash` ON `recovery_codes`(`code_hash`);
+CREATE INDEX `idx_recovery_codes_user_id` ON `recovery_codes`(`user_id`);
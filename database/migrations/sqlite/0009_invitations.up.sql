@@ -0,0 +1,15 @@
+CREATE TABLE `invitations` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `email` text NOT NULL,
+    `role` text NOT NULL,
+    `token_hash` text NOT NULL,
+    `invited_by_id` integer NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `accepted_at` datetime,
+    `user_id` integer,
+    `revoked_at` datetime,
+    `created_at` datetime
+);
+
+CREATE UNIQUE INDEX `idx_invitations_token_hash` ON `invitations`(`token_hash`);
+CREATE INDEX `idx_invitations_email` ON `invitations`(`email`);
@@ -0,0 +1,20 @@
+CREATE TABLE `approval_requests` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `operation` text NOT NULL,
+    `payload` blob NOT NULL,
+    `summary` text,
+    `status` text NOT NULL DEFAULT 'pending',
+    `maker_id` integer NOT NULL,
+    `checker_id` integer,
+    `comment` text,
+    `result` blob,
+    `error` text,
+    `expires_at` datetime NOT NULL,
+    `decided_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+
+CREATE INDEX `idx_approval_requests_maker_id` ON `approval_requests`(`maker_id`);
+CREATE INDEX `idx_approval_requests_status` ON `approval_requests`(`status`);
+CREATE INDEX `idx_approval_requests_operation` ON `approval_requests`(`operation`);
@@ -0,0 +1,10 @@
+CREATE TABLE `login_failures` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `email` text NOT NULL,
+    `failures` integer NOT NULL DEFAULT 0,
+    `last_failed_at` datetime NOT NULL,
+    `locked_until` datetime
+);
+
+CREATE INDEX `idx_login_failures_last_failed_at` ON `login_failures`(`last_failed_at`);
+CREATE UNIQUE INDEX `idx_login_failures_email` ON `login_failures`(`email`);
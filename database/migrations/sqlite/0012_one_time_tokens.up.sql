@@ -0,0 +1,13 @@
+CREATE TABLE `one_time_tokens` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `purpose` text NOT NULL,
+    `token_hash` text NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `used_at` datetime,
+    `created_at` datetime
+);
+
+CREATE INDEX `idx_one_time_tokens_expires_at` ON `one_time_tokens`(`expires_at`);
+CREATE UNIQUE INDEX `idx_one_time_tokens_token_hash` ON `one_time_tokens`(`token_hash`);
+CREATE INDEX `idx_one_time_tokens_user_id` ON `one_time_tokens`(`user_id`);
@@ -0,0 +1,19 @@
+-- Sessions for existing logins are created in Go; see migration_0013_sessions.go.
+
+CREATE TABLE `sessions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `family_id` text NOT NULL,
+    `device_name` text,
+    `user_agent` text,
+    `ip_address` text,
+    `mfa` numeric NOT NULL DEFAULT false,
+    `last_seen_at` datetime NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `revoked_at` datetime,
+    `created_at` datetime
+);
+
+CREATE INDEX `idx_sessions_expires_at` ON `sessions`(`expires_at`);
+CREATE UNIQUE INDEX `idx_sessions_family_id` ON `sessions`(`family_id`);
+CREATE INDEX `idx_sessions_user_id` ON `sessions`(`user_id`);
@@ -0,0 +1,21 @@
+ALTER TABLE `users` ADD `service_account` numeric NOT NULL DEFAULT false;
+
+CREATE TABLE `api_keys` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `prefix` text NOT NULL,
+    `secret_hash` text NOT NULL,
+    `scopes` text NOT NULL,
+    `rate_limit` integer NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `last_used_at` datetime,
+    `last_used_ip` text,
+    `revoked_at` datetime,
+    `created_by_id` integer NOT NULL,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+
+CREATE UNIQUE INDEX `idx_api_keys_prefix` ON `api_keys`(`prefix`);
+CREATE INDEX `idx_api_keys_user_id` ON `api_keys`(`user_id`);
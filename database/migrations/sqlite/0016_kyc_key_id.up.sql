@@ -0,0 +1,2 @@
+ALTER TABLE `kycs` ADD `key_id` text NOT NULL DEFAULT '';
+CREATE INDEX `idx_kycs_key_id` ON `kycs`(`key_id`);
@@ -0,0 +1,8 @@
+-- Passport numbers are decrypted in Go before this runs.
+
+DROP INDEX `idx_kycs_pan_index`;
+DROP INDEX `idx_kycs_aadhaar_index`;
+DROP INDEX `idx_kycs_passport_index`;
+ALTER TABLE `kycs` DROP COLUMN `pan_index`;
+ALTER TABLE `kycs` DROP COLUMN `aadhaar_index`;
+ALTER TABLE `kycs` DROP COLUMN `passport_index`;
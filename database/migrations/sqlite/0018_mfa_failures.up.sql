@@ -0,0 +1,11 @@
+CREATE TABLE `mfa_failures` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `challenge_id` text NOT NULL,
+    `user_id` integer NOT NULL,
+    `failures` integer NOT NULL DEFAULT 0,
+    `expires_at` datetime NOT NULL
+);
+
+CREATE INDEX `idx_mfa_failures_expires_at` ON `mfa_failures`(`expires_at`);
+CREATE INDEX `idx_mfa_failures_user_id` ON `mfa_failures`(`user_id`);
+CREATE UNIQUE INDEX `idx_mfa_failures_challenge_id` ON `mfa_failures`(`challenge_id`);
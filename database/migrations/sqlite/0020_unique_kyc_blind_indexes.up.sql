@@ -0,0 +1,11 @@
+-- A document number can belong to only one KYC record. Records without a
+-- number keep an empty index, which the partial indexes leave out. This
+-- fails if two records already share a number; resolve those first with
+-- POST /api/admin/kyc/search.
+
+DROP INDEX `idx_kycs_pan_index`;
+CREATE UNIQUE INDEX `idx_kycs_pan_index` ON `kycs`(`pan_index`) WHERE `pan_index` <> '';
+DROP INDEX `idx_kycs_aadhaar_index`;
+CREATE UNIQUE INDEX `idx_kycs_aadhaar_index` ON `kycs`(`aadhaar_index`) WHERE `aadhaar_index` <> '';
+DROP INDEX `idx_kycs_passport_index`;
+CREATE UNIQUE INDEX `idx_kycs_passport_index` ON `kycs`(`passport_index`) WHERE `passport_index` <> '';
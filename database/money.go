@@ -0,0 +1,104 @@
+package database
+
+import (
+    "fmt"
+    "log"
+    "strings"
+
+    "minibank-go/money"
+
+    "gorm.io/gorm"
+)
+
+// legacyUserMoney and legacyTransactionMoney describe the money columns as
+// they were typed when the conversion shipped, independent of later model
+// changes.
+type legacyUserMoney struct {
+    Balance money.Amount `gorm:"default:0"`
+}
+
+func (legacyUserMoney) TableName() string { return "users" }
+
+type legacyTransactionMoney struct {
+    Amount        money.Amount `gorm:"not null"`
+    BalanceBefore money.Amount `gorm:"not null"`
+    BalanceAfter  money.Amount `gorm:"not null"`
+}
+
+func (legacyTransactionMoney) TableName() string { return "transactions" }
+
+// moneyColumns lists every column that held a float64 amount in major units
+// before the switch to money.Amount.
+var moneyColumns = []struct {
+    model   interface{}
+    table   string
+    columns []string
+}{
+    {&legacyUserMoney{}, "users", []string{"balance"}},
+    {&legacyTransactionMoney{}, "transactions", []string{"amount", "balance_before", "balance_after"}},
+}
+
+// convertMoneyColumns rewrites legacy REAL money columns as integer minor
+// units. Each column is scaled and retyped in the same transaction, so a
+// column that has already been converted is never scaled twice.
+func convertMoneyColumns(db *gorm.DB) error {
+    for _, mc := range moneyColumns {
+        if !db.Migrator().HasTable(mc.model) {
+            continue
+        }
+
+        columnTypes, err := db.Migrator().ColumnTypes(mc.model)
+        if err != nil {
+            return fmt.Errorf("failed to read columns of %s: %w", mc.table, err)
+        }
+
+        for _, ct := range columnTypes {
+            if !contains(mc.columns, ct.Name()) || !isFloatType(ct.DatabaseTypeName()) {
+                continue
+            }
+
+            column := ct.Name()
+            err := db.Transaction(func(tx *gorm.DB) error {
+                scale := fmt.Sprintf("UPDATE %s SET %s = CAST(ROUND(%s * %d) AS %s)",
+                    mc.table, column, column, money.Scale, integerCastType(tx))
+                if err := tx.Exec(scale).Error; err != nil {
+                    return err
+                }
+                return tx.Migrator().AlterColumn(mc.model, column)
+            })
+            if err != nil {
+                return fmt.Errorf("failed to convert %s.%s to minor units: %w", mc.table, column, err)
+            }
+            log.Printf("Converted %s.%s to integer minor units", mc.table, column)
+        }
+    }
+    return nil
+}
+
+// integerCastType is the CAST target for a 64-bit integer on each dialect
+func integerCastType(db *gorm.DB) string {
+    switch db.Dialector.Name() {
+    case "postgres":
+        return "BIGINT"
+    case "mysql":
+        return "SIGNED"
+    }
+    return "INTEGER"
+}
+
+func isFloatType(typeName string) bool {
+    switch strings.ToLower(typeName) {
+    case "real", "float", "double", "double precision", "numeric", "decimal":
+        return true
+    }
+    return false
+}
+
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/money"
+    "minibank-go/utils"
+
+    "github.com/gorilla/mux"
+    "gorm.io/gorm"
+)
+
+// ownAccountQuery scopes a query to one of the user's accounts. An accountID
+// of 0 selects the user's primary account, the oldest one still active.
+func ownAccountQuery(db *gorm.DB, userID, accountID uint) *gorm.DB {
+    query := db.Where("user_id = ?", userID)
+    if accountID != 0 {
+        return query.Where("id = ?", accountID)
+    }
+    return query.Where("status = ?", "active").Order("opened_at ASC, id ASC")
+}
+
+var errInvalidPayee = errors.New("payee must be an account number, email or phone number")
+
+// payeeQuery scopes a query to the account a transfer identifier refers to.
+// An account number selects that account; a registered email or phone
+// number selects the customer's primary account.
+func payeeQuery(db *gorm.DB, identifier string) (*gorm.DB, error) {
+    identifier = strings.TrimSpace(identifier)
+    if number := utils.NormalizeAccountNumber(identifier); utils.ValidateAccountNumber(number) {
+        return db.Where("account_number = ?", number), nil
+    }
+
+    var column string
+    switch {
+    case utils.ValidateEmail(identifier):
+        column = "email"
+    case utils.ValidatePhone(identifier):
+        column = "phone"
+    default:
+        return nil, errInvalidPayee
+    }
+
+    owner := db.Model(&models.User{}).Select("id").Where(column+" = ?", identifier)
+    return db.Where("user_id = (?)", owner).Where("status = ?", "active").Order("opened_at ASC, id ASC"), nil
+}
+
+// allowPayeeLookup spends one of the user's payee lookups. Every request
+// that resolves an email, phone or account number the user does not own
+// draws from the same budget, since each one tells whether it is registered.
+func (h *Handlers) allowPayeeLookup(userID uint) bool {
+    return h.payeeLookups.Allow(strconv.FormatUint(uint64(userID), 10))
+}
+
+// payeeName masks a payee's name to the initials of their first and last
+// names, such as R*** K., which is enough to confirm a payee the sender
+// already knows without revealing who holds an email or phone number
+func payeeName(user *models.User) string {
+    var name string
+    if firstName := []rune(user.FirstName); len(firstName) > 0 {
+        name = string(firstName[0]) + "***"
+    }
+    if lastName := []rune(user.LastName); len(lastName) > 0 {
+        name += " " + string(lastName[0]) + "."
+    }
+    return strings.TrimSpace(name)
+}
+
+// openAccount creates an account for the user with a freshly generated number
+func openAccount(db *gorm.DB, userID uint, accountType string, currency money.Currency, nickname string) (*models.Account, error) {
+    number, err := utils.GenerateAccountNumber()
+    if err != nil {
+        return nil, err
+    }
+
+    account := models.Account{
+        UserID:        userID,
+        AccountNumber: number,
+        Type:          accountType,
+        Nickname:      nickname,
+        Currency:      currency,
+        Status:        "active",
+        OpenedAt:      time.Now(),
+    }
+    if err := db.Create(&account).Error; err != nil {
+        return nil, fmt.Errorf("failed to create account: %w", err)
+    }
+    return &account, nil
+}
+
+func accountIDFromPath(r *http.Request) (uint, error) {
+    id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+    if err != nil {
+        return 0, err
+    }
+    return uint(id), nil
+}
+
+func (h *Handlers) ListAccounts(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var accounts []models.Account
+    if err := h.db.Where("user_id = ?", claims.UserID).
+        Order("opened_at ASC").
+        Find(&accounts).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to fetch accounts", err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(accounts)
+}
+
+func (h *Handlers) OpenAccount(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.OpenAccountRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    if req.Currency == "" {
+        req.Currency = money.DefaultCurrency
+    }
+    if !req.Currency.Valid() {
+        sendError(w, http.StatusBadRequest, "Unsupported currency", string(req.Currency))
+        return
+    }
+
+    account, err := openAccount(h.db, claims.UserID, req.Type, req.Currency, req.Nickname)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to open account", err.Error())
+        return
+    }
+
+    h.logAudit(&claims.UserID, "CREATE", "ACCOUNT",
+        fmt.Sprintf("Opened %s account %s", account.Type, account.AccountNumber), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(account)
+}
+
+func (h *Handlers) GetAccount(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    accountID, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid account ID", err.Error())
+        return
+    }
+
+    var account models.Account
+    if err := ownAccountQuery(h.db, claims.UserID, accountID).First(&account).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Account not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch account", err.Error())
+        }
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(account)
+}
+
+func (h *Handlers) UpdateAccount(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    accountID, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid account ID", err.Error())
+        return
+    }
+
+    var req models.UpdateAccountRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    var account models.Account
+    if err := ownAccountQuery(h.db, claims.UserID, accountID).First(&account).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Account not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch account", err.Error())
+        }
+        return
+    }
+
+    if err := h.db.Model(&account).Update("nickname", req.Nickname).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to update account", err.Error())
+        return
+    }
+
+    h.logAudit(&claims.UserID, "UPDATE", "ACCOUNT",
+        fmt.Sprintf("Updated account %s", account.AccountNumber), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(account)
+}
+
+// CloseAccount closes an account with a zero balance. The record is kept so
+// its transaction history stays intact.
+func (h *Handlers) CloseAccount(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    accountID, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid account ID", err.Error())
+        return
+    }
+
+    var account models.Account
+    if err := ownAccountQuery(h.db, claims.UserID, accountID).First(&account).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Account not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch account", err.Error())
+        }
+        return
+    }
+
+    if account.Status == "closed" {
+        sendError(w, http.StatusConflict, "Account is already closed", nil)
+        return
+    }
+
+    if !account.HeldAmount.IsZero() {
+        sendError(w, http.StatusConflict, "Account has pending holds", map[string]string{
+            "held_amount": account.HeldAmount.String(),
+        })
+        return
+    }
+
+    if !account.Balance.IsZero() {
+        sendError(w, http.StatusConflict, "Account balance must be zero before closing", map[string]string{
+            "balance": account.Balance.String(),
+        })
+        return
+    }
+
+    // Only close the account if no deposit landed since the balance check
+    now := time.Now()
+    result := h.db.Model(&models.Account{}).
+        Where("id = ? AND version = ?", account.ID, account.Version).
+        Updates(map[string]interface{}{
+            "status":    "closed",
+            "closed_at": &now,
+            "version":   gorm.Expr("version + 1"),
+        })
+    if result.Error != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to close account", result.Error.Error())
+        return
+    }
+    if result.RowsAffected == 0 {
+        sendError(w, http.StatusConflict, "Account was updated by another request, please retry", nil)
+        return
+    }
+    account.Status = "closed"
+    account.ClosedAt = &now
+
+    h.logAudit(&claims.UserID, "CLOSE", "ACCOUNT",
+        fmt.Sprintf("Closed account %s", account.AccountNumber), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "message": "Account closed",
+        "account": account,
+    })
+}
+
+// LookupPayee resolves a transfer identifier so the sender can confirm the
+// payee's name before sending money. Only a masked account number and a
+// masked name are returned, and each user gets PAYEE_LOOKUP_LIMIT lookups a
+// minute, so the endpoint cannot be used to list customers.
+func (h *Handlers) LookupPayee(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    if !h.allowPayeeLookup(claims.UserID) {
+        sendThrottled(w, "Too many payee lookups, try again later", time.Minute)
+        return
+    }
+
+    query, err := payeeQuery(h.db, r.URL.Query().Get("to"))
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid payee", err.Error())
+        return
+    }
+
+    var account models.Account
+    if err := query.Preload("User").First(&account).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Payee not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to look up payee", err.Error())
+        }
+        return
+    }
+
+    if account.Status != "active" || account.User == nil {
+        sendError(w, http.StatusNotFound, "Payee not found", nil)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(models.PayeeLookupResponse{
+        AccountNumber: utils.MaskAccountNumber(account.AccountNumber),
+        AccountType:   account.Type,
+        Name:          payeeName(account.User),
+        Currency:      string(account.Currency),
+    })
+}
@@ -2,13 +2,17 @@ package handlers
 
 import (
     "encoding/json"
+    "fmt"
     "net/http"
     "strconv"
+    "strings"
     "time"
 
     "minibank-go/middleware"
     "minibank-go/models"
     "minibank-go/utils"
+
+    "gorm.io/gorm"
 )
 
 func (h *Handlers) GetPendingKYC(w http.ResponseWriter, r *http.Request) {
@@ -24,6 +28,90 @@ func (h *Handlers) GetPendingKYC(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(kycRecords)
 }
 
+// GetKYCMatches lists the other customers whose KYC records share a document
+// number with a KYC record, such as the same PAN
+func (h *Handlers) GetKYCMatches(w http.ResponseWriter, r *http.Request) {
+    id, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid KYC ID", err.Error())
+        return
+    }
+
+    var kyc models.KYC
+    if err := h.db.First(&kyc, id).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "KYC record not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch KYC record", err.Error())
+        }
+        return
+    }
+
+    matches, err := identityMatches(h.db, &kyc, kyc.UserID)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to find matching KYC records", err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(matches)
+}
+
+// SearchKYCByDocument finds the customers whose KYC records hold any of the
+// given document numbers. The numbers are in the body rather than the URL so
+// they stay out of access logs, and each search is audited.
+func (h *Handlers) SearchKYCByDocument(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.KYCDocumentSearchRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    var probe models.KYC
+    if err := setKYCBlindIndexes(&probe, req.PAN, req.AadhaarNumber, req.PassportNumber); err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to index identity documents", err.Error())
+        return
+    }
+    if probe.PANIndex == "" && probe.AadhaarIndex == "" && probe.PassportIndex == "" {
+        sendError(w, http.StatusBadRequest, "Provide a pan, aadhaar_number or passport_number to search for", nil)
+        return
+    }
+    matches, err := identityMatches(h.db, &probe, 0)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to find matching KYC records", err.Error())
+        return
+    }
+
+    var searched []string
+    if probe.PANIndex != "" {
+        searched = append(searched, models.DocumentPAN)
+    }
+    if probe.AadhaarIndex != "" {
+        searched = append(searched, models.DocumentAadhaar)
+    }
+    if probe.PassportIndex != "" {
+        searched = append(searched, models.DocumentPassport)
+    }
+    h.logAudit(&claims.UserID, "SEARCH", "KYC",
+        fmt.Sprintf("Searched KYC records by %s, %d found", strings.Join(searched, ", "), len(matches)), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(matches)
+}
+
+// VerifyKYC submits a KYC decision. It takes effect once a second officer
+// approves it.
 func (h *Handlers) VerifyKYC(w http.ResponseWriter, r *http.Request) {
     claims := middleware.GetUserFromContext(r)
     if claims == nil {
@@ -43,75 +131,89 @@ func (h *Handlers) VerifyKYC(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // Begin transaction
-    tx := h.db.Begin()
-    defer func() {
-        if r := recover(); r != nil {
-            tx.Rollback()
-        }
-    }()
+    h.submitApproval(w, r, claims, opKYCVerify, req)
+}
 
-    // Validate KYC status
-    if req.Status != "verified" && req.Status != "rejected" {
-        tx.Rollback()
-        sendError(w, http.StatusBadRequest, "Invalid KYC status", "Status must be either 'verified' or 'rejected'")
-        return
+// describeKYCVerification checks a submitted KYC decision and summarises it
+// for the checker
+func (h *Handlers) describeKYCVerification(tx *gorm.DB, payload json.RawMessage) (string, error) {
+    var req models.KYCVerificationRequest
+    if err := json.Unmarshal(payload, &req); err != nil {
+        return "", rejectRequest(http.StatusBadRequest, "Invalid request body", err.Error())
     }
-
-    // Validate rejection reason for rejected status
-    if req.Status == "rejected" && req.RejectionReason == "" {
-        tx.Rollback()
-        sendError(w, http.StatusBadRequest, "Rejection reason is required", "Please provide a reason for rejection")
-        return
+    if err := checkKYCDecision(req); err != nil {
+        return "", err
     }
 
-    // Update KYC record
-    now := time.Now()
-    updateData := map[string]interface{}{
-        "status":       req.Status,
-        "verified_by":  claims.UserID,
-        "verified_at":  &now,
+    var kyc models.KYC
+    if err := tx.Preload("User").First(&kyc, req.KYCID).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return "", rejectRequest(http.StatusNotFound, "KYC record not found", nil)
+        }
+        return "", failRequest("Failed to fetch KYC record", err)
     }
 
+    summary := fmt.Sprintf("Mark KYC %d of %s as %s", kyc.ID, kyc.User.Email, req.Status)
     if req.Status == "rejected" {
-        updateData["rejection_reason"] = req.RejectionReason
+        summary += ": " + req.RejectionReason
     }
+    return summary, nil
+}
 
-    if err := tx.Model(&models.KYC{}).Where("id = ?", req.KYCID).Updates(updateData).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to update KYC record", err.Error())
-        return
+// executeKYCVerification records an approved KYC decision on the record and
+// its user. The maker is recorded as the verifying officer.
+func (h *Handlers) executeKYCVerification(tx *gorm.DB, approval *models.ApprovalRequest) (interface{}, string, error) {
+    var req models.KYCVerificationRequest
+    if err := json.Unmarshal(approval.Payload, &req); err != nil {
+        return nil, "", failRequest("Invalid approval payload", err)
+    }
+    if err := checkKYCDecision(req); err != nil {
+        return nil, "", err
     }
 
-    // Update user KYC status
     var kyc models.KYC
     if err := tx.First(&kyc, req.KYCID).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusNotFound, "KYC record not found", err.Error())
-        return
+        if err == gorm.ErrRecordNotFound {
+            return nil, "", rejectRequest(http.StatusNotFound, "KYC record not found", nil)
+        }
+        return nil, "", failRequest("Failed to fetch KYC record", err)
     }
 
-    if err := tx.Model(&models.User{}).Where("id = ?", kyc.UserID).Update("kyc_status", req.Status).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to update user KYC status", err.Error())
-        return
+    now := time.Now()
+    updateData := map[string]interface{}{
+        "status":      req.Status,
+        "verified_by": approval.MakerID,
+        "verified_at": &now,
     }
 
-    if err := tx.Commit().Error; err != nil {
-        sendError(w, http.StatusInternalServerError, "Failed to commit transaction", err.Error())
-        return
+    if req.Status == "rejected" {
+        updateData["rejection_reason"] = req.RejectionReason
     }
 
-    h.logAudit(&claims.UserID, "UPDATE", "KYC", 
-        "KYC verification: "+req.Status, r.RemoteAddr, r.UserAgent())
+    if err := tx.Model(&kyc).Updates(updateData).Error; err != nil {
+        return nil, "", failRequest("Failed to update KYC record", err)
+    }
 
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]interface{}{
-        "message": "KYC verification updated successfully",
+    if err := tx.Model(&models.User{}).Where("id = ?", kyc.UserID).Update("kyc_status", req.Status).Error; err != nil {
+        return nil, "", failRequest("Failed to update user KYC status", err)
+    }
+
+    result := map[string]interface{}{
         "status":  req.Status,
-        "kyc_id":  req.KYCID,
+        "kyc_id":  kyc.ID,
         "user_id": kyc.UserID,
-    })
+    }
+    return result, "KYC verification: " + req.Status, nil
+}
+
+func checkKYCDecision(req models.KYCVerificationRequest) error {
+    if req.Status != "verified" && req.Status != "rejected" {
+        return rejectRequest(http.StatusBadRequest, "Invalid KYC status", "Status must be either 'verified' or 'rejected'")
+    }
+    if req.Status == "rejected" && req.RejectionReason == "" {
+        return rejectRequest(http.StatusBadRequest, "Rejection reason is required", "Please provide a reason for rejection")
+    }
+    return nil
 }
 
 func (h *Handlers) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
@@ -188,7 +290,8 @@ func (h *Handlers) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 
 	var users []models.User
 	// The Select statement already omits the password, which is good.
-	if err := h.db.Select("id, email, phone, first_name, last_name, balance, is_active, kyc_status, created_at, updated_at, is_admin").
+	if err := h.db.Select("id, email, phone, first_name, last_name, is_active, kyc_status, created_at, updated_at, role").
+		Preload("Accounts").
 		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -204,4 +307,138 @@ func (h *Handlers) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		"limit": limit,
 		"total": total,
 	})
-}
\ No newline at end of file
+}
+// UpdateUserAccess activates or deactivates a user. Any change signs the user
+// out everywhere, so their existing tokens cannot keep the old access.
+func (h *Handlers) UpdateUserAccess(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    userID, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid user ID", err.Error())
+        return
+    }
+    if userID == claims.UserID {
+        sendError(w, http.StatusBadRequest, "You cannot change your own access", nil)
+        return
+    }
+
+    var req models.UpdateUserAccessRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    var user models.User
+    if err := h.db.First(&user, userID).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "User not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch user", err.Error())
+        }
+        return
+    }
+
+    updates := map[string]interface{}{}
+    if req.IsActive != nil && *req.IsActive != user.IsActive {
+        updates["is_active"] = *req.IsActive
+    }
+
+    if len(updates) > 0 {
+        // The user's BeforeUpdate hook revokes their tokens in the same transaction
+        if err := h.db.Transaction(func(tx *gorm.DB) error {
+            return tx.Model(&user).Updates(updates).Error
+        }); err != nil {
+            sendError(w, http.StatusInternalServerError, "Failed to update user", err.Error())
+            return
+        }
+
+        h.logAudit(&claims.UserID, "UPDATE", "USER",
+            fmt.Sprintf("Changed access of user %d (%s): active=%t", user.ID, user.Email, user.IsActive), r.RemoteAddr, r.UserAgent())
+    }
+
+    user.Password = ""
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "message": "User access updated",
+        "user":    user,
+    })
+}
+
+// ListRoles returns every role and the permissions it grants
+func (h *Handlers) ListRoles(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(models.Roles())
+}
+
+// AssignRole changes a user's role. The user is signed out everywhere so
+// their next token carries the new role's permissions.
+func (h *Handlers) AssignRole(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    userID, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid user ID", err.Error())
+        return
+    }
+    if userID == claims.UserID {
+        sendError(w, http.StatusBadRequest, "You cannot change your own role", nil)
+        return
+    }
+
+    var req models.AssignRoleRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+    if !models.ValidRole(req.Role) {
+        sendError(w, http.StatusBadRequest, "Unknown role", req.Role)
+        return
+    }
+
+    var user models.User
+    if err := h.db.First(&user, userID).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "User not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch user", err.Error())
+        }
+        return
+    }
+
+    if user.Role != req.Role {
+        previous := user.Role
+
+        // The user's BeforeUpdate hook revokes their tokens in the same transaction
+        if err := h.db.Transaction(func(tx *gorm.DB) error {
+            return tx.Model(&user).Update("role", req.Role).Error
+        }); err != nil {
+            sendError(w, http.StatusInternalServerError, "Failed to assign role", err.Error())
+            return
+        }
+
+        h.logAudit(&claims.UserID, "ASSIGN_ROLE", "USER",
+            fmt.Sprintf("Changed role of user %d (%s) from %s to %s", user.ID, user.Email, previous, user.Role), r.RemoteAddr, r.UserAgent())
+    }
+
+    user.Password = ""
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "message":     "Role assigned",
+        "user":        user,
+        "permissions": models.RolePermissions(user.Role),
+    })
+}
@@ -0,0 +1,493 @@
+package handlers
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// Operations that need a second staff member's approval
+const (
+    opKYCVerify          = "kyc.verify"
+    opTransactionReverse = "transaction.reverse"
+)
+
+// approvalOperation is a sensitive admin operation that runs only once a
+// checker other than its maker approves it
+type approvalOperation struct {
+    permission string // required of both the maker and the checker
+    action     string // audit action and resource logged when it executes
+    resource   string
+    // describe validates a submitted payload and summarises it for the checker
+    describe func(h *Handlers, tx *gorm.DB, payload json.RawMessage) (string, error)
+    // execute performs the operation inside the approving transaction and
+    // returns its result and the audit details
+    execute func(h *Handlers, tx *gorm.DB, approval *models.ApprovalRequest) (interface{}, string, error)
+}
+
+var approvalOperations = map[string]approvalOperation{
+    opKYCVerify: {
+        permission: models.PermKYCVerify,
+        action:     "UPDATE",
+        resource:   "KYC",
+        describe:   (*Handlers).describeKYCVerification,
+        execute:    (*Handlers).executeKYCVerification,
+    },
+    opTransactionReverse: {
+        permission: models.PermTransactionsReverse,
+        action:     "REVERSE",
+        resource:   "TRANSACTION",
+        describe:   (*Handlers).describeReversal,
+        execute:    (*Handlers).executeReversal,
+    },
+}
+
+// errNotPending is returned when a request was decided, cancelled or expired
+// before this decision could be recorded
+var errNotPending = rejectRequest(http.StatusConflict, "Approval request is no longer pending", nil)
+
+// maxSummaryLength is the size of approval_requests.summary. Summaries
+// quote free text such as reasons, so they are cut to fit.
+const maxSummaryLength = 255
+
+// truncateRunes shortens s to at most n characters, ending in an ellipsis
+// when anything was cut
+func truncateRunes(s string, n int) string {
+    runes := []rune(s)
+    if len(runes) <= n {
+        return s
+    }
+    return string(runes[:n-1]) + "…"
+}
+
+// submitApproval validates an operation and queues it for a checker
+func (h *Handlers) submitApproval(w http.ResponseWriter, r *http.Request, claims *utils.Claims, operation string, req interface{}) {
+    op := approvalOperations[operation]
+
+    payload, err := json.Marshal(req)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to encode request", err.Error())
+        return
+    }
+
+    summary, err := op.describe(h, h.db, payload)
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+    summary = truncateRunes(summary, maxSummaryLength)
+
+    approval := models.ApprovalRequest{
+        Operation: operation,
+        Payload:   payload,
+        Summary:   summary,
+        Status:    "pending",
+        MakerID:   claims.UserID,
+        ExpiresAt: time.Now().Add(h.config.ApprovalTTL),
+    }
+    if err := h.db.Create(&approval).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to submit request for approval", err.Error())
+        return
+    }
+
+    h.logAudit(&claims.UserID, "SUBMIT", "APPROVAL",
+        fmt.Sprintf("Submitted %s request %d: %s", operation, approval.ID, summary), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusAccepted)
+    json.NewEncoder(w).Encode(models.ApprovalResponse{
+        Message:  "Submitted for approval",
+        Approval: approval,
+    })
+}
+
+// checkableOperations lists the operations the caller may approve
+func checkableOperations(claims *utils.Claims) []string {
+    var operations []string
+    for name, op := range approvalOperations {
+        if claims.HasPermission(op.permission) {
+            operations = append(operations, name)
+        }
+    }
+    return operations
+}
+
+// ListApprovals returns the approval requests for operations the caller is
+// allowed to check, newest first. Filter with ?status= and ?operation=.
+func (h *Handlers) ListApprovals(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    operations := checkableOperations(claims)
+    if len(operations) == 0 {
+        sendError(w, http.StatusForbidden, "Permission denied", nil)
+        return
+    }
+
+    page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+    if page <= 0 {
+        page = 1
+    }
+    limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+    if limit <= 0 || limit > 100 {
+        limit = 50
+    }
+    offset := (page - 1) * limit
+
+    query := h.db.Model(&models.ApprovalRequest{}).Where("operation IN ?", operations)
+    if status := r.URL.Query().Get("status"); status != "" {
+        query = query.Where("status = ?", status)
+    }
+    if operation := r.URL.Query().Get("operation"); operation != "" {
+        query = query.Where("operation = ?", operation)
+    }
+
+    var total int64
+    if err := query.Count(&total).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to fetch approval requests", err.Error())
+        return
+    }
+
+    var approvals []models.ApprovalRequest
+    if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&approvals).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to fetch approval requests", err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "approvals": approvals,
+        "page":      page,
+        "limit":     limit,
+        "total":     total,
+    })
+}
+
+// loadApproval fetches the approval request named in the path, provided the
+// caller holds the permission its operation needs. It writes the error
+// response and returns false otherwise.
+func (h *Handlers) loadApproval(w http.ResponseWriter, r *http.Request, claims *utils.Claims, approval *models.ApprovalRequest) (approvalOperation, bool) {
+    id, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid approval request ID", err.Error())
+        return approvalOperation{}, false
+    }
+
+    if err := h.db.First(approval, id).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Approval request not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch approval request", err.Error())
+        }
+        return approvalOperation{}, false
+    }
+
+    op, ok := approvalOperations[approval.Operation]
+    if !ok || !claims.HasPermission(op.permission) {
+        sendError(w, http.StatusForbidden, "Permission denied", nil)
+        return approvalOperation{}, false
+    }
+    return op, true
+}
+
+// GetApproval returns a single approval request
+func (h *Handlers) GetApproval(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var approval models.ApprovalRequest
+    if _, ok := h.loadApproval(w, r, claims, &approval); !ok {
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(approval)
+}
+
+// ApproveRequest signs off on a pending request and executes its operation
+// in the same database transaction. The maker cannot approve their own
+// request, and API keys cannot approve at all. If the operation fails the
+// request is marked failed with the reason, and has to be submitted again.
+func (h *Handlers) ApproveRequest(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.ApprovalDecisionRequest
+    if r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+            return
+        }
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    var approval models.ApprovalRequest
+    op, ok := h.loadApproval(w, r, claims, &approval)
+    if !ok {
+        return
+    }
+    if approval.MakerID == claims.UserID {
+        sendError(w, http.StatusForbidden, "A request cannot be approved by the user who submitted it", nil)
+        return
+    }
+    if claims.APIKeyID != 0 {
+        sendError(w, http.StatusForbidden, "Requests must be approved by a person, not with an API key", nil)
+        return
+    }
+
+    var details string
+    err := retryOnConflict(func() error {
+        return h.db.Transaction(func(tx *gorm.DB) error {
+            // Claim the request, unless it was decided or expired meanwhile
+            now := time.Now()
+            result := tx.Model(&models.ApprovalRequest{}).
+                Where("id = ? AND status = ? AND expires_at > ?", approval.ID, "pending", now).
+                Updates(map[string]interface{}{
+                    "status":     "executed",
+                    "checker_id": claims.UserID,
+                    "comment":    req.Comment,
+                    "decided_at": &now,
+                })
+            if result.Error != nil {
+                return failRequest("Failed to approve request", result.Error)
+            }
+            if result.RowsAffected == 0 {
+                return errNotPending
+            }
+
+            output, auditDetails, err := op.execute(h, tx, &approval)
+            if err != nil {
+                return err
+            }
+            details = auditDetails
+
+            encoded, err := json.Marshal(output)
+            if err != nil {
+                return failRequest("Failed to encode result", err)
+            }
+            if err := tx.Model(&models.ApprovalRequest{}).Where("id = ?", approval.ID).
+                Update("result", json.RawMessage(encoded)).Error; err != nil {
+                return failRequest("Failed to record result", err)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        // A failed operation is final; a contended or already decided one is not ours to mark
+        if !errors.Is(err, errNotPending) && !isConflict(err) {
+            h.failApproval(&approval, claims.UserID, req.Comment, err)
+            h.logAudit(&claims.UserID, "APPROVAL_FAILED", "APPROVAL",
+                fmt.Sprintf("Approved %s request %d but it failed: %v", approval.Operation, approval.ID, err), r.RemoteAddr, r.UserAgent())
+        }
+        sendRequestError(w, err)
+        return
+    }
+
+    h.logAudit(&claims.UserID, "APPROVE", "APPROVAL",
+        fmt.Sprintf("Approved %s request %d submitted by user %d", approval.Operation, approval.ID, approval.MakerID), r.RemoteAddr, r.UserAgent())
+    h.logAudit(&claims.UserID, op.action, op.resource,
+        fmt.Sprintf("%s (approval request %d)", details, approval.ID), r.RemoteAddr, r.UserAgent())
+
+    h.db.First(&approval, approval.ID)
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(models.ApprovalResponse{
+        Message:  "Request approved and executed",
+        Approval: approval,
+    })
+}
+
+// failApproval records why an approved request could not be executed
+func (h *Handlers) failApproval(approval *models.ApprovalRequest, checkerID uint, comment string, cause error) {
+    message := cause.Error()
+    var reqErr *requestError
+    if errors.As(cause, &reqErr) {
+        message = reqErr.message
+    }
+
+    now := time.Now()
+    err := h.db.Model(&models.ApprovalRequest{}).
+        Where("id = ? AND status = ?", approval.ID, "pending").
+        Updates(map[string]interface{}{
+            "status":     "failed",
+            "checker_id": checkerID,
+            "comment":    comment,
+            "error":      message,
+            "decided_at": &now,
+        }).Error
+    if err != nil {
+        log.Printf("Failed to mark approval request %d failed: %v", approval.ID, err)
+    }
+}
+
+// RejectRequest turns down a pending request; its operation never runs. A
+// comment explaining why is required.
+func (h *Handlers) RejectRequest(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.RejectApprovalRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    var approval models.ApprovalRequest
+    if _, ok := h.loadApproval(w, r, claims, &approval); !ok {
+        return
+    }
+    if approval.MakerID == claims.UserID {
+        sendError(w, http.StatusForbidden, "A request cannot be rejected by the user who submitted it; cancel it instead", nil)
+        return
+    }
+    if claims.APIKeyID != 0 {
+        sendError(w, http.StatusForbidden, "Requests must be rejected by a person, not with an API key", nil)
+        return
+    }
+
+    now := time.Now()
+    result := h.db.Model(&models.ApprovalRequest{}).
+        Where("id = ? AND status = ? AND expires_at > ?", approval.ID, "pending", now).
+        Updates(map[string]interface{}{
+            "status":     "rejected",
+            "checker_id": claims.UserID,
+            "comment":    req.Comment,
+            "decided_at": &now,
+        })
+    if result.Error != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to reject request", result.Error.Error())
+        return
+    }
+    if result.RowsAffected == 0 {
+        sendRequestError(w, errNotPending)
+        return
+    }
+
+    h.logAudit(&claims.UserID, "REJECT", "APPROVAL",
+        fmt.Sprintf("Rejected %s request %d submitted by user %d: %s", approval.Operation, approval.ID, approval.MakerID, req.Comment), r.RemoteAddr, r.UserAgent())
+
+    h.db.First(&approval, approval.ID)
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(models.ApprovalResponse{
+        Message:  "Request rejected",
+        Approval: approval,
+    })
+}
+
+// CancelApproval withdraws a pending request. Only its maker can cancel it.
+func (h *Handlers) CancelApproval(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var approval models.ApprovalRequest
+    if _, ok := h.loadApproval(w, r, claims, &approval); !ok {
+        return
+    }
+    if approval.MakerID != claims.UserID {
+        sendError(w, http.StatusForbidden, "Only the user who submitted a request can cancel it", nil)
+        return
+    }
+
+    now := time.Now()
+    result := h.db.Model(&models.ApprovalRequest{}).
+        Where("id = ? AND status = ?", approval.ID, "pending").
+        Updates(map[string]interface{}{
+            "status":     "cancelled",
+            "decided_at": &now,
+        })
+    if result.Error != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to cancel request", result.Error.Error())
+        return
+    }
+    if result.RowsAffected == 0 {
+        sendRequestError(w, errNotPending)
+        return
+    }
+
+    h.logAudit(&claims.UserID, "CANCEL", "APPROVAL",
+        fmt.Sprintf("Cancelled %s request %d", approval.Operation, approval.ID), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Request cancelled",
+    })
+}
+
+// ExpireApprovals marks pending requests past their expiry as expired and
+// returns how many were expired
+func (h *Handlers) ExpireApprovals() (int, error) {
+    var stale []models.ApprovalRequest
+    if err := h.db.Where("status = ? AND expires_at <= ?", "pending", time.Now()).
+        Order("id ASC").Limit(100).Find(&stale).Error; err != nil {
+        return 0, fmt.Errorf("failed to find expired approval requests: %w", err)
+    }
+
+    expired := 0
+    for _, approval := range stale {
+        result := h.db.Model(&models.ApprovalRequest{}).
+            Where("id = ? AND status = ?", approval.ID, "pending").
+            Update("status", "expired")
+        if result.Error != nil {
+            return expired, fmt.Errorf("failed to expire approval request %d: %w", approval.ID, result.Error)
+        }
+        // Decided while we were looking
+        if result.RowsAffected == 0 {
+            continue
+        }
+
+        h.logAudit(&approval.MakerID, "EXPIRE", "APPROVAL",
+            fmt.Sprintf("%s request %d expired without a decision", approval.Operation, approval.ID), "", "approvals")
+        expired++
+    }
+    return expired, nil
+}
+
+// RunApprovalExpiry expires undecided approval requests every interval. It
+// never returns.
+func (h *Handlers) RunApprovalExpiry(interval time.Duration) {
+    for {
+        time.Sleep(interval)
+        expired, err := h.ExpireApprovals()
+        if err != nil {
+            log.Printf("Approval expiry failed: %v", err)
+        }
+        if expired > 0 {
+            log.Printf("Expired %d approval requests", expired)
+        }
+    }
+}
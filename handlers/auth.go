@@ -2,14 +2,17 @@ package handlers
 
 import (
     "encoding/json"
+    "fmt"
     "log"
     "net/http"
-    "strings"
+    "time"
 
     "minibank-go/middleware"
     "minibank-go/models"
+    "minibank-go/money"
     "minibank-go/utils"
 
+    "github.com/google/uuid"
     "gorm.io/gorm"
 )
 
@@ -44,74 +47,61 @@ func (h *Handlers) Register(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // Determine if user should be admin
-    isAdmin := false
-    adminReason := ""
-
-    // Method 1: Admin code provided
-    if req.AdminCode != "" {
-        if req.AdminCode == h.config.AdminCode {
-            isAdmin = true
-            adminReason = "Admin code provided"
-            log.Printf("Admin user registered with admin code: %s", req.Email)
-        } else {
-            log.Printf("Invalid admin code provided for %s", req.Email)
-            http.Error(w, "Invalid admin code", http.StatusBadRequest)
-            return
-        }
-    }
-
-    // Method 2: Admin email pattern (as fallback)
-    if !isAdmin && strings.Contains(strings.ToLower(req.Email), "admin@") {
-        isAdmin = true
-        adminReason = "Admin email pattern detected"
-        log.Printf("Admin user registered with admin email pattern: %s", req.Email)
-    }
-
-    // Create user
+    // Create user. Staff are created with `minibank admin create` or by
+    // invitation, never through public registration.
     user := models.User{
         Email:     req.Email,
         Phone:     req.Phone,
         Password:  hashedPassword,
         FirstName: req.FirstName,
         LastName:  req.LastName,
-        Balance:   0,
         IsActive:  true,
-        IsAdmin:   isAdmin,
+        Role:      models.RoleCustomer,
         KYCStatus: "pending",
     }
 
-    if err := h.db.Create(&user).Error; err != nil {
+    var verificationToken string
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        if err := CreateUser(tx, &user); err != nil {
+            return err
+        }
+        verificationToken, err = issueOneTimeToken(tx, user.ID, models.PurposeEmailVerification, h.config.VerificationTTL)
+        return err
+    })
+    if err != nil {
         log.Printf("Failed to create user %s: %v", req.Email, err)
         http.Error(w, "Failed to create user", http.StatusInternalServerError)
         return
     }
+    h.sendVerificationEmail(&user, verificationToken)
 
-    log.Printf("User created successfully: ID=%d, Email=%s, IsAdmin=%v", user.ID, user.Email, user.IsAdmin)
+    log.Printf("User created successfully: ID=%d, Email=%s, Role=%s", user.ID, user.Email, user.Role)
 
-    // Log audit with admin status
-    auditDetails := "User registered"
-    if isAdmin {
-        auditDetails = "Admin user registered - " + adminReason
-    }
-    h.logAudit(&user.ID, "CREATE", "USER", auditDetails, r.RemoteAddr, r.UserAgent())
+    h.logAudit(&user.ID, "CREATE", "USER", "User registered", r.RemoteAddr, r.UserAgent())
 
     // Remove password from response
     user.Password = ""
 
-    response := map[string]interface{}{
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(map[string]interface{}{
         "message": "User registered successfully",
         "user":    user,
-    }
+    })
+}
 
-    if isAdmin {
-        response["admin_status"] = "Admin privileges granted"
-        response["admin_reason"] = adminReason
+// CreateUser stores a new user together with the savings account in the
+// default currency that every user starts with. tx should be a transaction.
+func CreateUser(tx *gorm.DB, user *models.User) error {
+    if err := tx.Create(user).Error; err != nil {
+        return err
     }
-
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(response)
+    account, err := openAccount(tx, user.ID, "savings", money.DefaultCurrency, "")
+    if err != nil {
+        return err
+    }
+    user.Accounts = []models.Account{*account}
+    return nil
 }
 
 func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
@@ -131,26 +121,72 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    // Throttle attempts from one address and against one email
+    key := loginKey(req.Email)
+    if !h.loginIPs.Allow(middleware.ClientIP(r)) || !h.loginEmails.Allow(key) {
+        sendThrottled(w, "Too many login attempts, try again later", time.Minute)
+        return
+    }
+
+    // Wait out earlier failures; unknown emails are treated the same
+    wait, err := h.loginWait(key)
+    if err != nil {
+        log.Printf("Database error during login for %s: %v", req.Email, err)
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if wait > 0 {
+        sendThrottled(w, "Too many failed login attempts, try again later", wait)
+        return
+    }
+
     // Find user
     var user models.User
+    found := true
     if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-        if err == gorm.ErrRecordNotFound {
-            log.Printf("Login attempt with non-existent email: %s", req.Email)
-            http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+        if err != gorm.ErrRecordNotFound {
+            log.Printf("Database error during login for %s: %v", req.Email, err)
+            http.Error(w, "Database error", http.StatusInternalServerError)
             return
         }
-        log.Printf("Database error during login for %s: %v", req.Email, err)
-        http.Error(w, "Database error", http.StatusInternalServerError)
-        return
+        // Spend as long as a real password check would
+        log.Printf("Login attempt with non-existent email: %s", req.Email)
+        user.Password = dummyPasswordHash
+        found = false
+    } else if user.ServiceAccount {
+        // Service accounts have no password and only use API keys
+        log.Printf("Login attempt for service account: %s", req.Email)
+        user.Password = dummyPasswordHash
+        found = false
     }
 
     // Check password
-    if !utils.CheckPasswordHash(req.Password, user.Password) {
-        log.Printf("Invalid password for user: %s", req.Email)
+    if !utils.CheckPasswordHash(req.Password, user.Password) || !found {
+        lockedUntil, err := h.recordLoginFailure(key)
+        if err != nil {
+            log.Printf("Failed to record failed login for %s: %v", req.Email, err)
+        }
+        if found {
+            log.Printf("Invalid password for user: %s", req.Email)
+            h.logAudit(&user.ID, "LOGIN_FAILED", "AUTH", "Invalid password", r.RemoteAddr, r.UserAgent())
+            if lockedUntil != nil {
+                h.notifyLockout(&user, *lockedUntil, r)
+            }
+        } else {
+            h.logAudit(nil, "LOGIN_FAILED", "AUTH", "Unknown email", r.RemoteAddr, r.UserAgent())
+        }
         http.Error(w, "Invalid credentials", http.StatusUnauthorized)
         return
     }
 
+    // With two-factor authentication the login is not over until the second
+    // factor is given, so failures are cleared by VerifyMFA instead
+    if !user.TwoFactorEnabled {
+        if _, err := h.clearLoginFailures(key); err != nil {
+            log.Printf("Failed to clear failed logins for %s: %v", req.Email, err)
+        }
+    }
+
     // Check if user is active
     if !user.IsActive {
         log.Printf("Login attempt for inactive user: %s", req.Email)
@@ -158,10 +194,39 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    log.Printf("User login: ID=%d, Email=%s, IsAdmin=%v", user.ID, user.Email, user.IsAdmin)
+    // With two-factor authentication the password only earns a challenge,
+    // exchanged for tokens at /api/auth/2fa/verify
+    if user.TwoFactorEnabled {
+        challenge, _, err := utils.GenerateChallengeToken(user.ID, user.Email, h.config.TwoFactor.ChallengeTTL)
+        if err != nil {
+            log.Printf("Failed to generate MFA challenge for user %s: %v", req.Email, err)
+            http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+            return
+        }
 
-    // Generate token with admin status
-    token, err := utils.GenerateToken(user.ID, user.Email, user.IsAdmin)
+        h.logAudit(&user.ID, "LOGIN_CHALLENGE", "AUTH", "Password accepted, awaiting second factor", r.RemoteAddr, r.UserAgent())
+
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(models.MFAChallengeResponse{
+            MFARequired: true,
+            MFAToken:    challenge,
+            ExpiresIn:   int(h.config.TwoFactor.ChallengeTTL.Seconds()),
+        })
+        return
+    }
+
+    log.Printf("User login: ID=%d, Email=%s, Role=%s", user.ID, user.Email, user.Role)
+
+    // Generate tokens with the user's role in a new session
+    var tokens *models.TokenResponse
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        session, err := h.startSession(tx, &user, req.DeviceName, false, r)
+        if err != nil {
+            return err
+        }
+        tokens, _, err = h.issueTokens(tx, &user, session, r)
+        return err
+    })
     if err != nil {
         log.Printf("Failed to generate token for user %s: %v", req.Email, err)
         http.Error(w, "Failed to generate token", http.StatusInternalServerError)
@@ -170,8 +235,8 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 
     // Log audit
     loginDetails := "User logged in"
-    if user.IsAdmin {
-        loginDetails = "Admin user logged in"
+    if models.IsStaffRole(user.Role) {
+        loginDetails = "Staff user logged in as " + user.Role
     }
     h.logAudit(&user.ID, "LOGIN", "AUTH", loginDetails, r.RemoteAddr, r.UserAgent())
 
@@ -179,49 +244,266 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
     user.Password = ""
 
     response := models.LoginResponse{
-        Token: token,
-        User:  user,
+        TokenResponse:          *tokens,
+        User:                   user,
+        TwoFactorSetupRequired: h.config.TwoFactor.RequireForAdmins && models.IsStaffRole(user.Role),
     }
 
-    log.Printf("Login successful for %s, admin status: %v", user.Email, user.IsAdmin)
+    log.Printf("Login successful for %s, role: %s", user.Email, user.Role)
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(response)
 }
 
-// Debug endpoint to check token claims
-func (h *Handlers) DebugToken(w http.ResponseWriter, r *http.Request) {
-    claims := middleware.GetUserFromContext(r)
-    if claims == nil {
-        http.Error(w, "No user context found", http.StatusUnauthorized)
+// startSession records a new login by the user from the device making the
+// request. mfa records whether the login passed a second factor.
+func (h *Handlers) startSession(tx *gorm.DB, user *models.User, deviceName string, mfa bool, r *http.Request) (*models.Session, error) {
+    if deviceName == "" {
+        deviceName = utils.DeviceName(r.UserAgent())
+    }
+    now := time.Now()
+    session := models.Session{
+        UserID:     user.ID,
+        FamilyID:   uuid.NewString(),
+        DeviceName: deviceName,
+        UserAgent:  r.UserAgent(),
+        IPAddress:  middleware.ClientIP(r),
+        MFA:        mfa,
+        LastSeenAt: now,
+        ExpiresAt:  now.Add(h.config.RefreshTokenTTL),
+    }
+    if err := tx.Create(&session).Error; err != nil {
+        return nil, err
+    }
+    return &session, nil
+}
+
+// issueTokens signs a new access token for the user in the session and
+// stores the refresh token that goes with it in the session's family
+func (h *Handlers) issueTokens(tx *gorm.DB, user *models.User, session *models.Session, r *http.Request) (*models.TokenResponse, *models.RefreshToken, error) {
+    accessToken, claims, err := utils.GenerateToken(user.ID, user.Email, user.Role, models.RolePermissions(user.Role), session.MFA, session.ID, h.config.AccessTokenTTL)
+    if err != nil {
+        return nil, nil, err
+    }
+    refreshToken, err := utils.GenerateOpaqueToken()
+    if err != nil {
+        return nil, nil, err
+    }
+
+    record := models.RefreshToken{
+        UserID:          user.ID,
+        TokenHash:       utils.HashToken(refreshToken),
+        FamilyID:        session.FamilyID,
+        AccessJTI:       claims.ID,
+        AccessExpiresAt: claims.ExpiresAt.Time,
+        ExpiresAt:       time.Now().Add(h.config.RefreshTokenTTL),
+        MFA:             session.MFA,
+        IPAddress:       r.RemoteAddr,
+        UserAgent:       r.UserAgent(),
+    }
+    if err := tx.Create(&record).Error; err != nil {
+        return nil, nil, err
+    }
+
+    // The session lasts as long as its newest refresh token
+    if err := tx.Model(&models.Session{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+        "last_seen_at": record.CreatedAt,
+        "expires_at":   record.ExpiresAt,
+    }).Error; err != nil {
+        return nil, nil, err
+    }
+
+    return &models.TokenResponse{
+        Token:        accessToken,
+        RefreshToken: refreshToken,
+        ExpiresIn:    int(h.config.AccessTokenTTL.Seconds()),
+    }, &record, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token works once; presenting one that has
+// already been used revokes its whole family, since it means the token was
+// stolen.
+func (h *Handlers) RefreshToken(w http.ResponseWriter, r *http.Request) {
+    var req models.RefreshRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    var current models.RefreshToken
+    if err := h.db.Where("token_hash = ?", utils.HashToken(req.RefreshToken)).First(&current).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusUnauthorized, "Invalid refresh token", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to look up refresh token", err.Error())
+        }
+        return
+    }
+
+    if current.ReplacedByID != nil {
+        log.Printf("Refresh token reuse detected for user %d, revoking family %s", current.UserID, current.FamilyID)
+        if err := h.db.Transaction(func(tx *gorm.DB) error {
+            return models.RevokeTokens(tx, "refresh token reused", "family_id = ?", current.FamilyID)
+        }); err != nil {
+            sendError(w, http.StatusInternalServerError, "Failed to revoke tokens", err.Error())
+            return
+        }
+        h.logAudit(&current.UserID, "TOKEN_REUSE", "AUTH", "Refresh token reused; all sessions from that login revoked", r.RemoteAddr, r.UserAgent())
+        sendError(w, http.StatusUnauthorized, "Invalid refresh token", nil)
+        return
+    }
+    if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+        sendError(w, http.StatusUnauthorized, "Refresh token has expired or been revoked", nil)
+        return
+    }
+
+    var session models.Session
+    if err := h.db.Where("family_id = ?", current.FamilyID).First(&session).Error; err != nil || session.RevokedAt != nil {
+        sendError(w, http.StatusUnauthorized, "Refresh token has expired or been revoked", nil)
         return
     }
 
-    // Also get fresh user data from database
     var user models.User
-    if err := h.db.First(&user, claims.UserID).Error; err != nil {
-        http.Error(w, "User not found in database", http.StatusNotFound)
+    if err := h.db.First(&user, current.UserID).Error; err != nil {
+        sendError(w, http.StatusUnauthorized, "Invalid refresh token", nil)
+        return
+    }
+    if !user.IsActive {
+        sendError(w, http.StatusForbidden, "Account is deactivated", nil)
         return
     }
 
-    response := map[string]interface{}{
-        "token_claims": map[string]interface{}{
-            "user_id":  claims.UserID,
-            "email":    claims.Email,
-            "is_admin": claims.IsAdmin,
-            "expires":  claims.ExpiresAt,
-            "issued":   claims.IssuedAt,
-        },
-        "database_user": map[string]interface{}{
-            "id":         user.ID,
-            "email":      user.Email,
-            "is_admin":   user.IsAdmin,
-            "is_active":  user.IsActive,
-            "kyc_status": user.KYCStatus,
-        },
-        "admin_status_match": claims.IsAdmin == user.IsAdmin,
+    var tokens *models.TokenResponse
+    err := h.db.Transaction(func(tx *gorm.DB) error {
+        var next *models.RefreshToken
+        var err error
+        tokens, next, err = h.issueTokens(tx, &user, &session, r)
+        if err != nil {
+            return failRequest("Failed to generate token", err)
+        }
+
+        // Retire the presented token, unless a concurrent refresh got there first
+        now := time.Now()
+        result := tx.Model(&models.RefreshToken{}).
+            Where("id = ? AND revoked_at IS NULL", current.ID).
+            Updates(map[string]interface{}{"revoked_at": &now, "replaced_by_id": next.ID})
+        if result.Error != nil {
+            return failRequest("Failed to rotate refresh token", result.Error)
+        }
+        if result.RowsAffected == 0 {
+            return rejectRequest(http.StatusUnauthorized, "Refresh token has expired or been revoked", nil)
+        }
+        return nil
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
     }
 
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+    json.NewEncoder(w).Encode(tokens)
+}
+
+// Logout revokes the caller's access token and its refresh token family, or
+// the family of the refresh token given in the body
+func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.LogoutRequest
+    if r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+            return
+        }
+    }
+
+    query := h.db.Where("user_id = ? AND access_jti = ?", claims.UserID, claims.ID)
+    if req.RefreshToken != "" {
+        query = h.db.Where("user_id = ? AND token_hash = ?", claims.UserID, utils.HashToken(req.RefreshToken))
+    }
+
+    err := h.db.Transaction(func(tx *gorm.DB) error {
+        var token models.RefreshToken
+        if err := query.First(&token).Error; err == nil {
+            if err := models.RevokeTokens(tx, "logout", "family_id = ?", token.FamilyID); err != nil {
+                return err
+            }
+        } else if err != gorm.ErrRecordNotFound {
+            return err
+        }
+
+        // The access token may already be listed through its refresh token
+        var listed int64
+        if err := tx.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&listed).Error; err != nil {
+            return err
+        }
+        if listed > 0 {
+            return nil
+        }
+        return tx.Create(&models.RevokedToken{
+            JTI:       claims.ID,
+            UserID:    claims.UserID,
+            Reason:    "logout",
+            ExpiresAt: claims.ExpiresAt.Time,
+        }).Error
+    })
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to log out", err.Error())
+        return
+    }
+
+    h.logAudit(&claims.UserID, "LOGOUT", "AUTH", "User logged out", r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Logged out",
+    })
+}
+
+// PurgeExpiredTokens deletes revocation entries and refresh tokens that have
+// expired, since neither can be used any more, along with failed-login
+// records that have lapsed, wrong-code counts of expired MFA challenges,
+// expired password reset and verification links and sessions that have run
+// out
+func (h *Handlers) PurgeExpiredTokens() error {
+    now := time.Now()
+    if err := h.db.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+        return fmt.Errorf("failed to purge revoked tokens: %w", err)
+    }
+    if err := h.db.Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error; err != nil {
+        return fmt.Errorf("failed to purge refresh tokens: %w", err)
+    }
+    if err := h.staleLoginFailures(now).Delete(&models.LoginFailure{}).Error; err != nil {
+        return fmt.Errorf("failed to purge login failures: %w", err)
+    }
+    if err := h.db.Where("expires_at < ?", now).Delete(&models.MFAFailure{}).Error; err != nil {
+        return fmt.Errorf("failed to purge MFA failures: %w", err)
+    }
+    if err := h.db.Where("expires_at < ?", now).Delete(&models.OneTimeToken{}).Error; err != nil {
+        return fmt.Errorf("failed to purge one-time tokens: %w", err)
+    }
+    if err := h.db.Where("expires_at < ?", now).Delete(&models.Session{}).Error; err != nil {
+        return fmt.Errorf("failed to purge sessions: %w", err)
+    }
+    return nil
+}
+
+// RunTokenCleanup purges expired tokens every interval. It never returns.
+func (h *Handlers) RunTokenCleanup(interval time.Duration) {
+    for {
+        time.Sleep(interval)
+        if err := h.PurgeExpiredTokens(); err != nil {
+            log.Printf("Token cleanup failed: %v", err)
+        }
+    }
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "time"
+
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/money"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// describeBeneficiary fills in the fields of a beneficiary that are derived
+// rather than stored. The account must be preloaded.
+func (h *Handlers) describeBeneficiary(b *models.Beneficiary) {
+    if b.Account != nil {
+        b.AccountNumber = utils.MaskAccountNumber(b.Account.AccountNumber)
+    }
+    b.CoolingOffEndsAt = nil
+    if endsAt := b.CreatedAt.Add(h.config.Beneficiaries.CoolingOff); time.Now().Before(endsAt) {
+        b.CoolingOffEndsAt = &endsAt
+    }
+}
+
+// checkBeneficiary applies the beneficiary rules to a transfer. Transfers
+// between the user's own accounts are exempt. A beneficiary still in its
+// cooling-off period can only receive up to the cooling-off limit in total.
+// A payee that is not saved at all is held to the same limit over any
+// cooling-off-long window, so skipping the beneficiary book gains nothing.
+func (h *Handlers) checkBeneficiary(tx *gorm.DB, userID uint, to *models.Account, amount money.Amount) error {
+    if to.UserID == userID {
+        return nil
+    }
+    rules := h.config.Beneficiaries
+
+    var beneficiary models.Beneficiary
+    saved := true
+    if err := tx.Where("user_id = ? AND account_id = ?", userID, to.ID).First(&beneficiary).Error; err != nil {
+        if err != gorm.ErrRecordNotFound {
+            return failRequest("Failed to check beneficiary", err)
+        }
+        if rules.Required {
+            return rejectRequest(http.StatusForbidden, "Payee is not a saved beneficiary", nil)
+        }
+        saved = false
+    }
+
+    since := time.Now().Add(-rules.CoolingOff)
+    if saved {
+        if !time.Now().Before(beneficiary.CreatedAt.Add(rules.CoolingOff)) {
+            return nil
+        }
+        since = beneficiary.CreatedAt
+    }
+
+    var sent money.Amount
+    if err := tx.Model(&models.Transaction{}).
+        Where("account_id IN (?) AND type = ? AND to_account_id = ? AND created_at >= ?",
+            h.userAccountIDs(userID), "transfer_out", to.ID, since).
+        Select("COALESCE(SUM(amount), 0)").
+        Scan(&sent).Error; err != nil {
+        return failRequest("Failed to check beneficiary limit", err)
+    }
+
+    if sent+amount > rules.CoolingOffLimit {
+        remaining := rules.CoolingOffLimit - sent
+        if remaining.IsNegative() {
+            remaining = 0
+        }
+        if !saved {
+            return rejectRequest(http.StatusForbidden, "Transfer exceeds the limit for a payee who is not a saved beneficiary", map[string]string{
+                "remaining": remaining.String(),
+            })
+        }
+        return rejectRequest(http.StatusForbidden, "Transfer exceeds the limit for a new beneficiary", map[string]string{
+            "remaining":           remaining.String(),
+            "cooling_off_ends_at": since.Add(rules.CoolingOff).Format(time.RFC3339),
+        })
+    }
+    return nil
+}
+
+// findBeneficiary loads one of the user's beneficiaries
+func (h *Handlers) findBeneficiary(w http.ResponseWriter, r *http.Request, userID uint, b *models.Beneficiary) bool {
+    id, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid beneficiary ID", err.Error())
+        return false
+    }
+
+    if err := h.db.Preload("Account").Where("id = ? AND user_id = ?", id, userID).First(b).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Beneficiary not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch beneficiary", err.Error())
+        }
+        return false
+    }
+    return true
+}
+
+// ListBeneficiaries returns the caller's saved payees
+func (h *Handlers) ListBeneficiaries(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var beneficiaries []models.Beneficiary
+    if err := h.db.Preload("Account").Where("user_id = ?", claims.UserID).
+        Order("created_at DESC").Find(&beneficiaries).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to fetch beneficiaries", err.Error())
+        return
+    }
+    for i := range beneficiaries {
+        h.describeBeneficiary(&beneficiaries[i])
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(beneficiaries)
+}
+
+// AddBeneficiary saves a payee, addressed like a transfer recipient, to the
+// caller's beneficiary book. Its cooling-off period starts now.
+func (h *Handlers) AddBeneficiary(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.AddBeneficiaryRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        errors := utils.FormatValidationError(err)
+        sendError(w, http.StatusBadRequest, "Validation failed", errors)
+        return
+    }
+
+    if !h.allowPayeeLookup(claims.UserID) {
+        sendThrottled(w, "Too many payee lookups, try again later", time.Minute)
+        return
+    }
+
+    query, err := payeeQuery(h.db, req.To)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid payee", err.Error())
+        return
+    }
+
+    var account models.Account
+    if err := query.Preload("User").First(&account).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Payee not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to look up payee", err.Error())
+        }
+        return
+    }
+
+    if account.Status != "active" || account.User == nil {
+        sendError(w, http.StatusNotFound, "Payee not found", nil)
+        return
+    }
+    if account.UserID == claims.UserID {
+        sendError(w, http.StatusBadRequest, "Your own accounts cannot be saved as beneficiaries", nil)
+        return
+    }
+
+    var existing int64
+    if err := h.db.Model(&models.Beneficiary{}).
+        Where("user_id = ? AND account_id = ?", claims.UserID, account.ID).
+        Count(&existing).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to check beneficiaries", err.Error())
+        return
+    }
+    if existing > 0 {
+        sendError(w, http.StatusConflict, "Payee is already a beneficiary", nil)
+        return
+    }
+
+    beneficiary := models.Beneficiary{
+        UserID:    claims.UserID,
+        AccountID: account.ID,
+        Account:   &account,
+        Name:      payeeName(account.User),
+        Nickname:  req.Nickname,
+    }
+    if err := h.db.Omit("Account").Create(&beneficiary).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to add beneficiary", err.Error())
+        return
+    }
+    h.describeBeneficiary(&beneficiary)
+
+    h.logAudit(&claims.UserID, "ADD", "BENEFICIARY",
+        fmt.Sprintf("Added beneficiary %s (%s)", beneficiary.AccountNumber, beneficiary.Name), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(beneficiary)
+}
+
+// UpdateBeneficiary renames a beneficiary
+func (h *Handlers) UpdateBeneficiary(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var beneficiary models.Beneficiary
+    if !h.findBeneficiary(w, r, claims.UserID, &beneficiary) {
+        return
+    }
+
+    var req models.UpdateBeneficiaryRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    if err := h.db.Model(&beneficiary).Update("nickname", req.Nickname).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to update beneficiary", err.Error())
+        return
+    }
+    h.describeBeneficiary(&beneficiary)
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(beneficiary)
+}
+
+// DeleteBeneficiary removes a payee from the beneficiary book. Saving it
+// again starts a new cooling-off period.
+func (h *Handlers) DeleteBeneficiary(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var beneficiary models.Beneficiary
+    if !h.findBeneficiary(w, r, claims.UserID, &beneficiary) {
+        return
+    }
+
+    if err := h.db.Delete(&models.Beneficiary{}, beneficiary.ID).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to delete beneficiary", err.Error())
+        return
+    }
+    h.describeBeneficiary(&beneficiary)
+
+    h.logAudit(&claims.UserID, "DELETE", "BENEFICIARY",
+        fmt.Sprintf("Removed beneficiary %s (%s)", beneficiary.AccountNumber, beneficiary.Name), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Beneficiary removed",
+    })
+}
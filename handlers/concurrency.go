@@ -0,0 +1,190 @@
+package handlers
+
+import (
+    "errors"
+    "math/rand"
+    "net/http"
+    "time"
+
+    "minibank-go/models"
+
+    "github.com/go-sql-driver/mysql"
+    "github.com/jackc/pgx/v5/pgconn"
+    "github.com/mattn/go-sqlite3"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+const (
+    maxBalanceAttempts = 8
+    baseRetryBackoff   = 10 * time.Millisecond
+)
+
+// errConcurrentUpdate is returned when an account changed between being read
+// and being written. The whole database transaction should be retried.
+var errConcurrentUpdate = errors.New("account was modified concurrently")
+
+// requestError aborts a database transaction with a specific API response
+type requestError struct {
+    status  int
+    message string
+    details interface{}
+    err     error
+}
+
+func (e *requestError) Error() string {
+    if e.err != nil {
+        return e.message + ": " + e.err.Error()
+    }
+    return e.message
+}
+
+func (e *requestError) Unwrap() error {
+    return e.err
+}
+
+func rejectRequest(status int, message string, details interface{}) error {
+    return &requestError{status: status, message: message, details: details}
+}
+
+// failRequest wraps an unexpected error as a 500. A concurrent update passes
+// through the wrapper so the transaction is still retried.
+func failRequest(message string, err error) error {
+    return &requestError{status: http.StatusInternalServerError, message: message, details: err.Error(), err: err}
+}
+
+// sendRequestError writes the response for an error returned from a
+// money-moving transaction
+func sendRequestError(w http.ResponseWriter, err error) {
+    var reqErr *requestError
+    switch {
+    case isConflict(err):
+        sendError(w, http.StatusConflict, "Account was updated by another request, please retry", nil)
+    case errors.As(err, &reqErr):
+        sendError(w, reqErr.status, reqErr.message, reqErr.details)
+    default:
+        sendError(w, http.StatusInternalServerError, "Failed to process transaction", err.Error())
+    }
+}
+
+// retryOnConflict runs fn until it succeeds, fails for a reason other than a
+// concurrent update, or runs out of attempts. Attempts are spaced with
+// jittered exponential backoff.
+func retryOnConflict(fn func() error) error {
+    backoff := baseRetryBackoff
+    for attempt := 1; ; attempt++ {
+        err := fn()
+        if !isConflict(err) || attempt == maxBalanceAttempts {
+            return err
+        }
+        time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff))))
+        backoff *= 2
+    }
+}
+
+// isConflict reports whether err means the transaction lost a race and can
+// be retried. Besides a failed version check this covers SQLite refusing to
+// upgrade a read transaction to a write while another writer holds the lock,
+// and serialization failures, deadlocks and lock timeouts on Postgres and
+// MySQL.
+func isConflict(err error) bool {
+    if errors.Is(err, errConcurrentUpdate) {
+        return true
+    }
+
+    var sqliteErr sqlite3.Error
+    if errors.As(err, &sqliteErr) {
+        return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+    }
+
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) {
+        return pgErr.Code == "40001" || pgErr.Code == "40P01" // serialization_failure, deadlock_detected
+    }
+
+    var mysqlErr *mysql.MySQLError
+    if errors.As(err, &mysqlErr) {
+        return mysqlErr.Number == 1213 || mysqlErr.Number == 1205 // deadlock, lock wait timeout
+    }
+    return false
+}
+
+// isUniqueViolation reports whether err is a unique constraint failure on
+// any of the supported databases
+func isUniqueViolation(err error) bool {
+    var sqliteErr sqlite3.Error
+    if errors.As(err, &sqliteErr) {
+        return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+    }
+
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) {
+        return pgErr.Code == "23505" // unique_violation
+    }
+
+    var mysqlErr *mysql.MySQLError
+    if errors.As(err, &mysqlErr) {
+        return mysqlErr.Number == 1062 // duplicate entry
+    }
+    return false
+}
+
+// forUpdate adds a row lock to the query on databases that support one.
+// SQLite ignores it and relies on the version check in saveBalance.
+func forUpdate(db *gorm.DB) *gorm.DB {
+    return db.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// lockAccounts re-reads the accounts under row locks. Locks are always taken
+// in ascending ID order so two transfers over the same pair of accounts in
+// opposite directions cannot deadlock.
+func lockAccounts(tx *gorm.DB, accounts ...*models.Account) error {
+    ids := make([]uint, len(accounts))
+    for i, account := range accounts {
+        ids[i] = account.ID
+    }
+
+    var locked []models.Account
+    if err := forUpdate(tx).Where("id IN ?", ids).Order("id ASC").Find(&locked).Error; err != nil {
+        return err
+    }
+
+    byID := make(map[uint]models.Account, len(locked))
+    for _, account := range locked {
+        byID[account.ID] = account
+    }
+    for _, account := range accounts {
+        current, ok := byID[account.ID]
+        if !ok {
+            return gorm.ErrRecordNotFound
+        }
+        *account = current
+    }
+    return nil
+}
+
+// saveBalance writes the account's balance and held amount only if the row
+// still has the version it was read at, and bumps the version. It returns
+// errConcurrentUpdate if another transaction got there first.
+func saveBalance(tx *gorm.DB, account *models.Account) error {
+    now := time.Now()
+    result := tx.Model(&models.Account{}).
+        Where("id = ? AND version = ?", account.ID, account.Version).
+        Updates(map[string]interface{}{
+            "balance":     account.Balance,
+            "held_amount": account.HeldAmount,
+            "version":     gorm.Expr("version + 1"),
+            "updated_at":  now,
+        })
+    if result.Error != nil {
+        return result.Error
+    }
+    if result.RowsAffected == 0 {
+        return errConcurrentUpdate
+    }
+
+    account.Version++
+    account.UpdatedAt = now
+    account.Refresh()
+    return nil
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "sync"
+    "testing"
+
+    "minibank-go/config"
+    "minibank-go/database"
+    "minibank-go/ledger"
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/money"
+    "minibank-go/notify"
+    "minibank-go/utils"
+
+    "gorm.io/gorm/logger"
+)
+
+// newTestHandlers returns handlers on a fresh, fully migrated SQLite database
+func newTestHandlers(t *testing.T) *Handlers {
+    t.Helper()
+    dir := t.TempDir()
+
+    db, err := database.Open(filepath.Join(dir, "minibank.db"), config.DatabasePool{})
+    if err != nil {
+        t.Fatalf("open database: %v", err)
+    }
+    db.Logger = logger.Discard
+    if err := database.MigrateUp(db); err != nil {
+        t.Fatalf("migrate: %v", err)
+    }
+    t.Cleanup(func() {
+        if sqlDB, err := db.DB(); err == nil {
+            sqlDB.Close()
+        }
+    })
+
+    cfg := config.Load()
+    cfg.AMLRules.DailyTransactionLimit = 1000
+    cfg.PayeeLookupLimit = 0
+    // Notifications are sent in the background and can outlive the test,
+    // so they must not land in its temporary directory
+    return NewHandlers(db, cfg, &notify.FileNotifier{Path: os.DevNull})
+}
+
+// newTestCustomer creates a user with one account holding balance
+func newTestCustomer(t *testing.T, h *Handlers, n int, balance money.Amount) *models.Account {
+    t.Helper()
+
+    user := models.User{
+        Email:     fmt.Sprintf("customer%d@example.com", n),
+        Phone:     fmt.Sprintf("90000000%02d", n),
+        Password:  "unused",
+        FirstName: "Test",
+        LastName:  fmt.Sprintf("Customer%d", n),
+        Role:      models.RoleCustomer,
+        IsActive:  true,
+    }
+    if err := h.db.Create(&user).Error; err != nil {
+        t.Fatalf("create user: %v", err)
+    }
+    account, err := openAccount(h.db, user.ID, "savings", money.DefaultCurrency, "")
+    if err != nil {
+        t.Fatalf("open account: %v", err)
+    }
+
+    if balance > 0 {
+        w := callAs(h.Deposit, user.ID, models.DepositRequest{AccountID: account.ID, Amount: balance})
+        if w.Code != http.StatusOK {
+            t.Fatalf("opening deposit: %d %s", w.Code, w.Body.String())
+        }
+    }
+    return reloadAccount(t, h, account.ID)
+}
+
+// callAs runs a handler for a JSON request made by the user
+func callAs(handler http.HandlerFunc, userID uint, body interface{}) *httptest.ResponseRecorder {
+    payload, _ := json.Marshal(body)
+    r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+    r = r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, &utils.Claims{UserID: userID}))
+    w := httptest.NewRecorder()
+    handler(w, r)
+    return w
+}
+
+func reloadAccount(t *testing.T, h *Handlers, id uint) *models.Account {
+    t.Helper()
+    var account models.Account
+    if err := h.db.First(&account, id).Error; err != nil {
+        t.Fatalf("reload account %d: %v", id, err)
+    }
+    return &account
+}
+
+// checkLedger fails the test unless the postings sum to zero and every
+// cached balance matches the ledger
+func checkLedger(t *testing.T, h *Handlers) {
+    t.Helper()
+    report, err := ledger.Verify(h.db)
+    if err != nil {
+        t.Fatalf("verify ledger: %v", err)
+    }
+    if !report.Balanced {
+        t.Errorf("trial balance is %s, want 0", report.TrialBalance)
+    }
+    for _, m := range report.Mismatches {
+        t.Errorf("account %d caches %s but the ledger says %s", m.AccountID, m.CachedBalance, m.LedgerBalance)
+    }
+}
+
+// runConcurrently starts every request at once and counts the successes
+// of each kind. Requests may only fail with one of the allowed statuses.
+func runConcurrently(t *testing.T, requests []func() *httptest.ResponseRecorder, kinds []string, allowed ...int) map[string]int {
+    t.Helper()
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    start := make(chan struct{})
+    succeeded := map[string]int{}
+    for i, request := range requests {
+        wg.Add(1)
+        go func(request func() *httptest.ResponseRecorder, kind string) {
+            defer wg.Done()
+            <-start
+            w := request()
+
+            mu.Lock()
+            defer mu.Unlock()
+            if w.Code == http.StatusOK {
+                succeeded[kind]++
+                return
+            }
+            for _, status := range allowed {
+                if w.Code == status {
+                    return
+                }
+            }
+            t.Errorf("%s: unexpected %d %s", kind, w.Code, w.Body.String())
+        }(request, kinds[i])
+    }
+    close(start)
+    wg.Wait()
+    return succeeded
+}
+
+func TestConcurrentOpposingTransfers(t *testing.T) {
+    h := newTestHandlers(t)
+    opening := money.FromMajor(1000)
+    a := newTestCustomer(t, h, 1, opening)
+    b := newTestCustomer(t, h, 2, opening)
+
+    const perDirection = 20
+    amount := money.FromMajor(10)
+    var requests []func() *httptest.ResponseRecorder
+    var kinds []string
+    for i := 0; i < perDirection; i++ {
+        requests = append(requests, func() *httptest.ResponseRecorder {
+            return callAs(h.Transfer, a.UserID, models.TransferRequest{FromAccountID: a.ID, To: b.AccountNumber, Amount: amount})
+        })
+        kinds = append(kinds, "a->b")
+        requests = append(requests, func() *httptest.ResponseRecorder {
+            return callAs(h.Transfer, b.UserID, models.TransferRequest{FromAccountID: b.ID, To: a.AccountNumber, Amount: amount})
+        })
+        kinds = append(kinds, "b->a")
+    }
+
+    // A transfer may give up with 409 after losing every retry, but must
+    // then have changed nothing
+    succeeded := runConcurrently(t, requests, kinds, http.StatusConflict)
+    ab, ba := succeeded["a->b"], succeeded["b->a"]
+    if ab == 0 || ba == 0 {
+        t.Fatalf("want transfers in both directions to succeed, got a->b %d, b->a %d", ab, ba)
+    }
+
+    finalA, finalB := reloadAccount(t, h, a.ID), reloadAccount(t, h, b.ID)
+    net := money.Amount(ba-ab) * amount
+    if want := opening + net; finalA.Balance != want {
+        t.Errorf("account A balance = %s, want %s", finalA.Balance, want)
+    }
+    if want := opening - net; finalB.Balance != want {
+        t.Errorf("account B balance = %s, want %s", finalB.Balance, want)
+    }
+    if finalA.Balance+finalB.Balance != 2*opening {
+        t.Errorf("money was created or lost: %s + %s != %s", finalA.Balance, finalB.Balance, 2*opening)
+    }
+
+    // Every transfer bumps the version of both accounts exactly once
+    if want := a.Version + uint(ab+ba); finalA.Version != want {
+        t.Errorf("account A version = %d, want %d", finalA.Version, want)
+    }
+    if want := b.Version + uint(ab+ba); finalB.Version != want {
+        t.Errorf("account B version = %d, want %d", finalB.Version, want)
+    }
+
+    var legs int64
+    h.db.Model(&models.Transaction{}).Where("type IN ?", []string{"transfer_out", "transfer_in"}).Count(&legs)
+    if want := int64(2 * (ab + ba)); legs != want {
+        t.Errorf("%d transfer legs recorded, want %d", legs, want)
+    }
+
+    checkLedger(t, h)
+}
+
+func TestConcurrentDepositsAndWithdrawals(t *testing.T) {
+    h := newTestHandlers(t)
+    opening := money.FromMajor(100)
+    account := newTestCustomer(t, h, 1, opening)
+
+    const perKind = 25
+    deposit, withdrawal := money.FromMajor(10), money.FromMajor(15)
+    var requests []func() *httptest.ResponseRecorder
+    var kinds []string
+    for i := 0; i < perKind; i++ {
+        requests = append(requests, func() *httptest.ResponseRecorder {
+            return callAs(h.Deposit, account.UserID, models.DepositRequest{AccountID: account.ID, Amount: deposit})
+        })
+        kinds = append(kinds, "deposit")
+        requests = append(requests, func() *httptest.ResponseRecorder {
+            return callAs(h.Withdraw, account.UserID, models.WithdrawRequest{AccountID: account.ID, Amount: withdrawal})
+        })
+        kinds = append(kinds, "withdraw")
+    }
+
+    // Withdrawals that find too little money are refused with 403
+    succeeded := runConcurrently(t, requests, kinds, http.StatusConflict, http.StatusForbidden)
+    deposits, withdrawals := succeeded["deposit"], succeeded["withdraw"]
+    if deposits == 0 || withdrawals == 0 {
+        t.Fatalf("want deposits and withdrawals to succeed, got %d and %d", deposits, withdrawals)
+    }
+
+    final := reloadAccount(t, h, account.ID)
+    want := opening + money.Amount(deposits)*deposit - money.Amount(withdrawals)*withdrawal
+    if final.Balance != want {
+        t.Errorf("balance = %s, want %s", final.Balance, want)
+    }
+    if final.Balance.IsNegative() {
+        t.Errorf("balance went negative: %s", final.Balance)
+    }
+    if wantVersion := account.Version + uint(deposits+withdrawals); final.Version != wantVersion {
+        t.Errorf("version = %d, want %d", final.Version, wantVersion)
+    }
+
+    var recorded int64
+    h.db.Model(&models.Transaction{}).Where("account_id = ? AND type IN ?", account.ID, []string{"deposit", "withdraw"}).Count(&recorded)
+    if wantRecorded := int64(1 + deposits + withdrawals); recorded != wantRecorded {
+        t.Errorf("%d transactions recorded, want %d", recorded, wantRecorded)
+    }
+
+    checkLedger(t, h)
+}
+
+func TestConcurrentTransfersToNewPayee(t *testing.T) {
+    h := newTestHandlers(t)
+    h.config.Beneficiaries.CoolingOffLimit = money.FromMajor(100)
+    a := newTestCustomer(t, h, 1, money.FromMajor(1000))
+    b := newTestCustomer(t, h, 2, 0)
+
+    const transfers = 20
+    amount := money.FromMajor(10)
+    var requests []func() *httptest.ResponseRecorder
+    var kinds []string
+    for i := 0; i < transfers; i++ {
+        requests = append(requests, func() *httptest.ResponseRecorder {
+            return callAs(h.Transfer, a.UserID, models.TransferRequest{FromAccountID: a.ID, To: b.AccountNumber, Amount: amount})
+        })
+        kinds = append(kinds, "a->b")
+    }
+
+    // Transfers over the payee limit are refused with 403
+    succeeded := runConcurrently(t, requests, kinds, http.StatusConflict, http.StatusForbidden)
+    if sent := money.Amount(succeeded["a->b"]) * amount; sent > h.config.Beneficiaries.CoolingOffLimit {
+        t.Errorf("sent %s to a payee limited to %s", sent, h.config.Beneficiaries.CoolingOffLimit)
+    }
+    if balance := reloadAccount(t, h, b.ID).Balance; balance != money.Amount(succeeded["a->b"])*amount {
+        t.Errorf("payee balance = %s, want %s", balance, money.Amount(succeeded["a->b"])*amount)
+    }
+
+    checkLedger(t, h)
+}
+
+func TestRebuildBalancesDuringTransfers(t *testing.T) {
+    h := newTestHandlers(t)
+    opening := money.FromMajor(1000)
+    a := newTestCustomer(t, h, 1, opening)
+    b := newTestCustomer(t, h, 2, opening)
+
+    amount := money.FromMajor(10)
+    var requests []func() *httptest.ResponseRecorder
+    var kinds []string
+    for i := 0; i < 20; i++ {
+        requests = append(requests, func() *httptest.ResponseRecorder {
+            return callAs(h.Transfer, a.UserID, models.TransferRequest{FromAccountID: a.ID, To: b.AccountNumber, Amount: amount})
+        })
+        kinds = append(kinds, "transfer")
+        if i%4 == 0 {
+            requests = append(requests, func() *httptest.ResponseRecorder {
+                return callAs(h.RebuildBalances, a.UserID, nil)
+            })
+            kinds = append(kinds, "rebuild")
+        }
+    }
+
+    // A rebuild racing a transfer must never write back a balance the
+    // transfer has already moved on from
+    succeeded := runConcurrently(t, requests, kinds, http.StatusConflict)
+    if succeeded["rebuild"] == 0 {
+        t.Fatal("no rebuild succeeded")
+    }
+
+    sent := money.Amount(succeeded["transfer"]) * amount
+    if balance := reloadAccount(t, h, a.ID).Balance; balance != opening-sent {
+        t.Errorf("account A balance = %s, want %s", balance, opening-sent)
+    }
+    if balance := reloadAccount(t, h, b.ID).Balance; balance != opening+sent {
+        t.Errorf("account B balance = %s, want %s", balance, opening+sent)
+    }
+
+    checkLedger(t, h)
+}
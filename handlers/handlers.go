@@ -12,8 +12,11 @@ import (
     "github.com/google/uuid"
 
     "minibank-go/config"
+    "minibank-go/ledger"
     "minibank-go/models"
     "minibank-go/middleware"
+    "minibank-go/money"
+    "minibank-go/notify"
     "minibank-go/utils"
 )
 
@@ -42,8 +45,13 @@ func sendError(w http.ResponseWriter, status int, err string, details interface{
 }
 
 type Handlers struct {
-    db     *gorm.DB
-    config *config.Config
+    db           *gorm.DB
+    config       *config.Config
+    loginIPs     *middleware.KeyedLimiter // login attempts per client IP
+    loginEmails  *middleware.KeyedLimiter // login attempts per email
+    payeeLookups *middleware.KeyedLimiter // payee lookups per user
+    mfaUsers     *middleware.KeyedLimiter // second-factor attempts per user
+    notifier     notify.Notifier
 }
 
 // generateReference generates a unique transaction reference
@@ -51,10 +59,15 @@ func (h *Handlers) generateReference() string {
     return uuid.New().String()
 }
 
-func NewHandlers(db *gorm.DB, cfg *config.Config) *Handlers {
+func NewHandlers(db *gorm.DB, cfg *config.Config, notifier notify.Notifier) *Handlers {
     return &Handlers{
-        db:     db,
-        config: cfg,
+        db:           db,
+        config:       cfg,
+        loginIPs:     newPerMinuteLimiter(cfg.LoginProtection.IPLimit),
+        loginEmails:  newPerMinuteLimiter(cfg.LoginProtection.EmailLimit),
+        payeeLookups: newPerMinuteLimiter(cfg.PayeeLookupLimit),
+        mfaUsers:     newPerMinuteLimiter(cfg.TwoFactor.UserLimit),
+        notifier:     notifier,
     }
 }
 
@@ -68,12 +81,20 @@ func (h *Handlers) HealthCheck(w http.ResponseWriter, r *http.Request) {
     })
 }
 
-// Check if user is admin
+// JWKS publishes the public keys access tokens are signed with, so other
+// services can verify them without holding a secret
+func (h *Handlers) JWKS(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "public, max-age=300")
+    json.NewEncoder(w).Encode(utils.JWKS())
+}
+
+// Check if user is a superadmin
 func (h *Handlers) isUserAdmin(claims *utils.Claims) bool {
     if claims == nil {
         return false
     }
-    return claims.IsAdmin
+    return claims.Role == models.RoleSuperadmin
 }
 
 // Send unauthorized response for admin endpoints
@@ -88,7 +109,7 @@ func (h *Handlers) sendUnauthorized(w http.ResponseWriter, r *http.Request) {
         "error": "Admin access required",
         "user_id": claims.UserID,
         "email": claims.Email,
-        "is_admin": claims.IsAdmin,
+        "role": claims.Role,
     })
 }
 
@@ -122,8 +143,13 @@ func (h *Handlers) GetTransactions(w http.ResponseWriter, r *http.Request) {
     }
     offset := (page - 1) * limit
 
+    query := h.db.Where("account_id IN (?)", h.userAccountIDs(claims.UserID))
+    if accountID, _ := strconv.ParseUint(r.URL.Query().Get("account_id"), 10, 64); accountID != 0 {
+        query = query.Where("account_id = ?", accountID)
+    }
+
     var transactions []models.Transaction
-    if err := h.db.Where("user_id = ?", claims.UserID).
+    if err := query.
         Order("created_at DESC").
         Limit(limit).
         Offset(offset).
@@ -136,9 +162,20 @@ func (h *Handlers) GetTransactions(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(transactions)
 }
 
+// userAccountIDs is a subquery selecting the IDs of every account a user owns
+func (h *Handlers) userAccountIDs(userID uint) *gorm.DB {
+    return h.db.Model(&models.Account{}).Select("id").Where("user_id = ?", userID)
+}
+
+// startOfDay returns midnight at the start of t's day in t's location
+func startOfDay(t time.Time) time.Time {
+    year, month, day := t.Date()
+    return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
 // Check daily transaction limits
-func (h *Handlers) checkDailyLimit(userID uint, amount float64, txnType string) error {
-    var dailyLimit float64
+func (h *Handlers) checkDailyLimit(userID uint, amount money.Amount, txnType string) error {
+    var dailyLimit money.Amount
     switch txnType {
     case "deposit":
         dailyLimit = h.config.TransactionLimits.DailyDepositLimit
@@ -150,28 +187,51 @@ func (h *Handlers) checkDailyLimit(userID uint, amount float64, txnType string)
         return fmt.Errorf("invalid transaction type: %s", txnType)
     }
 
-    var totalToday float64
+    // Transfers are counted by their outgoing leg, and card captures spend
+    // money just as withdrawals do
+    types := []string{txnType}
+    switch txnType {
+    case "transfer":
+        types = []string{"transfer_out"}
+    case "withdraw":
+        types = append(types, "capture")
+    }
+
+    var totalToday money.Amount
     if err := h.db.Model(&models.Transaction{}).
-        Where("user_id = ? AND created_at >= ? AND type = ?",
-            userID, time.Now().Format("2006-01-02 00:00:00"), txnType).
+        Where("account_id IN (?) AND created_at >= ? AND type IN ?",
+            h.userAccountIDs(userID), startOfDay(time.Now()), types).
         Select("COALESCE(SUM(amount), 0)").
         Scan(&totalToday).Error; err != nil {
         return fmt.Errorf("failed to calculate daily limit: %w", err)
     }
 
+    // So do holds placed today that are still waiting to be captured
+    if txnType == "withdraw" {
+        var held money.Amount
+        if err := h.db.Model(&models.Hold{}).
+            Where("account_id IN (?) AND created_at >= ? AND status = ?",
+                h.userAccountIDs(userID), startOfDay(time.Now()), "pending").
+            Select("COALESCE(SUM(amount), 0)").
+            Scan(&held).Error; err != nil {
+            return fmt.Errorf("failed to calculate daily limit: %w", err)
+        }
+        totalToday += held
+    }
+
     if totalToday+amount > dailyLimit {
-        return fmt.Errorf("daily limit exceeded: %.2f/%.2f", totalToday+amount, dailyLimit)
+        return fmt.Errorf("daily limit exceeded: %s/%s", totalToday+amount, dailyLimit)
     }
     return nil
 }
 
 // Check AML rules
-func (h *Handlers) checkAMLRules(userID uint, amount float64) error {
+func (h *Handlers) checkAMLRules(userID uint, amount money.Amount) error {
     // Get user's total transactions in last 30 days
-    var totalLast30Days float64
+    var totalLast30Days money.Amount
     if err := h.db.Model(&models.Transaction{}).
-        Where("user_id = ? AND created_at >= ?",
-            userID, time.Now().AddDate(0, -1, 0).Format("2006-01-02 00:00:00")).
+        Where("account_id IN (?) AND created_at >= ?",
+            h.userAccountIDs(userID), time.Now().AddDate(0, -1, 0)).
         Select("COALESCE(SUM(amount), 0)").
         Scan(&totalLast30Days).Error; err != nil {
         return fmt.Errorf("failed to calculate 30-day total: %w", err)
@@ -184,8 +244,8 @@ func (h *Handlers) checkAMLRules(userID uint, amount float64) error {
 
     // Check for rapid transactions
     var recentTxns []models.Transaction
-    if err := h.db.Where("user_id = ? AND created_at >= ?",
-        userID, time.Now().Add(-time.Hour*24).Format("2006-01-02 00:00:00")).
+    if err := h.db.Where("account_id IN (?) AND created_at >= ?",
+        h.userAccountIDs(userID), time.Now().Add(-time.Hour*24)).
         Find(&recentTxns).Error; err != nil {
         return fmt.Errorf("failed to check recent transactions: %w", err)
     }
@@ -229,58 +289,69 @@ func (h *Handlers) Deposit(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // Begin transaction
-    tx := h.db.Begin()
-    defer func() {
-        if r := recover(); r != nil {
-            tx.Rollback()
-        }
-    }()
-
-    // Lock user record for update
-    var user models.User
-    if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&user, claims.UserID).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to lock user record", err.Error())
-        return
-    }
-
-    // Update balance
-    user.Balance += req.Amount
-
-    if err := tx.Save(&user).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to update balance", err.Error())
-        return
-    }
-
-    // Create transaction record
-    txn := models.Transaction{
-        UserID:        claims.UserID,
-        Type:          "deposit",
-        Amount:        req.Amount,
-        BalanceBefore: user.Balance - req.Amount,
-        BalanceAfter:  user.Balance,
-        Description:   req.Description,
-        Reference:     h.generateReference(),
-    }
-
-    if err := tx.Create(&txn).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to create transaction record", err.Error())
+    var account models.Account
+    var txn models.Transaction
+    err := retryOnConflict(func() error {
+        return h.db.Transaction(func(tx *gorm.DB) error {
+            // Lock account record for update
+            if err := forUpdate(ownAccountQuery(tx, claims.UserID, req.AccountID)).First(&account).Error; err != nil {
+                if err == gorm.ErrRecordNotFound {
+                    return rejectRequest(http.StatusNotFound, "Account not found", nil)
+                }
+                return failRequest("Failed to lock account record", err)
+            }
+
+            if account.Status != "active" {
+                return rejectRequest(http.StatusForbidden, "Account is not active", map[string]string{"status": account.Status})
+            }
+
+            // Update balance
+            account.Balance += req.Amount
+
+            if err := saveBalance(tx, &account); err != nil {
+                return failRequest("Failed to update balance", err)
+            }
+
+            reference := h.generateReference()
+
+            // Post the journal entry behind the balance change
+            entry, err := ledger.PostDeposit(tx, &account, req.Amount, reference, req.Description)
+            if err != nil {
+                return failRequest("Failed to post ledger entry", err)
+            }
+
+            // Create transaction record
+            txn = models.Transaction{
+                AccountID:      account.ID,
+                Type:           "deposit",
+                Amount:         req.Amount,
+                Currency:       account.Currency,
+                BalanceBefore:  account.Balance - req.Amount,
+                BalanceAfter:   account.Balance,
+                Description:    req.Description,
+                Reference:      reference,
+                JournalEntryID: &entry.ID,
+            }
+
+            if err := tx.Create(&txn).Error; err != nil {
+                return failRequest("Failed to create transaction record", err)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        sendRequestError(w, err)
         return
     }
 
     // Log audit
-    h.logAudit(&claims.UserID, "deposit", "transaction", fmt.Sprintf("Deposited %f", req.Amount), r.RemoteAddr, r.UserAgent())
-
-    tx.Commit()
+    h.logAudit(&claims.UserID, "deposit", "transaction", fmt.Sprintf("Deposited %s to account %s", req.Amount, account.AccountNumber), r.RemoteAddr, r.UserAgent())
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]interface{}{
         "message": "Deposit successful",
         "transaction": txn,
-        "new_balance": user.Balance,
+        "new_balance": account.Balance,
     })
 }
 
@@ -316,231 +387,291 @@ func (h *Handlers) Withdraw(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // Begin transaction
-    tx := h.db.Begin()
-    defer func() {
-        if r := recover(); r != nil {
-            tx.Rollback()
-        }
-    }()
-
-    // Lock user record for update
-    var user models.User
-    if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&user, claims.UserID).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to lock user record", err.Error())
-        return
-    }
-
-    // Check sufficient balance
-    if user.Balance < req.Amount {
-        tx.Rollback()
-        sendError(w, http.StatusForbidden, "Insufficient balance", nil)
-        return
-    }
-
-    // Update balance
-    user.Balance -= req.Amount
-
-    if err := tx.Save(&user).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to update balance", err.Error())
-        return
-    }
-
-    // Create transaction record
-    txn := models.Transaction{
-        UserID:        claims.UserID,
-        Type:          "withdraw",
-        Amount:        req.Amount,
-        BalanceBefore: user.Balance + req.Amount,
-        BalanceAfter:  user.Balance,
-        Description:   req.Description,
-        Reference:     h.generateReference(),
-    }
-
-    if err := tx.Create(&txn).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to create transaction record", err.Error())
+    if err := h.requireStepUp(r, claims.UserID, req.Amount); err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    var account models.Account
+    var txn models.Transaction
+    err := retryOnConflict(func() error {
+        return h.db.Transaction(func(tx *gorm.DB) error {
+            // Lock account record for update
+            if err := forUpdate(ownAccountQuery(tx, claims.UserID, req.AccountID)).First(&account).Error; err != nil {
+                if err == gorm.ErrRecordNotFound {
+                    return rejectRequest(http.StatusNotFound, "Account not found", nil)
+                }
+                return failRequest("Failed to lock account record", err)
+            }
+
+            if account.Status != "active" {
+                return rejectRequest(http.StatusForbidden, "Account is not active", map[string]string{"status": account.Status})
+            }
+
+            // Check sufficient balance, leaving pending holds untouched
+            if account.Available < req.Amount {
+                return rejectRequest(http.StatusForbidden, "Insufficient balance", map[string]string{
+                    "available_balance": account.Available.String(),
+                })
+            }
+
+            // Update balance
+            account.Balance -= req.Amount
+
+            if err := saveBalance(tx, &account); err != nil {
+                return failRequest("Failed to update balance", err)
+            }
+
+            reference := h.generateReference()
+
+            // Post the journal entry behind the balance change
+            entry, err := ledger.PostWithdrawal(tx, &account, req.Amount, reference, req.Description)
+            if err != nil {
+                return failRequest("Failed to post ledger entry", err)
+            }
+
+            // Create transaction record
+            txn = models.Transaction{
+                AccountID:      account.ID,
+                Type:           "withdraw",
+                Amount:         req.Amount,
+                Currency:       account.Currency,
+                BalanceBefore:  account.Balance + req.Amount,
+                BalanceAfter:   account.Balance,
+                Description:    req.Description,
+                Reference:      reference,
+                JournalEntryID: &entry.ID,
+            }
+
+            if err := tx.Create(&txn).Error; err != nil {
+                return failRequest("Failed to create transaction record", err)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        sendRequestError(w, err)
         return
     }
 
     // Log audit
-    h.logAudit(&claims.UserID, "withdraw", "transaction", fmt.Sprintf("Withdrew %f", req.Amount), r.RemoteAddr, r.UserAgent())
-
-    tx.Commit()
+    h.logAudit(&claims.UserID, "withdraw", "transaction", fmt.Sprintf("Withdrew %s from account %s", req.Amount, account.AccountNumber), r.RemoteAddr, r.UserAgent())
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]interface{}{
         "message": "Withdrawal successful",
         "transaction": txn,
-        "new_balance": user.Balance,
+        "new_balance": account.Balance,
     })
 }
 
-// Transfer handler
-func (h *Handlers) Transfer(w http.ResponseWriter, r *http.Request) {
-    claims := middleware.GetUserFromContext(r)
-    if claims == nil {
-        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
-        return
+// transferResult is what a completed transfer leaves behind
+type transferResult struct {
+    From     models.Account
+    To       models.Account
+    Sent     models.Transaction
+    Received models.Transaction
+}
+
+// checkTransferLimits applies the daily transfer limit and AML rules to a
+// transfer the user is about to make
+func (h *Handlers) checkTransferLimits(userID uint, amount money.Amount) error {
+    // Check daily limit
+    if err := h.checkDailyLimit(userID, amount, "transfer"); err != nil {
+        return rejectRequest(http.StatusBadRequest, err.Error(), nil)
     }
 
-    var req models.TransferRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
-        return
+    // Check AML rules
+    if err := h.checkAMLRules(userID, amount); err != nil {
+        return rejectRequest(http.StatusBadRequest, err.Error(), nil)
     }
+    return nil
+}
 
-    if err := utils.ValidateStruct(req); err != nil {
-        errors := utils.FormatValidationError(err)
-        sendError(w, http.StatusBadRequest, "Validation failed", errors)
-        return
+// postTransfer moves money for the user inside tx. It is shared by the
+// transfer endpoint and the scheduler, so both run the same beneficiary,
+// account, currency and balance checks; callers apply checkTransferLimits
+// first.
+func (h *Handlers) postTransfer(tx *gorm.DB, userID uint, req models.TransferRequest) (*transferResult, error) {
+    var fromAccount, toAccount models.Account
+
+    // Resolve both accounts before locking either
+    if err := ownAccountQuery(tx, userID, req.FromAccountID).First(&fromAccount).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, rejectRequest(http.StatusNotFound, "Source account not found", nil)
+        }
+        return nil, failRequest("Failed to fetch sender account", err)
     }
 
-    // Check daily limit
-    if err := h.checkDailyLimit(claims.UserID, req.Amount, "transfer"); err != nil {
-        sendError(w, http.StatusBadRequest, err.Error(), nil)
-        return
+    recipientQuery := ownAccountQuery(tx, userID, req.ToAccountID)
+    if req.ToAccountID == 0 {
+        query, err := payeeQuery(tx, req.To)
+        if err != nil {
+            return nil, rejectRequest(http.StatusBadRequest, "Invalid payee", err.Error())
+        }
+        recipientQuery = query
+    }
+    if err := recipientQuery.First(&toAccount).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, rejectRequest(http.StatusNotFound, "Recipient account not found", nil)
+        }
+        return nil, failRequest("Failed to fetch recipient account", err)
     }
 
-    // Check AML rules
-    if err := h.checkAMLRules(claims.UserID, req.Amount); err != nil {
-        sendError(w, http.StatusBadRequest, err.Error(), nil)
-        return
+    if fromAccount.ID == toAccount.ID {
+        return nil, rejectRequest(http.StatusBadRequest, "Cannot transfer to the same account", nil)
     }
 
-    // Begin transaction
-    tx := h.db.Begin()
-    defer func() {
-        if r := recover(); r != nil {
-            tx.Rollback()
-        }
-    }()
+    // Lock both account records for update
+    if err := lockAccounts(tx, &fromAccount, &toAccount); err != nil {
+        return nil, failRequest("Failed to lock account records", err)
+    }
 
-    // Lock both user records for update
-    var fromUser, toUser models.User
-    if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&fromUser, claims.UserID).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to lock sender record", err.Error())
-        return
+    // Checked under the payee's lock, so concurrent transfers to the same
+    // payee cannot each see the other's amount as still unsent
+    if err := h.checkBeneficiary(tx, userID, &toAccount, req.Amount); err != nil {
+        return nil, err
     }
 
-    if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&toUser, req.ToUserID).Error; err != nil {
-        tx.Rollback()
-        if err == gorm.ErrRecordNotFound {
-            sendError(w, http.StatusNotFound, "Recipient user not found", map[string]string{"error_detail": err.Error()})
-        } else {
-            sendError(w, http.StatusInternalServerError, "Error fetching recipient user", map[string]string{"error_detail": err.Error()})
-        }
-        return
+    if fromAccount.Status != "active" || toAccount.Status != "active" {
+        return nil, rejectRequest(http.StatusForbidden, "Account is not active", map[string]string{
+            "from_status": fromAccount.Status,
+            "to_status":   toAccount.Status,
+        })
     }
 
-    // Check sufficient balance
-    if fromUser.Balance < req.Amount {
-        tx.Rollback()
-        sendError(w, http.StatusForbidden, "Insufficient balance", nil)
-        return
+    if fromAccount.Currency != toAccount.Currency {
+        return nil, rejectRequest(http.StatusBadRequest, "Currency mismatch", map[string]string{
+            "from_currency": string(fromAccount.Currency),
+            "to_currency":   string(toAccount.Currency),
+        })
+    }
+
+    // Check sufficient balance, leaving pending holds untouched
+    if fromAccount.Available < req.Amount {
+        return nil, rejectRequest(http.StatusForbidden, "Insufficient balance", map[string]string{
+            "available_balance": fromAccount.Available.String(),
+        })
     }
 
     // Update balances
-    fromUser.Balance -= req.Amount
-    toUser.Balance += req.Amount
+    fromAccount.Balance -= req.Amount
+    toAccount.Balance += req.Amount
 
-    if err := tx.Save(&fromUser).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to update sender balance", err.Error())
-        return
+    if err := saveBalance(tx, &fromAccount); err != nil {
+        return nil, failRequest("Failed to update sender balance", err)
     }
 
-    if err := tx.Save(&toUser).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to update recipient balance", err.Error())
-        return
+    if err := saveBalance(tx, &toAccount); err != nil {
+        return nil, failRequest("Failed to update recipient balance", err)
+    }
+
+    reference := h.generateReference()
+
+    // Post the journal entry behind both balance changes
+    entry, err := ledger.PostTransfer(tx, &fromAccount, &toAccount, req.Amount, reference, req.Description)
+    if err != nil {
+        return nil, failRequest("Failed to post ledger entry", err)
     }
 
     // Create transaction records
     senderTxn := models.Transaction{
-        UserID:        claims.UserID,
-        Type:          "transfer_out",
-        Amount:        req.Amount,
-        BalanceBefore: fromUser.Balance + req.Amount,
-        BalanceAfter:  fromUser.Balance,
-        ToUserID:      &toUser.ID,
-        Description:   req.Description,
-        Reference:     h.generateReference(),
+        AccountID:      fromAccount.ID,
+        Type:           "transfer_out",
+        Amount:         req.Amount,
+        Currency:       fromAccount.Currency,
+        BalanceBefore:  fromAccount.Balance + req.Amount,
+        BalanceAfter:   fromAccount.Balance,
+        ToAccountID:    &toAccount.ID,
+        Description:    req.Description,
+        Reference:      reference,
+        JournalEntryID: &entry.ID,
     }
 
     receiverTxn := models.Transaction{
-        UserID:        toUser.ID,
-        Type:          "transfer_in",
-        Amount:        req.Amount,
-        BalanceBefore: toUser.Balance - req.Amount,
-        BalanceAfter:  toUser.Balance,
-        FromUserID:    &claims.UserID,
-        Description:   req.Description,
-        Reference:     senderTxn.Reference,
+        AccountID:      toAccount.ID,
+        Type:           "transfer_in",
+        Amount:         req.Amount,
+        Currency:       toAccount.Currency,
+        BalanceBefore:  toAccount.Balance - req.Amount,
+        BalanceAfter:   toAccount.Balance,
+        FromAccountID:  &fromAccount.ID,
+        Description:    req.Description,
+        Reference:      reference,
+        JournalEntryID: &entry.ID,
     }
 
     if err := tx.Create(&senderTxn).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to create sender transaction record", err.Error())
-        return
+        return nil, failRequest("Failed to create sender transaction record", err)
     }
 
     if err := tx.Create(&receiverTxn).Error; err != nil {
-        tx.Rollback()
-        sendError(w, http.StatusInternalServerError, "Failed to create receiver transaction record", err.Error())
-        return
+        return nil, failRequest("Failed to create receiver transaction record", err)
     }
-
-    // Log audit
-    h.logAudit(&claims.UserID, "transfer_out", "transaction", fmt.Sprintf("Transferred %f to user %d", req.Amount, req.ToUserID), r.RemoteAddr, r.UserAgent())
-    h.logAudit(&toUser.ID, "transfer_in", "transaction", fmt.Sprintf("Received %f from user %d", req.Amount, claims.UserID), r.RemoteAddr, r.UserAgent())
-
-    tx.Commit()
-
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]interface{}{
-        "message": "Transfer successful",
-        "transaction": senderTxn,
-        "new_balance": fromUser.Balance,
-    })
+    return &transferResult{From: fromAccount, To: toAccount, Sent: senderTxn, Received: receiverTxn}, nil
 }
 
-// VerifyUser handler
-func (h *Handlers) VerifyUser(w http.ResponseWriter, r *http.Request) {
-    var req struct {
-        UserID uint `json:"user_id" validate:"required,gt=0"`
-        Status string `json:"status" validate:"required,oneof=verified rejected"`
+// Transfer handler. Money can move to one of the caller's own accounts, by
+// account ID, or to any customer's account addressed by account number,
+// email or phone number.
+func (h *Handlers) Transfer(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
     }
 
+    var req models.TransferRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
         return
     }
 
     if err := utils.ValidateStruct(req); err != nil {
-        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        errors := utils.FormatValidationError(err)
+        sendError(w, http.StatusBadRequest, "Validation failed", errors)
+        return
+    }
+
+    // Paying by email, phone or account number reveals whether it exists
+    if req.ToAccountID == 0 && !h.allowPayeeLookup(claims.UserID) {
+        sendThrottled(w, "Too many payee lookups, try again later", time.Minute)
         return
     }
 
-    var user models.User
-    if err := h.db.First(&user, req.UserID).Error; err != nil {
-        sendError(w, http.StatusNotFound, "User not found", nil)
+    if err := h.checkTransferLimits(claims.UserID, req.Amount); err != nil {
+        sendRequestError(w, err)
         return
     }
 
-    user.Verified = req.Status == "verified"
-    if err := h.db.Save(&user).Error; err != nil {
-        sendError(w, http.StatusInternalServerError, "Failed to update user verification status", err.Error())
+    if err := h.requireStepUp(r, claims.UserID, req.Amount); err != nil {
+        sendRequestError(w, err)
         return
     }
 
+    var result *transferResult
+    err := retryOnConflict(func() error {
+        return h.db.Transaction(func(tx *gorm.DB) error {
+            var err error
+            result, err = h.postTransfer(tx, claims.UserID, req)
+            return err
+        })
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+    fromAccount, toAccount := result.From, result.To
+
+    // Log audit
+    h.logAudit(&claims.UserID, "transfer_out", "transaction", fmt.Sprintf("Transferred %s from account %s to account %s", req.Amount, fromAccount.AccountNumber, toAccount.AccountNumber), r.RemoteAddr, r.UserAgent())
+    h.logAudit(&toAccount.UserID, "transfer_in", "transaction", fmt.Sprintf("Received %s into account %s from account %s", req.Amount, toAccount.AccountNumber, fromAccount.AccountNumber), r.RemoteAddr, r.UserAgent())
+
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]interface{}{
-        "message": "User verification status updated",
-        "user":    user,
+        "message": "Transfer successful",
+        "transaction": result.Sent,
+        "new_balance": fromAccount.Balance,
     })
 }
 
@@ -565,12 +696,12 @@ func (h *Handlers) DebugToken(w http.ResponseWriter, r *http.Request) {
 	// Clear sensitive information
 	user.Password = ""
 
-	adminStatusMatch := claims.IsAdmin == user.IsAdmin
+	roleMatch := claims.Role == user.Role
 
 	response := map[string]interface{}{
-		"token_claims":       claims,
-		"database_user":      user,
-		"admin_status_match": adminStatusMatch,
+		"token_claims":  claims,
+		"database_user": user,
+		"role_match":    roleMatch,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+
+    "minibank-go/ledger"
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// ownHoldQuery scopes a query to a hold on one of the user's accounts
+func (h *Handlers) ownHoldQuery(db *gorm.DB, userID, holdID uint) *gorm.DB {
+    return db.Where("id = ? AND account_id IN (?)", holdID, h.userAccountIDs(userID))
+}
+
+// releaseHold moves a pending hold to a final status and gives its amount
+// back to the available balance. The account must already be locked.
+func releaseHold(tx *gorm.DB, hold *models.Hold, account *models.Account, status string) error {
+    now := time.Now()
+    result := tx.Model(&models.Hold{}).
+        Where("id = ? AND status = ?", hold.ID, "pending").
+        Updates(map[string]interface{}{
+            "status":      status,
+            "released_at": &now,
+        })
+    if result.Error != nil {
+        return result.Error
+    }
+    if result.RowsAffected == 0 {
+        return errConcurrentUpdate
+    }
+    hold.Status = status
+    hold.ReleasedAt = &now
+
+    account.HeldAmount -= hold.Amount
+    return saveBalance(tx, account)
+}
+
+// loadPendingHold fetches one of the user's holds together with its locked
+// account, refusing holds that are no longer pending
+func (h *Handlers) loadPendingHold(tx *gorm.DB, userID, holdID uint, hold *models.Hold, account *models.Account) error {
+    if err := h.ownHoldQuery(tx, userID, holdID).First(hold).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return rejectRequest(http.StatusNotFound, "Hold not found", nil)
+        }
+        return failRequest("Failed to fetch hold", err)
+    }
+
+    if hold.Status != "pending" {
+        return rejectRequest(http.StatusConflict, "Hold is no longer pending", map[string]string{"status": hold.Status})
+    }
+    if time.Now().After(hold.ExpiresAt) {
+        return rejectRequest(http.StatusConflict, "Hold has expired", nil)
+    }
+
+    if err := forUpdate(tx).First(account, hold.AccountID).Error; err != nil {
+        return failRequest("Failed to lock account record", err)
+    }
+    return nil
+}
+
+// ListHolds returns the holds on the caller's accounts, newest first
+func (h *Handlers) ListHolds(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    query := h.db.Where("account_id IN (?)", h.userAccountIDs(claims.UserID))
+    if status := r.URL.Query().Get("status"); status != "" {
+        query = query.Where("status = ?", status)
+    }
+
+    var holds []models.Hold
+    if err := query.Order("created_at DESC").Find(&holds).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to fetch holds", err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(holds)
+}
+
+// GetHold returns one of the caller's holds
+func (h *Handlers) GetHold(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    holdID, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid hold ID", err.Error())
+        return
+    }
+
+    var hold models.Hold
+    if err := h.ownHoldQuery(h.db, claims.UserID, holdID).First(&hold).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Hold not found", nil)
+            return
+        }
+        sendError(w, http.StatusInternalServerError, "Failed to fetch hold", err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(hold)
+}
+
+// PlaceHold reserves an amount on one of the caller's accounts. The hold
+// lowers the available balance until it is captured, voided or expires.
+func (h *Handlers) PlaceHold(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.PlaceHoldRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        errors := utils.FormatValidationError(err)
+        sendError(w, http.StatusBadRequest, "Validation failed", errors)
+        return
+    }
+
+    // A hold is spent on capture, so it counts as a withdrawal
+    if err := h.checkDailyLimit(claims.UserID, req.Amount, "withdraw"); err != nil {
+        sendError(w, http.StatusBadRequest, err.Error(), nil)
+        return
+    }
+
+    // Check AML rules
+    if err := h.checkAMLRules(claims.UserID, req.Amount); err != nil {
+        sendError(w, http.StatusBadRequest, err.Error(), nil)
+        return
+    }
+
+    if err := h.requireStepUp(r, claims.UserID, req.Amount); err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    var account models.Account
+    var hold models.Hold
+    err := retryOnConflict(func() error {
+        return h.db.Transaction(func(tx *gorm.DB) error {
+            if err := forUpdate(ownAccountQuery(tx, claims.UserID, req.AccountID)).First(&account).Error; err != nil {
+                if err == gorm.ErrRecordNotFound {
+                    return rejectRequest(http.StatusNotFound, "Account not found", nil)
+                }
+                return failRequest("Failed to lock account record", err)
+            }
+
+            if account.Status != "active" {
+                return rejectRequest(http.StatusForbidden, "Account is not active", map[string]string{"status": account.Status})
+            }
+
+            if account.Available < req.Amount {
+                return rejectRequest(http.StatusForbidden, "Insufficient balance", map[string]string{
+                    "available_balance": account.Available.String(),
+                })
+            }
+
+            account.HeldAmount += req.Amount
+            if err := saveBalance(tx, &account); err != nil {
+                return failRequest("Failed to update held amount", err)
+            }
+
+            hold = models.Hold{
+                AccountID:   account.ID,
+                Reference:   h.generateReference(),
+                Amount:      req.Amount,
+                Currency:    account.Currency,
+                Description: req.Description,
+                Status:      "pending",
+                ExpiresAt:   time.Now().Add(h.config.HoldExpiry),
+            }
+            if err := tx.Create(&hold).Error; err != nil {
+                return failRequest("Failed to create hold", err)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    h.logAudit(&claims.UserID, "HOLD", "ACCOUNT",
+        fmt.Sprintf("Placed hold %s of %s on account %s", hold.Reference, hold.Amount, account.AccountNumber), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "message":           "Hold placed",
+        "hold":              hold,
+        "available_balance": account.Available,
+    })
+}
+
+// CaptureHold settles a pending hold for its full amount or less. The
+// captured amount is debited and posted to the ledger; any remainder is
+// released back to the available balance.
+func (h *Handlers) CaptureHold(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    holdID, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid hold ID", err.Error())
+        return
+    }
+
+    var req models.CaptureHoldRequest
+    if r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+            return
+        }
+    }
+    if req.Amount.IsNegative() {
+        sendError(w, http.StatusBadRequest, "Capture amount must be positive", nil)
+        return
+    }
+
+    var hold models.Hold
+    var account models.Account
+    var txn models.Transaction
+    err = retryOnConflict(func() error {
+        return h.db.Transaction(func(tx *gorm.DB) error {
+            if err := h.loadPendingHold(tx, claims.UserID, holdID, &hold, &account); err != nil {
+                return err
+            }
+
+            amount := req.Amount
+            if amount.IsZero() {
+                amount = hold.Amount
+            }
+            if amount > hold.Amount {
+                return rejectRequest(http.StatusBadRequest, "Capture amount exceeds the hold", map[string]string{
+                    "hold_amount": hold.Amount.String(),
+                })
+            }
+
+            // Release the whole hold, then take the captured part
+            now := time.Now()
+            result := tx.Model(&models.Hold{}).
+                Where("id = ? AND status = ?", hold.ID, "pending").
+                Updates(map[string]interface{}{
+                    "status":          "captured",
+                    "captured_amount": amount,
+                    "captured_at":     &now,
+                })
+            if result.Error != nil {
+                return failRequest("Failed to capture hold", result.Error)
+            }
+            if result.RowsAffected == 0 {
+                return errConcurrentUpdate
+            }
+
+            account.HeldAmount -= hold.Amount
+            account.Balance -= amount
+            if err := saveBalance(tx, &account); err != nil {
+                return failRequest("Failed to update balance", err)
+            }
+
+            reference := h.generateReference()
+            description := hold.Description
+            if description == "" {
+                description = fmt.Sprintf("Capture of hold %s", hold.Reference)
+            }
+
+            // Post the journal entry for the captured amount only
+            entry, err := ledger.PostCapture(tx, &account, amount, reference, description)
+            if err != nil {
+                return failRequest("Failed to post ledger entry", err)
+            }
+
+            txn = models.Transaction{
+                AccountID:      account.ID,
+                Type:           "capture",
+                Amount:         amount,
+                Currency:       account.Currency,
+                BalanceBefore:  account.Balance + amount,
+                BalanceAfter:   account.Balance,
+                Description:    description,
+                Reference:      reference,
+                JournalEntryID: &entry.ID,
+            }
+            if err := tx.Create(&txn).Error; err != nil {
+                return failRequest("Failed to create transaction record", err)
+            }
+
+            if err := tx.Model(&models.Hold{}).Where("id = ?", hold.ID).Update("transaction_id", txn.ID).Error; err != nil {
+                return failRequest("Failed to link capture transaction", err)
+            }
+            hold.Status = "captured"
+            hold.CapturedAmount = amount
+            hold.CapturedAt = &now
+            hold.TransactionID = &txn.ID
+            return nil
+        })
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    h.logAudit(&claims.UserID, "CAPTURE", "HOLD",
+        fmt.Sprintf("Captured %s of hold %s on account %s", hold.CapturedAmount, hold.Reference, account.AccountNumber), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "message":           "Hold captured",
+        "hold":              hold,
+        "transaction":       txn,
+        "new_balance":       account.Balance,
+        "available_balance": account.Available,
+    })
+}
+
+// VoidHold cancels a pending hold and releases its amount
+func (h *Handlers) VoidHold(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    holdID, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid hold ID", err.Error())
+        return
+    }
+
+    var hold models.Hold
+    var account models.Account
+    err = retryOnConflict(func() error {
+        return h.db.Transaction(func(tx *gorm.DB) error {
+            if err := h.loadPendingHold(tx, claims.UserID, holdID, &hold, &account); err != nil {
+                return err
+            }
+            if err := releaseHold(tx, &hold, &account, "voided"); err != nil {
+                return failRequest("Failed to void hold", err)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    h.logAudit(&claims.UserID, "VOID", "HOLD",
+        fmt.Sprintf("Voided hold %s of %s on account %s", hold.Reference, hold.Amount, account.AccountNumber), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "message":           "Hold voided",
+        "hold":              hold,
+        "available_balance": account.Available,
+    })
+}
+
+// ExpireHolds releases pending holds that are past their expiry and returns
+// how many were expired
+func (h *Handlers) ExpireHolds() (int, error) {
+    var stale []models.Hold
+    if err := h.db.Where("status = ? AND expires_at < ?", "pending", time.Now()).
+        Order("id ASC").Limit(100).Find(&stale).Error; err != nil {
+        return 0, fmt.Errorf("failed to find expired holds: %w", err)
+    }
+
+    expired := 0
+    for _, hold := range stale {
+        hold := hold
+        err := retryOnConflict(func() error {
+            return h.db.Transaction(func(tx *gorm.DB) error {
+                var account models.Account
+                if err := forUpdate(tx).First(&account, hold.AccountID).Error; err != nil {
+                    return err
+                }
+                return releaseHold(tx, &hold, &account, "expired")
+            })
+        })
+        if err != nil {
+            // Captured or voided while we were looking
+            if isConflict(err) {
+                continue
+            }
+            return expired, fmt.Errorf("failed to expire hold %s: %w", hold.Reference, err)
+        }
+        expired++
+    }
+    return expired, nil
+}
+
+// RunHoldExpiry expires stale holds every interval. It never returns.
+func (h *Handlers) RunHoldExpiry(interval time.Duration) {
+    for {
+        time.Sleep(interval)
+        expired, err := h.ExpireHolds()
+        if err != nil {
+            log.Printf("Hold expiry failed: %v", err)
+        }
+        if expired > 0 {
+            log.Printf("Expired %d stale holds", expired)
+        }
+    }
+}
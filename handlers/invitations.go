@@ -0,0 +1,241 @@
+package handlers
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// ListInvitations returns every staff invitation, newest first
+func (h *Handlers) ListInvitations(w http.ResponseWriter, r *http.Request) {
+    var invitations []models.Invitation
+    if err := h.db.Order("created_at DESC").Find(&invitations).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to fetch invitations", err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(invitations)
+}
+
+// CreateInvitation issues a single-use invitation for a new staff member.
+// Any earlier invitation to the same email that is still open is revoked.
+// The token is only returned here; only its hash is stored.
+func (h *Handlers) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.CreateInvitationRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    if !models.IsStaffRole(req.Role) {
+        sendError(w, http.StatusBadRequest, "Invitations are for staff roles only", req.Role)
+        return
+    }
+
+    var existing int64
+    if err := h.db.Model(&models.User{}).Where("email = ?", req.Email).Count(&existing).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
+        return
+    }
+    if existing > 0 {
+        sendError(w, http.StatusConflict, "A user with this email already exists; assign a role instead", nil)
+        return
+    }
+
+    token, err := utils.GenerateOpaqueToken()
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to generate invitation token", err.Error())
+        return
+    }
+
+    invitation := models.Invitation{
+        Email:       req.Email,
+        Role:        req.Role,
+        TokenHash:   utils.HashToken(token),
+        InvitedByID: claims.UserID,
+        ExpiresAt:   time.Now().Add(h.config.InvitationTTL),
+    }
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        now := time.Now()
+        if err := tx.Model(&models.Invitation{}).
+            Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", req.Email).
+            Update("revoked_at", &now).Error; err != nil {
+            return err
+        }
+        return tx.Create(&invitation).Error
+    })
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to create invitation", err.Error())
+        return
+    }
+
+    h.logAudit(&claims.UserID, "INVITE", "USER",
+        fmt.Sprintf("Invited %s as %s", invitation.Email, invitation.Role), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(models.InvitationResponse{
+        Invitation: invitation,
+        Token:      token,
+    })
+}
+
+// RevokeInvitation cancels an invitation that has not been accepted yet
+func (h *Handlers) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    id, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid invitation ID", err.Error())
+        return
+    }
+
+    var invitation models.Invitation
+    if err := h.db.First(&invitation, id).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Invitation not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch invitation", err.Error())
+        }
+        return
+    }
+
+    now := time.Now()
+    result := h.db.Model(&models.Invitation{}).
+        Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+        Update("revoked_at", &now)
+    if result.Error != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to revoke invitation", result.Error.Error())
+        return
+    }
+    if result.RowsAffected == 0 {
+        sendError(w, http.StatusConflict, "Invitation has already been accepted or revoked", nil)
+        return
+    }
+
+    h.logAudit(&claims.UserID, "REVOKE", "INVITATION",
+        fmt.Sprintf("Revoked invitation %d for %s", invitation.ID, invitation.Email), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Invitation revoked",
+    })
+}
+
+// AcceptInvitation creates the invited staff member's user with the role
+// they were invited for. Each invitation can be accepted once.
+func (h *Handlers) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+    var req models.AcceptInvitationRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    var invitation models.Invitation
+    if err := h.db.Where("token_hash = ?", utils.HashToken(req.Token)).First(&invitation).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusBadRequest, "Invalid or expired invitation", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to look up invitation", err.Error())
+        }
+        return
+    }
+    if invitation.AcceptedAt != nil || invitation.RevokedAt != nil || time.Now().After(invitation.ExpiresAt) {
+        sendError(w, http.StatusBadRequest, "Invalid or expired invitation", nil)
+        return
+    }
+
+    var existing int64
+    if err := h.db.Model(&models.User{}).Where("email = ? OR phone = ?", invitation.Email, req.Phone).Count(&existing).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
+        return
+    }
+    if existing > 0 {
+        sendError(w, http.StatusConflict, "User already exists", nil)
+        return
+    }
+
+    hashedPassword, err := utils.HashPassword(req.Password)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to hash password", err.Error())
+        return
+    }
+
+    user := models.User{
+        Email:     invitation.Email,
+        Phone:     req.Phone,
+        Password:  hashedPassword,
+        FirstName: req.FirstName,
+        LastName:  req.LastName,
+        IsActive:  true,
+        Role:      invitation.Role,
+        KYCStatus: "pending",
+    }
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        // Claim the invitation, unless it was accepted or revoked meanwhile
+        now := time.Now()
+        result := tx.Model(&models.Invitation{}).
+            Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", invitation.ID, now).
+            Update("accepted_at", &now)
+        if result.Error != nil {
+            return failRequest("Failed to accept invitation", result.Error)
+        }
+        if result.RowsAffected == 0 {
+            return rejectRequest(http.StatusBadRequest, "Invalid or expired invitation", nil)
+        }
+
+        if err := CreateUser(tx, &user); err != nil {
+            return failRequest("Failed to create user", err)
+        }
+        if err := tx.Model(&models.Invitation{}).Where("id = ?", invitation.ID).Update("user_id", user.ID).Error; err != nil {
+            return failRequest("Failed to accept invitation", err)
+        }
+        return nil
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    log.Printf("Invitation %d accepted: user %d (%s) created as %s", invitation.ID, user.ID, user.Email, user.Role)
+    h.logAudit(&user.ID, "CREATE", "USER",
+        fmt.Sprintf("Staff user registered as %s by invitation %d", user.Role, invitation.ID), r.RemoteAddr, r.UserAgent())
+
+    user.Password = ""
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "message": "User registered successfully",
+        "user":    user,
+    })
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"minibank-go/middleware"
 	"minibank-go/models"
@@ -60,6 +61,31 @@ func (h *Handlers) SubmitKYC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse documents already registered to another customer, found by
+	// blind index since the stored numbers are encrypted
+	var kyc models.KYC
+	if err := setKYCBlindIndexes(&kyc, req.PAN, req.AadhaarNumber, req.PassportNumber); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "Failed to index identity documents",
+			"details": err.Error(),
+		})
+		return
+	}
+	matches, err := identityMatches(h.db, &kyc, claims.UserID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if len(matches) > 0 {
+		h.logAudit(&claims.UserID, "KYC_DUPLICATE", "KYC",
+			fmt.Sprintf("KYC refused: %s matched KYC record %d of user %d", strings.Join(matches[0].Documents, ", "), matches[0].KYCID, matches[0].UserID),
+			r.RemoteAddr, r.UserAgent())
+		http.Error(w, "Identity document is already registered to another customer", http.StatusConflict)
+		return
+	}
+
 	// Encrypt sensitive data
 	encryptedPAN, err := utils.EncryptSensitiveData(req.PAN)
 	if err != nil {
@@ -86,21 +112,36 @@ func (h *Handlers) SubmitKYC(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Create KYC record
-	kyc := models.KYC{
-		UserID:         claims.UserID,
-		PAN:            encryptedPAN,
-		AadhaarNumber:  encryptedAadhaar,
-		PassportNumber: req.PassportNumber,
-		DateOfBirth:    req.DateOfBirth,
-		Address:        req.Address,
-		City:           req.City,
-		State:          req.State,
-		PinCode:        req.PinCode,
-		Status:         "pending",
+	encryptedPassport, err := utils.EncryptSensitiveData(req.PassportNumber)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "Failed to encrypt passport data",
+			"details": fmt.Sprintf("Encryption error: %v", err),
+		})
+		return
 	}
 
+	// Create KYC record
+	kyc.UserID = claims.UserID
+	kyc.PAN = encryptedPAN
+	kyc.AadhaarNumber = encryptedAadhaar
+	kyc.PassportNumber = encryptedPassport
+	kyc.KeyID = utils.EncryptionKeyID(encryptedPAN)
+	kyc.DateOfBirth = req.DateOfBirth
+	kyc.Address = req.Address
+	kyc.City = req.City
+	kyc.State = req.State
+	kyc.PinCode = req.PinCode
+	kyc.Status = "pending"
+
 	if err := h.db.Create(&kyc).Error; err != nil {
+		// Another customer registered the same document since the check above
+		if isUniqueViolation(err) {
+			http.Error(w, "Identity document is already registered to another customer", http.StatusConflict)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -154,3 +195,78 @@ func (h *Handlers) GetKYCStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// setKYCBlindIndexes sets the blind indexes of the document numbers on a KYC
+// record
+func setKYCBlindIndexes(kyc *models.KYC, pan, aadhaar, passport string) error {
+	for _, document := range []struct {
+		index  *models.DocumentIndex
+		kind   string
+		number string
+	}{
+		{&kyc.PANIndex, models.DocumentPAN, pan},
+		{&kyc.AadhaarIndex, models.DocumentAadhaar, aadhaar},
+		{&kyc.PassportIndex, models.DocumentPassport, passport},
+	} {
+		index, err := utils.BlindIndex(document.kind, document.number)
+		if err != nil {
+			return err
+		}
+		*document.index = models.DocumentIndex(index)
+	}
+	return nil
+}
+
+// identityMatches finds the KYC records of customers other than
+// excludeUserID that share a document number with probe, comparing blind
+// indexes. An excludeUserID of 0 excludes no one.
+func identityMatches(db *gorm.DB, probe *models.KYC, excludeUserID uint) ([]models.KYCIdentityMatch, error) {
+	var conditions []string
+	var args []interface{}
+	for column, index := range map[string]models.DocumentIndex{
+		"pan_index":      probe.PANIndex,
+		"aadhaar_index":  probe.AadhaarIndex,
+		"passport_index": probe.PassportIndex,
+	} {
+		if index != "" {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, index)
+		}
+	}
+	matches := []models.KYCIdentityMatch{}
+	if len(conditions) == 0 {
+		return matches, nil
+	}
+
+	var records []models.KYC
+	if err := db.Preload("User").
+		Where(strings.Join(conditions, " OR "), args...).
+		Where("user_id <> ?", excludeUserID).
+		Order("id").
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		match := models.KYCIdentityMatch{
+			KYCID:     record.ID,
+			Status:    record.Status,
+			UserID:    record.UserID,
+			Email:     record.User.Email,
+			FirstName: record.User.FirstName,
+			LastName:  record.User.LastName,
+			Documents: []string{},
+		}
+		if probe.PANIndex != "" && record.PANIndex == probe.PANIndex {
+			match.Documents = append(match.Documents, models.DocumentPAN)
+		}
+		if probe.AadhaarIndex != "" && record.AadhaarIndex == probe.AadhaarIndex {
+			match.Documents = append(match.Documents, models.DocumentAadhaar)
+		}
+		if probe.PassportIndex != "" && record.PassportIndex == probe.PassportIndex {
+			match.Documents = append(match.Documents, models.DocumentPassport)
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+    "testing"
+    "time"
+
+    "minibank-go/models"
+)
+
+func TestKYCDocumentIndexesAreUnique(t *testing.T) {
+    h := newTestHandlers(t)
+
+    newKYC := func(n int, pan, passport models.DocumentIndex) error {
+        account := newTestCustomer(t, h, n, 0)
+        return h.db.Create(&models.KYC{
+            UserID:        account.UserID,
+            PAN:           "encrypted",
+            PANIndex:      pan,
+            PassportIndex: passport,
+            DateOfBirth:   time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+            Address:       "1 Test Street",
+            City:          "Pune",
+            State:         "Maharashtra",
+            PinCode:       "411001",
+        }).Error
+    }
+
+    // Records without a passport share the empty index
+    if err := newKYC(1, "pan-1", ""); err != nil {
+        t.Fatalf("first record: %v", err)
+    }
+    if err := newKYC(2, "pan-2", ""); err != nil {
+        t.Fatalf("second record without a passport: %v", err)
+    }
+
+    err := newKYC(3, "pan-1", "")
+    if err == nil {
+        t.Fatal("a second record with the same PAN was stored")
+    }
+    if !isUniqueViolation(err) {
+        t.Errorf("duplicate PAN failed with %v, want a unique violation", err)
+    }
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+
+    "minibank-go/ledger"
+    "minibank-go/middleware"
+)
+
+// VerifyLedger checks the trial balance and compares every cached user
+// balance with the balance derived from ledger postings
+func (h *Handlers) VerifyLedger(w http.ResponseWriter, r *http.Request) {
+    report, err := ledger.Verify(h.db)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to verify ledger", err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(report)
+}
+
+// RebuildBalances overwrites cached user balances from the ledger
+func (h *Handlers) RebuildBalances(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    changed, err := ledger.RebuildBalances(h.db)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to rebuild balances", err.Error())
+        return
+    }
+
+    h.logAudit(&claims.UserID, "REBUILD", "LEDGER",
+        fmt.Sprintf("Rebuilt cached balances from ledger, %d changed", len(changed)), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "message": "Balances rebuilt from ledger",
+        "changed": changed,
+    })
+}
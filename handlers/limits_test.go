@@ -0,0 +1,76 @@
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+    "testing"
+
+    "minibank-go/models"
+    "minibank-go/money"
+)
+
+func TestDailyTransferLimitCountsEarlierTransfers(t *testing.T) {
+    h := newTestHandlers(t)
+    h.config.TransactionLimits.DailyTransferLimit = money.FromMajor(500)
+
+    from := newTestCustomer(t, h, 1, money.FromMajor(1000))
+    to, err := openAccount(h.db, from.UserID, "current", money.DefaultCurrency, "")
+    if err != nil {
+        t.Fatalf("open account: %v", err)
+    }
+
+    transfer := models.TransferRequest{FromAccountID: from.ID, ToAccountID: to.ID, Amount: money.FromMajor(300)}
+    if w := callAs(h.Transfer, from.UserID, transfer); w.Code != http.StatusOK {
+        t.Fatalf("first transfer: %d %s", w.Code, w.Body.String())
+    }
+
+    // 300 + 300 is over the limit of 500, though each transfer is under it
+    if w := callAs(h.Transfer, from.UserID, transfer); w.Code != http.StatusBadRequest {
+        t.Fatalf("second transfer: got %d %s, want 400", w.Code, w.Body.String())
+    }
+
+    if balance := reloadAccount(t, h, from.ID).Balance; balance != money.FromMajor(700) {
+        t.Errorf("balance = %s, want 700.00", balance)
+    }
+}
+
+func TestTransfersByPayeeSpendPayeeLookups(t *testing.T) {
+    h := newTestHandlers(t)
+    h.payeeLookups = newPerMinuteLimiter(2)
+
+    from := newTestCustomer(t, h, 1, money.FromMajor(1000))
+
+    // Probing for registered emails through transfers is throttled like
+    // the lookup endpoint, and a miss says nothing about why
+    for i := 0; i < 2; i++ {
+        transfer := models.TransferRequest{FromAccountID: from.ID, To: fmt.Sprintf("unknown%d@example.com", i), Amount: money.FromMajor(10)}
+        w := callAs(h.Transfer, from.UserID, transfer)
+        if w.Code != http.StatusNotFound {
+            t.Fatalf("transfer %d: got %d %s, want 404", i, w.Code, w.Body.String())
+        }
+        if strings.Contains(w.Body.String(), "error_detail") {
+            t.Errorf("transfer %d: response leaks the lookup error: %s", i, w.Body.String())
+        }
+    }
+
+    transfer := models.TransferRequest{FromAccountID: from.ID, To: "unknown2@example.com", Amount: money.FromMajor(10)}
+    if w := callAs(h.Transfer, from.UserID, transfer); w.Code != http.StatusTooManyRequests {
+        t.Fatalf("third transfer: got %d %s, want 429", w.Code, w.Body.String())
+    }
+}
+
+func TestResendVerificationIsThrottled(t *testing.T) {
+    h := newTestHandlers(t)
+    h.loginEmails = newPerMinuteLimiter(2)
+
+    account := newTestCustomer(t, h, 1, 0)
+    for i := 0; i < 2; i++ {
+        if w := callAs(h.ResendVerification, account.UserID, nil); w.Code != http.StatusAccepted {
+            t.Fatalf("resend %d: got %d %s, want 202", i, w.Code, w.Body.String())
+        }
+    }
+    if w := callAs(h.ResendVerification, account.UserID, nil); w.Code != http.StatusTooManyRequests {
+        t.Fatalf("third resend: got %d %s, want 429", w.Code, w.Body.String())
+    }
+}
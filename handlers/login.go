@@ -0,0 +1,194 @@
+package handlers
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/utils"
+
+    "golang.org/x/time/rate"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// dummyPasswordHash is checked against when a login names an email nobody
+// has, so the response takes as long as it would for a wrong password
+var dummyPasswordHash, _ = utils.HashPassword("minibank-login-timing-equaliser")
+
+// newPerMinuteLimiter returns a limiter allowing perMinute attempts per key,
+// such as logins per email. Zero means no limit.
+func newPerMinuteLimiter(perMinute int) *middleware.KeyedLimiter {
+    if perMinute <= 0 {
+        return middleware.NewKeyedLimiter(rate.Inf, 0)
+    }
+    return middleware.NewKeyedLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute)
+}
+
+// loginKey is the form of an email that failures and throttles are counted
+// against
+func loginKey(email string) string {
+    return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginDelay returns how long logins must wait after the given number of
+// consecutive failures
+func (h *Handlers) loginDelay(failures int) time.Duration {
+    cfg := h.config.LoginProtection
+    if failures <= 0 || cfg.DelayBase <= 0 {
+        return 0
+    }
+    delay := cfg.DelayBase << uint(failures-1)
+    if delay <= 0 || delay > cfg.DelayMax {
+        delay = cfg.DelayMax
+    }
+    return delay
+}
+
+// loginWait returns how long the next login for email has to wait because of
+// earlier failures or a lockout. Zero means it may go ahead.
+func (h *Handlers) loginWait(email string) (time.Duration, error) {
+    var failure models.LoginFailure
+    err := h.db.Where("email = ?", email).First(&failure).Error
+    if err == gorm.ErrRecordNotFound {
+        return 0, nil
+    }
+    if err != nil {
+        return 0, err
+    }
+
+    now := time.Now()
+    if failure.LockedUntil != nil && now.Before(*failure.LockedUntil) {
+        return failure.LockedUntil.Sub(now), nil
+    }
+    if next := failure.LastFailedAt.Add(h.loginDelay(failure.Failures)); now.Before(next) {
+        return next.Sub(now), nil
+    }
+    return 0, nil
+}
+
+// staleLoginFailures selects failure records that no longer count: the last
+// failure is older than a lockout would last and no lockout is in force
+func (h *Handlers) staleLoginFailures(now time.Time) *gorm.DB {
+    return h.db.Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)",
+        now.Add(-h.config.LoginProtection.LockoutDuration), now)
+}
+
+// recordLoginFailure counts a failed login for email. Once the failures reach
+// LOGIN_MAX_ATTEMPTS the email is locked out and the count starts over; it
+// returns when the lockout ends if this failure started one.
+func (h *Handlers) recordLoginFailure(email string) (*time.Time, error) {
+    now := time.Now()
+
+    // Failures long ago do not add up to a lockout
+    if err := h.staleLoginFailures(now).Where("email = ?", email).Delete(&models.LoginFailure{}).Error; err != nil {
+        return nil, err
+    }
+
+    failure := models.LoginFailure{Email: email, Failures: 1, LastFailedAt: now}
+    err := h.db.Clauses(clause.OnConflict{
+        Columns: []clause.Column{{Name: "email"}},
+        DoUpdates: clause.Assignments(map[string]interface{}{
+            "failures":       gorm.Expr("login_failures.failures + 1"),
+            "last_failed_at": now,
+        }),
+    }).Create(&failure).Error
+    if err != nil {
+        return nil, err
+    }
+
+    maxAttempts := h.config.LoginProtection.MaxAttempts
+    if maxAttempts <= 0 {
+        return nil, nil
+    }
+
+    // Only the failure that reaches the limit starts the lockout
+    until := now.Add(h.config.LoginProtection.LockoutDuration)
+    result := h.db.Model(&models.LoginFailure{}).
+        Where("email = ? AND failures >= ?", email, maxAttempts).
+        Updates(map[string]interface{}{
+            "failures":     0,
+            "locked_until": &until,
+        })
+    if result.Error != nil || result.RowsAffected == 0 {
+        return nil, result.Error
+    }
+    return &until, nil
+}
+
+// clearLoginFailures forgets failed logins for email. It reports whether
+// there were any.
+func (h *Handlers) clearLoginFailures(email string) (bool, error) {
+    result := h.db.Where("email = ?", email).Delete(&models.LoginFailure{})
+    return result.RowsAffected > 0, result.Error
+}
+
+// notifyLockout tells the account owner and the audit log that their account
+// was locked after repeated failed logins
+func (h *Handlers) notifyLockout(user *models.User, until time.Time, r *http.Request) {
+    h.notify(user, "Sign-in temporarily locked", fmt.Sprintf(
+        "There were %d failed attempts to sign in to your account, so sign-in is locked until %s. "+
+            "If this was not you, consider resetting your password.",
+        h.config.LoginProtection.MaxAttempts, until.Format(time.RFC1123)))
+    h.logAudit(&user.ID, "ACCOUNT_LOCKED", "AUTH",
+        fmt.Sprintf("Sign-in locked until %s after repeated failed logins", until.Format(time.RFC3339)), r.RemoteAddr, r.UserAgent())
+}
+
+// sendThrottled refuses a request that has to wait, such as a login, telling
+// the client for how long
+func sendThrottled(w http.ResponseWriter, message string, wait time.Duration) {
+    seconds := int(math.Ceil(wait.Seconds()))
+    w.Header().Set("Retry-After", strconv.Itoa(seconds))
+    sendError(w, http.StatusTooManyRequests, message, map[string]int{
+        "retry_after": seconds,
+    })
+}
+
+// UnlockUser clears a user's failed logins and lifts any lockout
+func (h *Handlers) UnlockUser(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    userID, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid user ID", err.Error())
+        return
+    }
+
+    var user models.User
+    if err := h.db.First(&user, userID).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "User not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch user", err.Error())
+        }
+        return
+    }
+
+    cleared, err := h.clearLoginFailures(loginKey(user.Email))
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to unlock user", err.Error())
+        return
+    }
+
+    message := "User was not locked"
+    if cleared {
+        message = "User unlocked"
+        h.logAudit(&claims.UserID, "UNLOCK", "USER",
+            fmt.Sprintf("Cleared failed logins of user %d (%s)", user.ID, user.Email), r.RemoteAddr, r.UserAgent())
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": message,
+    })
+}
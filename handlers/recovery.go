@@ -0,0 +1,288 @@
+package handlers
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "time"
+
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/notify"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// issueOneTimeToken creates a token for the user and purpose, replacing any
+// they still hold, and returns it
+func issueOneTimeToken(tx *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+    token, err := utils.GenerateOpaqueToken()
+    if err != nil {
+        return "", err
+    }
+
+    // Only the newest link works
+    if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+        Delete(&models.OneTimeToken{}).Error; err != nil {
+        return "", err
+    }
+
+    return token, tx.Create(&models.OneTimeToken{
+        UserID:    userID,
+        Purpose:   purpose,
+        TokenHash: utils.HashToken(token),
+        ExpiresAt: time.Now().Add(ttl),
+    }).Error
+}
+
+// claimOneTimeToken marks a token used and returns it, unless it is unknown,
+// for another purpose, expired or already used
+func claimOneTimeToken(tx *gorm.DB, token, purpose string) (*models.OneTimeToken, error) {
+    var record models.OneTimeToken
+    if err := tx.Where("token_hash = ? AND purpose = ?", utils.HashToken(token), purpose).First(&record).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, rejectRequest(http.StatusBadRequest, "Invalid or expired token", nil)
+        }
+        return nil, failRequest("Failed to look up token", err)
+    }
+
+    now := time.Now()
+    result := tx.Model(&models.OneTimeToken{}).
+        Where("id = ? AND used_at IS NULL AND expires_at > ?", record.ID, now).
+        Update("used_at", &now)
+    if result.Error != nil {
+        return nil, failRequest("Failed to use token", result.Error)
+    }
+    if result.RowsAffected == 0 {
+        return nil, rejectRequest(http.StatusBadRequest, "Invalid or expired token", nil)
+    }
+    return &record, nil
+}
+
+// notify sends a message to a user in the background, so a slow or failing
+// provider neither delays the response nor reveals anything through it
+func (h *Handlers) notify(user *models.User, subject, body string) {
+    msg := notify.Message{
+        To:      user.Email,
+        Subject: subject,
+        Body:    fmt.Sprintf("Hello %s,\n\n%s\n\nMiniBank", user.FirstName, body),
+    }
+    go func() {
+        if err := h.notifier.Send(msg); err != nil {
+            log.Printf("Failed to notify %s (%s): %v", msg.To, msg.Subject, err)
+        }
+    }()
+}
+
+// expiresIn describes a link lifetime for an email, such as "1 hour"
+func expiresIn(d time.Duration) string {
+    n, unit := int(d/time.Minute), "minute"
+    if d >= time.Hour && d%time.Hour == 0 {
+        n, unit = int(d/time.Hour), "hour"
+    }
+    if n != 1 {
+        unit += "s"
+    }
+    return fmt.Sprintf("%d %s", n, unit)
+}
+
+// appLink returns a link into the web app carrying a token
+func (h *Handlers) appLink(path, token string) string {
+    return h.config.Notifications.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendVerificationEmail emails the user a link that confirms their address
+func (h *Handlers) sendVerificationEmail(user *models.User, token string) {
+    h.notify(user, "Verify your email address", fmt.Sprintf(
+        "Please confirm your email address by opening this link:\n\n%s\n\nThe link expires in %s.",
+        h.appLink("/verify-email", token), expiresIn(h.config.VerificationTTL)))
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the email belongs to a user.
+func (h *Handlers) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+    var req models.ForgotPasswordRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    // Reset requests count against the login throttles
+    if !h.loginIPs.Allow(middleware.ClientIP(r)) || !h.loginEmails.Allow(loginKey(req.Email)) {
+        sendThrottled(w, "Too many requests, try again later", time.Minute)
+        return
+    }
+
+    var user models.User
+    err := h.db.Where("email = ?", req.Email).First(&user).Error
+    switch {
+    case err == gorm.ErrRecordNotFound:
+        log.Printf("Password reset requested for non-existent email: %s", req.Email)
+    case err != nil:
+        sendError(w, http.StatusInternalServerError, "Failed to process request", err.Error())
+        return
+    case !user.IsActive:
+        log.Printf("Password reset requested for inactive user: %s", req.Email)
+    case user.ServiceAccount:
+        log.Printf("Password reset requested for service account: %s", req.Email)
+    default:
+        token, err := issueOneTimeToken(h.db, user.ID, models.PurposePasswordReset, h.config.PasswordResetTTL)
+        if err != nil {
+            sendError(w, http.StatusInternalServerError, "Failed to process request", err.Error())
+            return
+        }
+
+        h.notify(&user, "Reset your password", fmt.Sprintf(
+            "We received a request to reset your password. Open this link to choose a new one:\n\n%s\n\n"+
+                "The link expires in %s. If you did not ask for this, you can ignore this email.",
+            h.appLink("/reset-password", token), expiresIn(h.config.PasswordResetTTL)))
+        h.logAudit(&user.ID, "PASSWORD_RESET_REQUESTED", "AUTH", "Password reset link sent", r.RemoteAddr, r.UserAgent())
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusAccepted)
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "If the email is registered, a password reset link has been sent",
+    })
+}
+
+// ResetPassword sets a new password with a reset token. Every session the
+// user has is signed out and any login lockout is lifted.
+func (h *Handlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+    var req models.ResetPasswordRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    hashedPassword, err := utils.HashPassword(req.Password)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to hash password", err.Error())
+        return
+    }
+
+    var user models.User
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        record, err := claimOneTimeToken(tx, req.Token, models.PurposePasswordReset)
+        if err != nil {
+            return err
+        }
+
+        if err := tx.First(&user, record.UserID).Error; err != nil {
+            return rejectRequest(http.StatusBadRequest, "Invalid or expired token", nil)
+        }
+        if !user.IsActive {
+            return rejectRequest(http.StatusForbidden, "Account is deactivated", nil)
+        }
+
+        if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("password", hashedPassword).Error; err != nil {
+            return failRequest("Failed to update password", err)
+        }
+        if err := models.RevokeUserTokens(tx, user.ID, "password reset"); err != nil {
+            return failRequest("Failed to sign out sessions", err)
+        }
+        return nil
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    if _, err := h.clearLoginFailures(loginKey(user.Email)); err != nil {
+        log.Printf("Failed to clear failed logins for %s: %v", user.Email, err)
+    }
+
+    h.logAudit(&user.ID, "PASSWORD_RESET", "AUTH", "Password reset with emailed link; all sessions signed out", r.RemoteAddr, r.UserAgent())
+    h.notify(&user, "Your password was changed",
+        "Your MiniBank password was just reset and every device was signed out. "+
+            "If this was not you, contact support immediately.")
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Password has been reset; please log in again",
+    })
+}
+
+// VerifyEmail confirms the user's email address with the token sent to it
+func (h *Handlers) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+    var req models.VerifyEmailRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    var userID uint
+    err := h.db.Transaction(func(tx *gorm.DB) error {
+        record, err := claimOneTimeToken(tx, req.Token, models.PurposeEmailVerification)
+        if err != nil {
+            return err
+        }
+        userID = record.UserID
+
+        if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("verified", true).Error; err != nil {
+            return failRequest("Failed to verify email", err)
+        }
+        return nil
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    h.logAudit(&userID, "EMAIL_VERIFIED", "USER", "Email address verified", r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Email address verified",
+    })
+}
+
+// ResendVerification emails the current user a new verification link
+func (h *Handlers) ResendVerification(w http.ResponseWriter, r *http.Request) {
+    var user models.User
+    if !h.loadCurrentUser(w, r, &user) {
+        return
+    }
+    if user.Verified {
+        sendError(w, http.StatusConflict, "Email address is already verified", nil)
+        return
+    }
+
+    // Each resend mails the user, so it counts against the login throttles
+    // like a reset request does
+    if !h.loginIPs.Allow(middleware.ClientIP(r)) || !h.loginEmails.Allow(loginKey(user.Email)) {
+        sendThrottled(w, "Too many requests, try again later", time.Minute)
+        return
+    }
+
+    token, err := issueOneTimeToken(h.db, user.ID, models.PurposeEmailVerification, h.config.VerificationTTL)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to issue verification link", err.Error())
+        return
+    }
+    h.sendVerificationEmail(&user, token)
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusAccepted)
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Verification email sent",
+    })
+}
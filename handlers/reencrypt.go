@@ -0,0 +1,101 @@
+package handlers
+
+import (
+    "fmt"
+    "log"
+    "time"
+
+    "minibank-go/models"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// reencryptBatchSize is how many KYC records are loaded at a time while
+// re-encrypting
+const reencryptBatchSize = 100
+
+// reencrypt decrypts a value and encrypts it again under the current master
+// key
+func reencrypt(encrypted string) (string, error) {
+    plaintext, err := utils.DecryptSensitiveData(encrypted)
+    if err != nil {
+        return "", err
+    }
+    return utils.EncryptSensitiveData(plaintext)
+}
+
+// ReencryptKYC moves the document numbers of KYC records that are not
+// encrypted under the current master key onto it, including records written
+// before envelope encryption. It returns how many records it rewrote. A
+// record that cannot be decrypted is logged and skipped, so one bad record
+// does not hold up the rest.
+func ReencryptKYC(db *gorm.DB) (int, error) {
+    current, err := utils.CurrentEncryptionKeyID()
+    if err != nil {
+        return 0, err
+    }
+
+    rewritten := 0
+    var lastID uint
+    for {
+        // Deleted records still hold document numbers, so they move too
+        var records []models.KYC
+        if err := db.Unscoped().Where("id > ? AND key_id <> ?", lastID, current).
+            Order("id").Limit(reencryptBatchSize).Find(&records).Error; err != nil {
+            return rewritten, err
+        }
+        if len(records) == 0 {
+            return rewritten, nil
+        }
+
+        for _, kyc := range records {
+            lastID = kyc.ID
+
+            pan, err := reencrypt(kyc.PAN)
+            if err != nil {
+                log.Printf("Failed to re-encrypt PAN of KYC record %d: %v", kyc.ID, err)
+                continue
+            }
+            aadhaar, err := reencrypt(kyc.AadhaarNumber)
+            if err != nil {
+                log.Printf("Failed to re-encrypt Aadhaar number of KYC record %d: %v", kyc.ID, err)
+                continue
+            }
+            passport, err := reencrypt(kyc.PassportNumber)
+            if err != nil {
+                log.Printf("Failed to re-encrypt passport number of KYC record %d: %v", kyc.ID, err)
+                continue
+            }
+
+            // Leave the record alone if it changed since it was read
+            result := db.Unscoped().Model(&models.KYC{}).
+                Where("id = ? AND key_id = ? AND pan = ?", kyc.ID, kyc.KeyID, kyc.PAN).
+                UpdateColumns(map[string]interface{}{
+                    "pan":             pan,
+                    "aadhaar_number":  aadhaar,
+                    "passport_number": passport,
+                    "key_id":          utils.EncryptionKeyID(pan),
+                })
+            if result.Error != nil {
+                return rewritten, fmt.Errorf("failed to update KYC record %d: %v", kyc.ID, result.Error)
+            }
+            rewritten += int(result.RowsAffected)
+        }
+    }
+}
+
+// RunKYCReencryption re-encrypts KYC records under older master keys every
+// interval, so a key rotation reaches existing records without downtime
+func (h *Handlers) RunKYCReencryption(interval time.Duration) {
+    for {
+        time.Sleep(interval)
+        rewritten, err := ReencryptKYC(h.db)
+        if err != nil {
+            log.Printf("KYC re-encryption failed: %v", err)
+        }
+        if rewritten > 0 {
+            log.Printf("Re-encrypted %d KYC records under the current master key", rewritten)
+        }
+    }
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+
+    "minibank-go/ledger"
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/money"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// signedAmount returns how a transaction changed its account's balance
+func signedAmount(txn models.Transaction) money.Amount {
+    switch txn.Type {
+    case "withdraw", "transfer_out", "capture":
+        return -txn.Amount
+    }
+    return txn.Amount
+}
+
+// ReverseTransaction submits the reversal of a completed deposit,
+// withdrawal, transfer or capture, addressed by its reference. It runs once
+// a second staff member approves it.
+func (h *Handlers) ReverseTransaction(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.ReverseTransactionRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        errors := utils.FormatValidationError(err)
+        sendError(w, http.StatusBadRequest, "Validation failed", errors)
+        return
+    }
+
+    h.submitApproval(w, r, claims, opTransactionReverse, req)
+}
+
+// findReversible loads every leg of the transaction with the given reference
+// and checks that it can be reversed
+func findReversible(tx *gorm.DB, reference string) ([]models.Transaction, error) {
+    var originals []models.Transaction
+    if err := tx.Where("reference = ?", reference).Order("id ASC").Find(&originals).Error; err != nil {
+        return nil, failRequest("Failed to fetch transaction", err)
+    }
+    if len(originals) == 0 {
+        return nil, rejectRequest(http.StatusNotFound, "Transaction not found", nil)
+    }
+
+    for _, original := range originals {
+        switch {
+        case original.Type == "reversal":
+            return nil, rejectRequest(http.StatusBadRequest, "A reversal cannot itself be reversed", nil)
+        case original.Status == "reversed":
+            return nil, rejectRequest(http.StatusConflict, "Transaction has already been reversed", nil)
+        case original.Status != "completed":
+            return nil, rejectRequest(http.StatusConflict, "Only completed transactions can be reversed", map[string]string{"status": original.Status})
+        case original.JournalEntryID == nil:
+            return nil, rejectRequest(http.StatusConflict, "Transaction predates the ledger and cannot be reversed", nil)
+        }
+    }
+    return originals, nil
+}
+
+// describeReversal checks a submitted reversal and summarises it for the
+// checker
+func (h *Handlers) describeReversal(tx *gorm.DB, payload json.RawMessage) (string, error) {
+    var req models.ReverseTransactionRequest
+    if err := json.Unmarshal(payload, &req); err != nil {
+        return "", rejectRequest(http.StatusBadRequest, "Invalid request body", err.Error())
+    }
+
+    originals, err := findReversible(tx, req.Reference)
+    if err != nil {
+        return "", err
+    }
+    return fmt.Sprintf("Reverse %s %s of %s: %s", originals[0].Type, req.Reference,
+        originals[0].Amount.String(), req.Reason), nil
+}
+
+// executeReversal undoes an approved reversal. Every leg gets a compensating
+// reversal transaction linked to it, the ledger entry is negated and the
+// originals are marked reversed. An account that has since spent the money
+// cannot be taken negative; the reversal is refused instead.
+func (h *Handlers) executeReversal(tx *gorm.DB, approval *models.ApprovalRequest) (interface{}, string, error) {
+    var req models.ReverseTransactionRequest
+    if err := json.Unmarshal(approval.Payload, &req); err != nil {
+        return nil, "", failRequest("Invalid approval payload", err)
+    }
+
+    originals, err := findReversible(tx, req.Reference)
+    if err != nil {
+        return nil, "", err
+    }
+
+    // Lock every affected account
+    accounts := make([]*models.Account, len(originals))
+    for i, original := range originals {
+        accounts[i] = &models.Account{ID: original.AccountID}
+    }
+    if err := lockAccounts(tx, accounts...); err != nil {
+        return nil, "", failRequest("Failed to lock account records", err)
+    }
+
+    // Apply the compensating balance changes
+    for i, original := range originals {
+        account := accounts[i]
+        if account.Status == "closed" {
+            return nil, "", rejectRequest(http.StatusConflict, "Account is closed", map[string]string{
+                "account_number": account.AccountNumber,
+            })
+        }
+
+        // Money reserved by pending holds cannot be taken back
+        if account.Available-signedAmount(original) < 0 {
+            return nil, "", rejectRequest(http.StatusUnprocessableEntity, "Insufficient funds to reverse transaction", map[string]string{
+                "account_number":    account.AccountNumber,
+                "available_balance": account.Available.String(),
+                "required":          original.Amount.String(),
+            })
+        }
+        account.Balance -= signedAmount(original)
+
+        if err := saveBalance(tx, account); err != nil {
+            return nil, "", failRequest("Failed to update balance", err)
+        }
+    }
+
+    reference := h.generateReference()
+    description := fmt.Sprintf("Reversal of %s: %s", req.Reference, req.Reason)
+
+    // Post the journal entry that negates the original
+    entry, err := ledger.PostReversal(tx, *originals[0].JournalEntryID, reference, description)
+    if err != nil {
+        return nil, "", failRequest("Failed to post ledger entry", err)
+    }
+
+    // Create the compensating transaction records
+    var reversals []models.Transaction
+    originalIDs := make([]uint, len(originals))
+    for i, original := range originals {
+        original := original
+        account := accounts[i]
+        originalIDs[i] = original.ID
+
+        reversal := models.Transaction{
+            AccountID:      account.ID,
+            Type:           "reversal",
+            Amount:         original.Amount,
+            Currency:       original.Currency,
+            BalanceBefore:  account.Balance + signedAmount(original),
+            BalanceAfter:   account.Balance,
+            ToAccountID:    original.FromAccountID,
+            FromAccountID:  original.ToAccountID,
+            Description:    description,
+            Reference:      reference,
+            JournalEntryID: &entry.ID,
+            ReversalOfID:   &original.ID,
+        }
+        if err := tx.Create(&reversal).Error; err != nil {
+            return nil, "", failRequest("Failed to create reversal transaction record", err)
+        }
+        reversals = append(reversals, reversal)
+    }
+
+    // Mark the originals reversed, unless someone beat us to it
+    result := tx.Model(&models.Transaction{}).
+        Where("id IN ? AND status = ?", originalIDs, "completed").
+        Update("status", "reversed")
+    if result.Error != nil {
+        return nil, "", failRequest("Failed to mark transaction reversed", result.Error)
+    }
+    if result.RowsAffected != int64(len(originals)) {
+        return nil, "", errConcurrentUpdate
+    }
+
+    output := map[string]interface{}{
+        "reference": reference,
+        "reversals": reversals,
+    }
+    return output, fmt.Sprintf("Reversed transaction %s as %s: %s", req.Reference, reference, req.Reason), nil
+}
@@ -0,0 +1,604 @@
+package handlers
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/money"
+    "minibank-go/utils"
+
+    "github.com/robfig/cron/v3"
+    "gorm.io/gorm"
+)
+
+// errScheduleChanged means a scheduled transfer was run, edited or cancelled
+// after it was read. The stale copy is dropped rather than retried.
+var errScheduleChanged = errors.New("scheduled transfer was modified concurrently")
+
+// nextRunAfter returns when a recurring transfer is next due after t, or nil
+// once it has no occurrences left. One-off transfers never recur.
+func nextRunAfter(st *models.ScheduledTransfer, t time.Time) (*time.Time, error) {
+    if st.Schedule == "" {
+        return nil, nil
+    }
+    if st.MaxOccurrences > 0 && st.Occurrences >= st.MaxOccurrences {
+        return nil, nil
+    }
+
+    schedule, err := cron.ParseStandard(st.Schedule)
+    if err != nil {
+        return nil, err
+    }
+    next := schedule.Next(t)
+    if next.IsZero() || (st.EndAt != nil && next.After(*st.EndAt)) {
+        return nil, nil
+    }
+    return &next, nil
+}
+
+// updateScheduledTransfer writes updates to st only if nobody else has
+// changed it since it was read
+func updateScheduledTransfer(tx *gorm.DB, st *models.ScheduledTransfer, updates map[string]interface{}) error {
+    updates["version"] = gorm.Expr("version + 1")
+    result := tx.Model(&models.ScheduledTransfer{}).
+        Where("id = ? AND version = ?", st.ID, st.Version).
+        Updates(updates)
+    if result.Error != nil {
+        return result.Error
+    }
+    if result.RowsAffected == 0 {
+        return errScheduleChanged
+    }
+    st.Version++
+    return nil
+}
+
+// findScheduledTransfer loads one of the user's scheduled transfers
+func (h *Handlers) findScheduledTransfer(w http.ResponseWriter, r *http.Request, userID uint, st *models.ScheduledTransfer) bool {
+    id, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid scheduled transfer ID", err.Error())
+        return false
+    }
+
+    if err := h.db.Where("id = ? AND user_id = ?", id, userID).First(st).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Scheduled transfer not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch scheduled transfer", err.Error())
+        }
+        return false
+    }
+    return true
+}
+
+// ListScheduledTransfers returns the caller's scheduled transfers
+func (h *Handlers) ListScheduledTransfers(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    query := h.db.Where("user_id = ?", claims.UserID)
+    if status := r.URL.Query().Get("status"); status != "" {
+        query = query.Where("status = ?", status)
+    }
+
+    var transfers []models.ScheduledTransfer
+    if err := query.Order("created_at DESC").Find(&transfers).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to fetch scheduled transfers", err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(transfers)
+}
+
+// GetScheduledTransfer returns one scheduled transfer with its run history
+func (h *Handlers) GetScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var st models.ScheduledTransfer
+    if !h.findScheduledTransfer(w, r, claims.UserID, &st) {
+        return
+    }
+    if err := h.db.Where("scheduled_transfer_id = ?", st.ID).Order("id DESC").Find(&st.Runs).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to fetch scheduled transfer runs", err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(st)
+}
+
+// CreateScheduledTransfer sets up a one-off transfer at start_at or a
+// recurring one on a cron schedule. The payee is resolved now, so later runs
+// always pay the same account.
+func (h *Handlers) CreateScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.CreateScheduledTransferRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        errors := utils.FormatValidationError(err)
+        sendError(w, http.StatusBadRequest, "Validation failed", errors)
+        return
+    }
+
+    now := time.Now()
+    startAt := now
+    if req.StartAt != nil {
+        startAt = *req.StartAt
+    }
+    if req.Schedule == "" && !startAt.After(now) {
+        sendError(w, http.StatusBadRequest, "start_at must be in the future", nil)
+        return
+    }
+    if req.EndAt != nil && req.EndAt.Before(startAt) {
+        sendError(w, http.StatusBadRequest, "end_at must be after start_at", nil)
+        return
+    }
+
+    // Each run pays without the user present, so large ones are confirmed now
+    if err := h.requireStepUp(r, claims.UserID, req.Amount); err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    var fromAccount, toAccount models.Account
+    if err := ownAccountQuery(h.db, claims.UserID, req.FromAccountID).First(&fromAccount).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Source account not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch sender account", err.Error())
+        }
+        return
+    }
+
+    recipientQuery := ownAccountQuery(h.db, claims.UserID, req.ToAccountID)
+    if req.ToAccountID == 0 {
+        if !h.allowPayeeLookup(claims.UserID) {
+            sendThrottled(w, "Too many payee lookups, try again later", time.Minute)
+            return
+        }
+        query, err := payeeQuery(h.db, req.To)
+        if err != nil {
+            sendError(w, http.StatusBadRequest, "Invalid payee", err.Error())
+            return
+        }
+        recipientQuery = query
+    }
+    if err := recipientQuery.First(&toAccount).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Recipient account not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch recipient account", err.Error())
+        }
+        return
+    }
+
+    if fromAccount.ID == toAccount.ID {
+        sendError(w, http.StatusBadRequest, "Cannot transfer to the same account", nil)
+        return
+    }
+    if fromAccount.Status != "active" || toAccount.Status != "active" {
+        sendError(w, http.StatusForbidden, "Account is not active", nil)
+        return
+    }
+    if fromAccount.Currency != toAccount.Currency {
+        sendError(w, http.StatusBadRequest, "Currency mismatch", map[string]string{
+            "from_currency": string(fromAccount.Currency),
+            "to_currency":   string(toAccount.Currency),
+        })
+        return
+    }
+
+    st := models.ScheduledTransfer{
+        UserID:         claims.UserID,
+        FromAccountID:  fromAccount.ID,
+        Amount:         req.Amount,
+        Currency:       fromAccount.Currency,
+        Description:    req.Description,
+        Schedule:       req.Schedule,
+        StartAt:        startAt,
+        EndAt:          req.EndAt,
+        MaxOccurrences: req.MaxOccurrences,
+        Status:         "active",
+    }
+    if toAccount.UserID == claims.UserID {
+        st.ToAccountID = &toAccount.ID
+    } else {
+        st.To = toAccount.AccountNumber
+    }
+
+    // A recurring transfer is first due at its first occurrence from start_at
+    st.NextRunAt = &startAt
+    if st.Schedule != "" {
+        next, err := nextRunAfter(&st, startAt.Add(-time.Second))
+        if err != nil {
+            sendError(w, http.StatusBadRequest, "Invalid schedule", err.Error())
+            return
+        }
+        if next == nil {
+            sendError(w, http.StatusBadRequest, "Schedule has no occurrences before end_at", nil)
+            return
+        }
+        st.NextRunAt = next
+    }
+
+    if err := h.db.Create(&st).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to create scheduled transfer", err.Error())
+        return
+    }
+
+    h.logAudit(&claims.UserID, "CREATE", "SCHEDULED_TRANSFER",
+        fmt.Sprintf("Scheduled %s from account %s to account %s, first due %s", st.Amount, fromAccount.AccountNumber, toAccount.AccountNumber, st.NextRunAt.Format(time.RFC3339)), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(st)
+}
+
+// UpdateScheduledTransfer changes the amount, description or schedule of an
+// active or paused transfer, or pauses and resumes it
+func (h *Handlers) UpdateScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var st models.ScheduledTransfer
+    if !h.findScheduledTransfer(w, r, claims.UserID, &st) {
+        return
+    }
+
+    var req models.UpdateScheduledTransferRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    if st.Status != "active" && st.Status != "paused" {
+        sendError(w, http.StatusConflict, "Scheduled transfer has finished", map[string]string{"status": st.Status})
+        return
+    }
+
+    updates := map[string]interface{}{}
+    reschedule := false
+    if req.Amount != nil {
+        if *req.Amount < money.FromMajor(1) {
+            sendError(w, http.StatusBadRequest, "Amount must be at least 1.00", nil)
+            return
+        }
+        if *req.Amount > st.Amount {
+            if err := h.requireStepUp(r, claims.UserID, *req.Amount); err != nil {
+                sendRequestError(w, err)
+                return
+            }
+        }
+        st.Amount = *req.Amount
+        updates["amount"] = st.Amount
+    }
+    if req.Description != nil {
+        st.Description = *req.Description
+        updates["description"] = st.Description
+    }
+    if req.Schedule != nil {
+        if st.Schedule == "" || *req.Schedule == "" {
+            sendError(w, http.StatusBadRequest, "Only the schedule of a recurring transfer can be changed", nil)
+            return
+        }
+        if _, err := cron.ParseStandard(*req.Schedule); err != nil {
+            sendError(w, http.StatusBadRequest, "Invalid schedule", err.Error())
+            return
+        }
+        st.Schedule = *req.Schedule
+        updates["schedule"] = st.Schedule
+        reschedule = true
+    }
+    if req.EndAt != nil {
+        st.EndAt = req.EndAt
+        updates["end_at"] = st.EndAt
+        reschedule = true
+    }
+    if req.MaxOccurrences != nil {
+        st.MaxOccurrences = *req.MaxOccurrences
+        updates["max_occurrences"] = st.MaxOccurrences
+        reschedule = true
+    }
+    if req.Status != "" && req.Status != st.Status {
+        st.Status = req.Status
+        updates["status"] = st.Status
+        reschedule = reschedule || st.Status == "active"
+    }
+
+    // Recurring transfers pick up from the next occurrence after now
+    if reschedule && st.Schedule != "" {
+        next, err := nextRunAfter(&st, time.Now())
+        if err != nil {
+            sendError(w, http.StatusBadRequest, "Invalid schedule", err.Error())
+            return
+        }
+        st.NextRunAt = next
+        st.Attempts = 0
+        updates["next_run_at"] = st.NextRunAt
+        updates["attempts"] = 0
+        if next == nil {
+            st.Status = "completed"
+            updates["status"] = st.Status
+        }
+    }
+
+    if len(updates) > 0 {
+        if err := updateScheduledTransfer(h.db, &st, updates); err != nil {
+            if err == errScheduleChanged {
+                sendError(w, http.StatusConflict, "Scheduled transfer was updated by another request, please retry", nil)
+            } else {
+                sendError(w, http.StatusInternalServerError, "Failed to update scheduled transfer", err.Error())
+            }
+            return
+        }
+    }
+
+    h.logAudit(&claims.UserID, "UPDATE", "SCHEDULED_TRANSFER",
+        fmt.Sprintf("Updated scheduled transfer %d", st.ID), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(st)
+}
+
+// CancelScheduledTransfer stops a scheduled transfer for good. Its history
+// is kept.
+func (h *Handlers) CancelScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var st models.ScheduledTransfer
+    if !h.findScheduledTransfer(w, r, claims.UserID, &st) {
+        return
+    }
+
+    if st.Status != "active" && st.Status != "paused" {
+        sendError(w, http.StatusConflict, "Scheduled transfer has finished", map[string]string{"status": st.Status})
+        return
+    }
+
+    if err := updateScheduledTransfer(h.db, &st, map[string]interface{}{
+        "status":      "cancelled",
+        "next_run_at": nil,
+    }); err != nil {
+        if err == errScheduleChanged {
+            sendError(w, http.StatusConflict, "Scheduled transfer was updated by another request, please retry", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to cancel scheduled transfer", err.Error())
+        }
+        return
+    }
+
+    h.logAudit(&claims.UserID, "CANCEL", "SCHEDULED_TRANSFER",
+        fmt.Sprintf("Cancelled scheduled transfer %d", st.ID), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Scheduled transfer cancelled",
+    })
+}
+
+// runScheduledTransfer makes the transfer that is due for st. The schedule
+// is advanced in the same database transaction as the money moves, so an
+// occurrence is paid at most once however often the server restarts or
+// however many instances are running. Nothing is paid while the owner is
+// deactivated; the attempt fails and is retried like any other.
+func (h *Handlers) runScheduledTransfer(st models.ScheduledTransfer) error {
+    dueAt := *st.NextRunAt
+    req := models.TransferRequest{
+        FromAccountID: st.FromAccountID,
+        To:            st.To,
+        Amount:        st.Amount,
+        Description:   st.Description,
+    }
+    if st.ToAccountID != nil {
+        req.ToAccountID = *st.ToAccountID
+    }
+    if req.Description == "" {
+        req.Description = fmt.Sprintf("Scheduled transfer %d", st.ID)
+    }
+
+    var result *transferResult
+    err := h.checkTransferLimits(st.UserID, st.Amount)
+    if err == nil {
+        err = retryOnConflict(func() error {
+            current := st
+            return h.db.Transaction(func(tx *gorm.DB) error {
+                now := time.Now()
+                settled := current
+                settled.Occurrences++
+                next, err := nextRunAfter(&settled, laterOf(dueAt, now))
+                if err != nil {
+                    return err
+                }
+
+                updates := map[string]interface{}{
+                    "occurrences": settled.Occurrences,
+                    "attempts":    0,
+                    "next_run_at": next,
+                    "last_run_at": &now,
+                    "last_error":  "",
+                }
+                if next == nil {
+                    updates["status"] = "completed"
+                }
+                if err := updateScheduledTransfer(tx.Where("status = ?", "active"), &current, updates); err != nil {
+                    return err
+                }
+
+                // A deactivated customer's schedules fail rather than keep
+                // paying out while their tokens are revoked
+                var owner models.User
+                if err := tx.Select("id", "is_active").First(&owner, current.UserID).Error; err != nil {
+                    if err == gorm.ErrRecordNotFound {
+                        return rejectRequest(http.StatusForbidden, "Account holder not found", nil)
+                    }
+                    return failRequest("Failed to fetch account holder", err)
+                }
+                if !owner.IsActive {
+                    return rejectRequest(http.StatusForbidden, "Account is deactivated", nil)
+                }
+
+                result, err = h.postTransfer(tx, current.UserID, req)
+                if err != nil {
+                    return err
+                }
+
+                return tx.Create(&models.ScheduledTransferRun{
+                    ScheduledTransferID: current.ID,
+                    Occurrence:          settled.Occurrences,
+                    Attempt:             current.Attempts + 1,
+                    DueAt:               dueAt,
+                    Status:              "succeeded",
+                    Reference:           result.Sent.Reference,
+                }).Error
+            })
+        })
+    }
+    switch {
+    case err == errScheduleChanged:
+        return nil
+    case err != nil:
+        return h.recordScheduledFailure(st, dueAt, err)
+    }
+
+    from, to := result.From, result.To
+    h.logAudit(&st.UserID, "transfer_out", "transaction", fmt.Sprintf("Scheduled transfer %d sent %s from account %s to account %s", st.ID, st.Amount, from.AccountNumber, to.AccountNumber), "", "scheduler")
+    h.logAudit(&to.UserID, "transfer_in", "transaction", fmt.Sprintf("Received %s into account %s from account %s", st.Amount, to.AccountNumber, from.AccountNumber), "", "scheduler")
+    return nil
+}
+
+// recordScheduledFailure notes a failed attempt and schedules a retry with
+// exponential backoff. Once the retries run out, or a retry would run into
+// the next occurrence, the occurrence is given up as missed.
+func (h *Handlers) recordScheduledFailure(st models.ScheduledTransfer, dueAt time.Time, cause error) error {
+    now := time.Now()
+    attempt := st.Attempts + 1
+
+    updates := map[string]interface{}{
+        "attempts":    attempt,
+        "last_run_at": &now,
+        "last_error":  cause.Error(),
+    }
+
+    settled := st
+    settled.Occurrences++
+    next, err := nextRunAfter(&settled, laterOf(dueAt, now))
+    if err != nil {
+        return err
+    }
+
+    retryAt := now.Add(h.config.Scheduler.RetryDelay << uint(attempt-1))
+    missed := attempt > h.config.Scheduler.RetryLimit || (next != nil && !retryAt.Before(*next))
+    if missed {
+        updates["occurrences"] = settled.Occurrences
+        updates["attempts"] = 0
+        updates["next_run_at"] = next
+        if next == nil {
+            updates["status"] = "failed"
+        }
+    } else {
+        updates["next_run_at"] = &retryAt
+    }
+
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        if err := updateScheduledTransfer(tx.Where("status = ?", "active"), &st, updates); err != nil {
+            return err
+        }
+        return tx.Create(&models.ScheduledTransferRun{
+            ScheduledTransferID: st.ID,
+            Occurrence:          settled.Occurrences,
+            Attempt:             attempt,
+            DueAt:               dueAt,
+            Status:              "failed",
+            Error:               cause.Error(),
+        }).Error
+    })
+    if err == errScheduleChanged {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("failed to record failure of scheduled transfer %d: %w", st.ID, err)
+    }
+
+    outcome := fmt.Sprintf("retrying at %s", retryAt.Format(time.RFC3339))
+    if missed {
+        outcome = "occurrence missed"
+    }
+    h.logAudit(&st.UserID, "SCHEDULED_TRANSFER_FAILED", "SCHEDULED_TRANSFER",
+        fmt.Sprintf("Scheduled transfer %d attempt %d failed (%s): %s", st.ID, attempt, outcome, cause.Error()), "", "scheduler")
+    return nil
+}
+
+func laterOf(a, b time.Time) time.Time {
+    if a.After(b) {
+        return a
+    }
+    return b
+}
+
+// ExecuteDueTransfers runs every active scheduled transfer that is due and
+// returns how many were attempted
+func (h *Handlers) ExecuteDueTransfers() (int, error) {
+    var due []models.ScheduledTransfer
+    if err := h.db.Where("status = ? AND next_run_at <= ?", "active", time.Now()).
+        Order("next_run_at ASC").Limit(100).Find(&due).Error; err != nil {
+        return 0, fmt.Errorf("failed to find due scheduled transfers: %w", err)
+    }
+
+    for _, st := range due {
+        if err := h.runScheduledTransfer(st); err != nil {
+            return 0, err
+        }
+    }
+    return len(due), nil
+}
+
+// RunScheduler executes due scheduled transfers every interval. It never
+// returns.
+func (h *Handlers) RunScheduler(interval time.Duration) {
+    for {
+        time.Sleep(interval)
+        attempted, err := h.ExecuteDueTransfers()
+        if err != nil {
+            log.Printf("Scheduled transfers failed: %v", err)
+        }
+        if attempted > 0 {
+            log.Printf("Ran %d scheduled transfers", attempted)
+        }
+    }
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+    "strings"
+    "testing"
+    "time"
+
+    "minibank-go/models"
+    "minibank-go/money"
+)
+
+func TestScheduledTransferSkipsDeactivatedOwner(t *testing.T) {
+    h := newTestHandlers(t)
+    from := newTestCustomer(t, h, 1, money.FromMajor(1000))
+    to := newTestCustomer(t, h, 2, 0)
+
+    due := time.Now().Add(-time.Minute)
+    st := models.ScheduledTransfer{
+        UserID:        from.UserID,
+        FromAccountID: from.ID,
+        To:            to.AccountNumber,
+        Amount:        money.FromMajor(100),
+        Currency:      money.DefaultCurrency,
+        StartAt:       due,
+        NextRunAt:     &due,
+        Status:        "active",
+        Version:       1,
+    }
+    if err := h.db.Create(&st).Error; err != nil {
+        t.Fatalf("create scheduled transfer: %v", err)
+    }
+
+    var owner models.User
+    if err := h.db.First(&owner, from.UserID).Error; err != nil {
+        t.Fatalf("load owner: %v", err)
+    }
+    if err := h.db.Model(&owner).Update("is_active", false).Error; err != nil {
+        t.Fatalf("deactivate owner: %v", err)
+    }
+
+    if _, err := h.ExecuteDueTransfers(); err != nil {
+        t.Fatalf("execute due transfers: %v", err)
+    }
+
+    if balance := reloadAccount(t, h, from.ID).Balance; balance != money.FromMajor(1000) {
+        t.Errorf("sender balance = %s, want 1000.00", balance)
+    }
+    if err := h.db.First(&st, st.ID).Error; err != nil {
+        t.Fatalf("reload scheduled transfer: %v", err)
+    }
+    if st.Attempts != 1 || !strings.Contains(st.LastError, "deactivated") {
+        t.Errorf("attempts = %d, last error = %q, want a failed attempt for a deactivated owner", st.Attempts, st.LastError)
+    }
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "regexp"
+    "strings"
+    "time"
+
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// serviceAccountName is the form service account names take, such as
+// nightly-reconcile
+var serviceAccountName = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// serviceAccountPassword is stored in place of a password hash. It is not a
+// valid bcrypt hash, so no password ever matches it.
+const serviceAccountPassword = "!"
+
+// newAPIKey returns a fresh key together with its prefix and secret
+func newAPIKey() (key, prefix, secret string, err error) {
+    b := make([]byte, 6)
+    if _, err := rand.Read(b); err != nil {
+        return "", "", "", fmt.Errorf("failed to generate key prefix: %v", err)
+    }
+    prefix = hex.EncodeToString(b)
+    secret, err = utils.GenerateOpaqueToken()
+    if err != nil {
+        return "", "", "", err
+    }
+    return models.APIKeyPrefix + "_" + prefix + "_" + secret, prefix, secret, nil
+}
+
+// loadServiceAccount fetches the service account named by the path, writing
+// the error response if there is none
+func (h *Handlers) loadServiceAccount(w http.ResponseWriter, r *http.Request, account *models.User) bool {
+    id, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid service account ID", err.Error())
+        return false
+    }
+    if err := h.db.Where("id = ? AND service_account = ?", id, true).First(account).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Service account not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch service account", err.Error())
+        }
+        return false
+    }
+    return true
+}
+
+// ListServiceAccounts returns every service account with its API keys,
+// newest first
+func (h *Handlers) ListServiceAccounts(w http.ResponseWriter, r *http.Request) {
+    var accounts []models.User
+    if err := h.db.Where("service_account = ?", true).Order("created_at DESC").Find(&accounts).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to fetch service accounts", err.Error())
+        return
+    }
+
+    ids := make([]uint, len(accounts))
+    for i, account := range accounts {
+        ids[i] = account.ID
+    }
+    var keys []models.APIKey
+    if len(ids) > 0 {
+        if err := h.db.Where("user_id IN ?", ids).Order("created_at DESC").Find(&keys).Error; err != nil {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch API keys", err.Error())
+            return
+        }
+    }
+
+    response := make([]models.ServiceAccountResponse, len(accounts))
+    for i, account := range accounts {
+        response[i] = models.ServiceAccountResponse{User: account, APIKeys: []models.APIKey{}}
+        for _, key := range keys {
+            if key.UserID == account.ID {
+                response[i].APIKeys = append(response[i].APIKeys, key)
+            }
+        }
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// CreateServiceAccount adds a service account with a staff role. It has no
+// password or email and can only act through API keys.
+func (h *Handlers) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var req models.CreateServiceAccountRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+    if !serviceAccountName.MatchString(req.Name) {
+        sendError(w, http.StatusBadRequest, "Name may only contain lowercase letters, digits and dashes", req.Name)
+        return
+    }
+    if !models.IsStaffRole(req.Role) {
+        sendError(w, http.StatusBadRequest, "Service accounts need a staff role", req.Role)
+        return
+    }
+
+    // The email and phone columns are unique and required, so service
+    // accounts get placeholders on reserved names that can never be a person's
+    account := models.User{
+        Email:          req.Name + "@service-accounts.invalid",
+        Phone:          "svc-" + req.Name,
+        Password:       serviceAccountPassword,
+        FirstName:      req.Name,
+        LastName:       "Service Account",
+        IsActive:       true,
+        Role:           req.Role,
+        KYCStatus:      "pending",
+        ServiceAccount: true,
+    }
+
+    var existing int64
+    if err := h.db.Model(&models.User{}).Where("email = ? OR phone = ?", account.Email, account.Phone).Count(&existing).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to check service account", err.Error())
+        return
+    }
+    if existing > 0 {
+        sendError(w, http.StatusConflict, "A service account with this name already exists", nil)
+        return
+    }
+
+    if err := h.db.Create(&account).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to create service account", err.Error())
+        return
+    }
+
+    h.logAudit(&claims.UserID, "CREATE", "SERVICE_ACCOUNT",
+        fmt.Sprintf("Created service account %d (%s) as %s", account.ID, req.Name, account.Role), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(models.ServiceAccountResponse{
+        User:    account,
+        APIKeys: []models.APIKey{},
+    })
+}
+
+// CreateAPIKey issues an API key for a service account. Its scopes must be
+// permissions that both the account's role and the caller hold. The key is
+// only returned here; only a hash of its secret is stored.
+func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var account models.User
+    if !h.loadServiceAccount(w, r, &account) {
+        return
+    }
+    if !account.IsActive {
+        sendError(w, http.StatusConflict, "Service account is deactivated", nil)
+        return
+    }
+
+    var req models.CreateAPIKeyRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    granted := models.ScopeList(models.RolePermissions(account.Role))
+    var scopes models.ScopeList
+    for _, scope := range req.Scopes {
+        switch {
+        case !models.IsPermission(scope):
+            sendError(w, http.StatusBadRequest, "Unknown scope", scope)
+            return
+        case !granted.Has(scope):
+            sendError(w, http.StatusBadRequest, "The service account's role does not grant this scope", scope)
+            return
+        case !claims.HasPermission(scope):
+            sendError(w, http.StatusForbidden, "You cannot grant a scope you do not hold", scope)
+            return
+        }
+        if !scopes.Has(scope) {
+            scopes = append(scopes, scope)
+        }
+    }
+
+    rateLimit := req.RateLimit
+    if rateLimit == 0 {
+        rateLimit = h.config.APIKeyRateLimit
+    }
+    expiresAt := time.Now().Add(h.config.APIKeyTTL)
+    if req.ExpiresAt != nil {
+        if !req.ExpiresAt.After(time.Now()) {
+            sendError(w, http.StatusBadRequest, "expires_at must be in the future", nil)
+            return
+        }
+        expiresAt = *req.ExpiresAt
+    }
+
+    key, prefix, secret, err := newAPIKey()
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to generate API key", err.Error())
+        return
+    }
+
+    record := models.APIKey{
+        UserID:      account.ID,
+        Name:        req.Name,
+        Prefix:      prefix,
+        SecretHash:  utils.HashToken(secret),
+        Scopes:      scopes,
+        RateLimit:   rateLimit,
+        ExpiresAt:   expiresAt,
+        CreatedByID: claims.UserID,
+    }
+    if err := h.db.Create(&record).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to create API key", err.Error())
+        return
+    }
+
+    h.logAudit(&claims.UserID, "CREATE", "API_KEY",
+        fmt.Sprintf("Issued API key %s (%s) to service account %d with scopes %s", record.Prefix, record.Name, account.ID, strings.Join(scopes, ", ")),
+        r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(models.APIKeyResponse{
+        APIKey: record,
+        Key:    key,
+    })
+}
+
+// RevokeAPIKey stops an API key from working
+func (h *Handlers) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    id, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid API key ID", err.Error())
+        return
+    }
+
+    var key models.APIKey
+    if err := h.db.First(&key, id).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "API key not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch API key", err.Error())
+        }
+        return
+    }
+
+    now := time.Now()
+    result := h.db.Model(&models.APIKey{}).Where("id = ? AND revoked_at IS NULL", key.ID).Update("revoked_at", &now)
+    if result.Error != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to revoke API key", result.Error.Error())
+        return
+    }
+    if result.RowsAffected == 0 {
+        sendError(w, http.StatusConflict, "API key has already been revoked", nil)
+        return
+    }
+
+    h.logAudit(&claims.UserID, "REVOKE", "API_KEY",
+        fmt.Sprintf("Revoked API key %s (%s) of service account %d", key.Prefix, key.Name, key.UserID), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "API key revoked",
+    })
+}
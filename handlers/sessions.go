@@ -0,0 +1,134 @@
+package handlers
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "time"
+
+    "minibank-go/middleware"
+    "minibank-go/models"
+
+    "gorm.io/gorm"
+)
+
+// activeSessions narrows a query to the user's sessions that can still be used
+func activeSessions(tx *gorm.DB, userID uint) *gorm.DB {
+    return tx.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now())
+}
+
+// ListSessions returns the devices the current user is logged in on, most
+// recently used first
+func (h *Handlers) ListSessions(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var sessions []models.Session
+    if err := activeSessions(h.db, claims.UserID).Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to fetch sessions", err.Error())
+        return
+    }
+    for i := range sessions {
+        sessions[i].Current = sessions[i].ID == claims.SessionID
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeSession signs one of the current user's devices out. Revoking the
+// current session logs the caller out.
+func (h *Handlers) RevokeSession(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    id, err := accountIDFromPath(r)
+    if err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid session ID", err.Error())
+        return
+    }
+
+    var session models.Session
+    if err := h.db.Where("id = ? AND user_id = ?", id, claims.UserID).First(&session).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "Session not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch session", err.Error())
+        }
+        return
+    }
+    if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+        sendError(w, http.StatusConflict, "Session has already ended", nil)
+        return
+    }
+
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        if err := models.RevokeTokens(tx, "session revoked", "family_id = ?", session.FamilyID); err != nil {
+            return err
+        }
+        // A session whose refresh tokens are all spent is ended directly
+        now := time.Now()
+        return tx.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", session.ID).Update("revoked_at", &now).Error
+    })
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to revoke session", err.Error())
+        return
+    }
+
+    h.logAudit(&claims.UserID, "REVOKE", "SESSION",
+        fmt.Sprintf("Signed out session %d (%s, %s)", session.ID, session.DeviceName, session.IPAddress), r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Session revoked",
+    })
+}
+
+// RevokeOtherSessions signs the current user out on every device but the
+// one making the request
+func (h *Handlers) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return
+    }
+
+    var revoked int64
+    err := h.db.Transaction(func(tx *gorm.DB) error {
+        var families []string
+        if err := activeSessions(tx.Model(&models.Session{}), claims.UserID).
+            Where("id <> ?", claims.SessionID).Pluck("family_id", &families).Error; err != nil {
+            return err
+        }
+        revoked = int64(len(families))
+        if revoked == 0 {
+            return nil
+        }
+        if err := models.RevokeTokens(tx, "signed out elsewhere", "family_id IN ?", families); err != nil {
+            return err
+        }
+        now := time.Now()
+        return tx.Model(&models.Session{}).Where("family_id IN ? AND revoked_at IS NULL", families).Update("revoked_at", &now).Error
+    })
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to revoke sessions", err.Error())
+        return
+    }
+
+    if revoked > 0 {
+        h.logAudit(&claims.UserID, "REVOKE", "SESSION",
+            fmt.Sprintf("Signed out %d other session(s)", revoked), r.RemoteAddr, r.UserAgent())
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "message": "Other sessions revoked",
+        "revoked": revoked,
+    })
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "net/http"
+    "time"
+
+    "minibank-go/models"
+    "minibank-go/money"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+)
+
+// Codes in the details of errors from step-up checks
+const (
+    stepUpRequired = "step_up_required"
+    stepUpFailed   = "step_up_failed"
+    stepUpLocked   = "step_up_locked"
+)
+
+// guessablePIN reports whether a PIN is one digit repeated or a run of
+// consecutive digits, such as 1111 or 4321
+func guessablePIN(pin string) bool {
+    repeated, ascending, descending := true, true, true
+    for i := 1; i < len(pin); i++ {
+        diff := int(pin[i]) - int(pin[i-1])
+        repeated = repeated && diff == 0
+        ascending = ascending && diff == 1
+        descending = descending && diff == -1
+    }
+    return repeated || ascending || descending
+}
+
+// stepUpMethods lists the step-up checks the user can complete
+func stepUpMethods(user *models.User) []string {
+    methods := []string{}
+    if user.HasTransactionPIN() {
+        methods = append(methods, models.StepUpPIN)
+    }
+    if user.TwoFactorEnabled {
+        methods = append(methods, models.StepUpTOTP)
+    }
+    return methods
+}
+
+// stepUpChallenge describes how the user can complete step-up, for the
+// details of an error
+func (h *Handlers) stepUpChallenge(user *models.User, code string) models.StepUpChallenge {
+    return models.StepUpChallenge{
+        Code:      code,
+        Methods:   stepUpMethods(user),
+        Threshold: h.config.StepUp.Threshold,
+    }
+}
+
+// stepUpLockedError refuses a step-up check while the user is locked out of
+// it, or returns nil if they are not
+func (h *Handlers) stepUpLockedError(user *models.User) error {
+    now := time.Now()
+    if user.StepUpLockedUntil == nil || !now.Before(*user.StepUpLockedUntil) {
+        return nil
+    }
+    challenge := h.stepUpChallenge(user, stepUpLocked)
+    challenge.RetryAfter = int(math.Ceil(user.StepUpLockedUntil.Sub(now).Seconds()))
+    return rejectRequest(http.StatusTooManyRequests, "Too many failed attempts, try again later", challenge)
+}
+
+// recordStepUpFailure counts a wrong PIN, code or password against the
+// user. Once the failures reach STEP_UP_MAX_ATTEMPTS step-up is locked and
+// the count starts over. It returns the error to refuse the request with.
+func (h *Handlers) recordStepUpFailure(r *http.Request, user *models.User, message string) error {
+    if err := h.db.Model(&models.User{}).Where("id = ?", user.ID).
+        UpdateColumn("step_up_failures", gorm.Expr("step_up_failures + 1")).Error; err != nil {
+        return failRequest("Failed to record failed attempt", err)
+    }
+    user.StepUpFailures++
+
+    challenge := h.stepUpChallenge(user, stepUpFailed)
+    maxAttempts := h.config.StepUp.MaxAttempts
+    if maxAttempts <= 0 {
+        return rejectRequest(http.StatusForbidden, message, challenge)
+    }
+
+    // Only the failure that reaches the limit starts the lockout
+    until := time.Now().Add(h.config.StepUp.LockoutDuration)
+    result := h.db.Model(&models.User{}).
+        Where("id = ? AND step_up_failures >= ?", user.ID, maxAttempts).
+        UpdateColumns(map[string]interface{}{
+            "step_up_failures":     0,
+            "step_up_locked_until": &until,
+        })
+    if result.Error != nil {
+        return failRequest("Failed to record failed attempt", result.Error)
+    }
+    if result.RowsAffected > 0 {
+        user.StepUpFailures, user.StepUpLockedUntil = 0, &until
+        h.notify(user, "Payment confirmation locked", fmt.Sprintf(
+            "There were %d failed attempts to confirm a payment or change your transaction PIN, so large payments are blocked until %s. "+
+                "If this was not you, change your password and sign out your other devices.",
+            maxAttempts, until.Format(time.RFC1123)))
+        h.logAudit(&user.ID, "STEP_UP_LOCKED", "AUTH",
+            fmt.Sprintf("Step-up locked until %s after repeated failures", until.Format(time.RFC3339)), r.RemoteAddr, r.UserAgent())
+        return h.stepUpLockedError(user)
+    }
+
+    remaining := maxAttempts - user.StepUpFailures
+    if remaining < 1 {
+        remaining = 1
+    }
+    challenge.AttemptsRemaining = &remaining
+    return rejectRequest(http.StatusForbidden, message, challenge)
+}
+
+// clearStepUpFailures forgets earlier failures after a successful check
+func (h *Handlers) clearStepUpFailures(user *models.User) error {
+    if user.StepUpFailures == 0 {
+        return nil
+    }
+    if err := h.db.Model(&models.User{}).Where("id = ?", user.ID).
+        UpdateColumn("step_up_failures", 0).Error; err != nil {
+        return failRequest("Failed to reset failed attempts", err)
+    }
+    user.StepUpFailures = 0
+    return nil
+}
+
+// requireStepUp checks the transaction PIN or TOTP code sent in the
+// X-Step-Up-PIN or X-Step-Up-TOTP header of a payment of amount from the
+// user. Payments up to STEP_UP_THRESHOLD need neither. Failures count
+// towards a lockout of their own, apart from the login lockout, and the
+// error details carry a code clients can act on.
+func (h *Handlers) requireStepUp(r *http.Request, userID uint, amount money.Amount) error {
+    if amount <= h.config.StepUp.Threshold {
+        return nil
+    }
+
+    stepUp := models.StepUp{
+        PIN:      r.Header.Get(models.StepUpPINHeader),
+        TOTPCode: r.Header.Get(models.StepUpTOTPHeader),
+    }
+    if err := utils.ValidateStruct(stepUp); err != nil {
+        return rejectRequest(http.StatusBadRequest, "Invalid step-up header",
+            models.StepUpPINHeader+" must be 4 to 6 digits and "+models.StepUpTOTPHeader+" 6 digits")
+    }
+
+    var user models.User
+    if err := h.db.First(&user, userID).Error; err != nil {
+        return failRequest("Failed to fetch user", err)
+    }
+
+    challenge := h.stepUpChallenge(&user, stepUpRequired)
+    if len(challenge.Methods) == 0 {
+        return rejectRequest(http.StatusForbidden,
+            "Set a transaction PIN or enable two-factor authentication to make payments above "+h.config.StepUp.Threshold.String(), challenge)
+    }
+    if !stepUp.Provided() {
+        return rejectRequest(http.StatusForbidden, "Step-up authentication required", challenge)
+    }
+    if err := h.stepUpLockedError(&user); err != nil {
+        return err
+    }
+
+    var ok bool
+    switch {
+    case stepUp.PIN != "":
+        if !user.HasTransactionPIN() {
+            return rejectRequest(http.StatusBadRequest, "No transaction PIN is set", challenge)
+        }
+        ok = utils.CheckPasswordHash(stepUp.PIN, user.TransactionPIN)
+    default:
+        if !user.TwoFactorEnabled {
+            return rejectRequest(http.StatusBadRequest, "Two-factor authentication is not enabled", challenge)
+        }
+        var err error
+        if ok, err = consumeTOTPCode(h.db, &user, stepUp.TOTPCode); err != nil {
+            return err
+        }
+    }
+    if !ok {
+        return h.recordStepUpFailure(r, &user, "Invalid transaction PIN or code")
+    }
+    return h.clearStepUpFailures(&user)
+}
+
+// checkStepUpPassword confirms the account password before a step-up method
+// is added or changed: the transaction PIN, or TOTP enrollment. Wrong
+// passwords count towards the step-up lockout, so a stolen token cannot be
+// used to guess the password here.
+func (h *Handlers) checkStepUpPassword(r *http.Request, user *models.User, password string) error {
+    if err := h.stepUpLockedError(user); err != nil {
+        return err
+    }
+    if !utils.CheckPasswordHash(password, user.Password) {
+        return h.recordStepUpFailure(r, user, "Invalid password")
+    }
+    return h.clearStepUpFailures(user)
+}
+
+// SetTransactionPIN sets or changes the caller's transaction PIN, which
+// confirms large withdrawals and transfers
+func (h *Handlers) SetTransactionPIN(w http.ResponseWriter, r *http.Request) {
+    var user models.User
+    if !h.loadCurrentUser(w, r, &user) {
+        return
+    }
+
+    var req models.SetTransactionPINRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+    if guessablePIN(req.PIN) {
+        sendError(w, http.StatusBadRequest, "PIN is too easy to guess", nil)
+        return
+    }
+
+    if err := h.checkStepUpPassword(r, &user, req.Password); err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    hash, err := utils.HashPassword(req.PIN)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to set transaction PIN", err.Error())
+        return
+    }
+    if err := h.db.Model(&models.User{}).Where("id = ?", user.ID).Update("transaction_pin", hash).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to set transaction PIN", err.Error())
+        return
+    }
+
+    message := "Transaction PIN set"
+    if user.HasTransactionPIN() {
+        message = "Transaction PIN changed"
+    }
+    h.notify(&user, message, "The PIN that confirms large payments from your MiniBank account was just "+
+        "set or changed. If this was not you, change your password and sign out your other devices.")
+    h.logAudit(&user.ID, "PIN_SET", "AUTH", message, r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": message,
+    })
+}
+
+// RemoveTransactionPIN removes the caller's transaction PIN. Large payments
+// then need a TOTP code, or cannot be made until a new PIN is set.
+func (h *Handlers) RemoveTransactionPIN(w http.ResponseWriter, r *http.Request) {
+    var user models.User
+    if !h.loadCurrentUser(w, r, &user) {
+        return
+    }
+
+    var req models.RemoveTransactionPINRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    if !user.HasTransactionPIN() {
+        sendError(w, http.StatusBadRequest, "No transaction PIN is set", nil)
+        return
+    }
+    if err := h.checkStepUpPassword(r, &user, req.Password); err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    if err := h.db.Model(&models.User{}).Where("id = ?", user.ID).Update("transaction_pin", "").Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to remove transaction PIN", err.Error())
+        return
+    }
+
+    h.logAudit(&user.ID, "PIN_REMOVE", "AUTH", "Transaction PIN removed", r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Transaction PIN removed",
+    })
+}
@@ -0,0 +1,546 @@
+package handlers
+
+import (
+    "encoding/json"
+    "errors"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/utils"
+
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// recoveryCodeCount is how many recovery codes a user is given at a time
+const recoveryCodeCount = 10
+
+// isTOTPCode reports whether a second factor looks like a TOTP code rather
+// than a recovery code
+func isTOTPCode(code string) bool {
+    if len(code) != 6 {
+        return false
+    }
+    for _, c := range code {
+        if c < '0' || c > '9' {
+            return false
+        }
+    }
+    return true
+}
+
+// consumeTOTPCode checks a TOTP code and records its time step, so the same
+// code is refused if presented again, even by a concurrent request. It
+// reports whether the code was accepted.
+func consumeTOTPCode(tx *gorm.DB, user *models.User, code string) (bool, error) {
+    secret, err := utils.DecryptSensitiveData(user.TOTPSecret)
+    if err != nil {
+        return false, failRequest("Failed to read two-factor secret", err)
+    }
+    step, ok := utils.ValidateTOTP(secret, code, user.TOTPLastStep)
+    if !ok {
+        return false, nil
+    }
+    result := tx.Model(&models.User{}).
+        Where("id = ? AND totp_last_step < ?", user.ID, step).
+        Update("totp_last_step", step)
+    if result.Error != nil {
+        return false, failRequest("Failed to record two-factor code", result.Error)
+    }
+    if result.RowsAffected == 0 {
+        return false, nil
+    }
+    user.TOTPLastStep = step
+    return true, nil
+}
+
+// verifySecondFactor checks a TOTP or recovery code for a user with
+// two-factor authentication enabled and consumes it, so the same code is
+// refused if presented again, even by a concurrent request
+func verifySecondFactor(tx *gorm.DB, user *models.User, code string) error {
+    code = strings.TrimSpace(code)
+
+    if isTOTPCode(code) {
+        ok, err := consumeTOTPCode(tx, user, code)
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return rejectRequest(http.StatusUnauthorized, "Invalid two-factor code", nil)
+        }
+        return nil
+    }
+
+    now := time.Now()
+    result := tx.Model(&models.RecoveryCode{}).
+        Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(utils.NormalizeRecoveryCode(code))).
+        Update("used_at", &now)
+    if result.Error != nil {
+        return failRequest("Failed to record recovery code", result.Error)
+    }
+    if result.RowsAffected == 0 {
+        return rejectRequest(http.StatusUnauthorized, "Invalid two-factor code", nil)
+    }
+    log.Printf("Recovery code used by user %d", user.ID)
+    return nil
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and issues a new
+// set. The codes are returned in clear once; only their hashes are kept.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+    if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+        return nil, err
+    }
+
+    codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+    if err != nil {
+        return nil, err
+    }
+    records := make([]models.RecoveryCode, len(codes))
+    for i, code := range codes {
+        records[i] = models.RecoveryCode{
+            UserID:   userID,
+            CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code)),
+        }
+    }
+    if err := tx.Create(&records).Error; err != nil {
+        return nil, err
+    }
+    return codes, nil
+}
+
+// loadCurrentUser loads the user the request is authenticated as
+func (h *Handlers) loadCurrentUser(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+    claims := middleware.GetUserFromContext(r)
+    if claims == nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or missing token", nil)
+        return false
+    }
+
+    if err := h.db.First(user, claims.UserID).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            sendError(w, http.StatusNotFound, "User not found", nil)
+        } else {
+            sendError(w, http.StatusInternalServerError, "Failed to fetch user", err.Error())
+        }
+        return false
+    }
+    return true
+}
+
+// SetupTwoFactor starts TOTP enrollment. It returns a new secret and the
+// otpauth URI for an authenticator app; 2FA is not enabled until a code
+// from the app is confirmed. Both steps need the account password, since a
+// TOTP code confirms large payments.
+func (h *Handlers) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+    var user models.User
+    if !h.loadCurrentUser(w, r, &user) {
+        return
+    }
+
+    var req models.TwoFactorSetupRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    if user.TwoFactorEnabled {
+        sendError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+        return
+    }
+    if err := h.checkStepUpPassword(r, &user, req.Password); err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    secret, err := utils.GenerateTOTPSecret()
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to generate two-factor secret", err.Error())
+        return
+    }
+    encrypted, err := utils.EncryptSensitiveData(secret)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to encrypt two-factor secret", err.Error())
+        return
+    }
+
+    if err := h.db.Model(&user).Updates(map[string]interface{}{"totp_secret": encrypted, "totp_last_step": 0}).Error; err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to save two-factor secret", err.Error())
+        return
+    }
+
+    h.logAudit(&user.ID, "2FA_SETUP", "AUTH", "Two-factor enrollment started", r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(models.TwoFactorSetupResponse{
+        Secret:     secret,
+        OTPAuthURI: utils.TOTPURI(secret, user.Email),
+    })
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves
+// their authenticator produces valid codes, and returns their recovery codes
+func (h *Handlers) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+    var user models.User
+    if !h.loadCurrentUser(w, r, &user) {
+        return
+    }
+
+    var req models.TwoFactorConfirmRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    if user.TwoFactorEnabled {
+        sendError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+        return
+    }
+    if user.TOTPSecret == "" {
+        sendError(w, http.StatusBadRequest, "Two-factor setup has not been started", nil)
+        return
+    }
+    if err := h.checkStepUpPassword(r, &user, req.Password); err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    secret, err := utils.DecryptSensitiveData(user.TOTPSecret)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to read two-factor secret", err.Error())
+        return
+    }
+    step, ok := utils.ValidateTOTP(secret, req.Code, user.TOTPLastStep)
+    if !ok {
+        sendError(w, http.StatusBadRequest, "Invalid two-factor code", nil)
+        return
+    }
+
+    var codes []string
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        // The secret must still be the one the code was checked against
+        result := tx.Model(&models.User{}).
+            Where("id = ? AND two_factor_enabled = ? AND totp_secret = ?", user.ID, false, user.TOTPSecret).
+            Updates(map[string]interface{}{"two_factor_enabled": true, "totp_last_step": step})
+        if result.Error != nil {
+            return failRequest("Failed to enable two-factor authentication", result.Error)
+        }
+        if result.RowsAffected == 0 {
+            return rejectRequest(http.StatusConflict, "Two-factor setup changed, please start again", nil)
+        }
+
+        var err error
+        if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+            return failRequest("Failed to generate recovery codes", err)
+        }
+        return nil
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    h.notify(&user, "Two-factor authentication enabled", "Two-factor authentication was just turned on for your MiniBank account, "+
+        "and codes from the authenticator app are now needed to log in and confirm large payments. "+
+        "If this was not you, reset your password and contact us.")
+    h.logAudit(&user.ID, "2FA_ENABLE", "AUTH", "Two-factor authentication enabled", r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking a
+// current code. Staff cannot turn it off while it is mandatory for them.
+func (h *Handlers) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+    var user models.User
+    if !h.loadCurrentUser(w, r, &user) {
+        return
+    }
+
+    var req models.TwoFactorCodeRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    if !user.TwoFactorEnabled {
+        sendError(w, http.StatusBadRequest, "Two-factor authentication is not enabled", nil)
+        return
+    }
+    if models.IsStaffRole(user.Role) && h.config.TwoFactor.RequireForAdmins {
+        sendError(w, http.StatusForbidden, "Two-factor authentication is mandatory for staff", nil)
+        return
+    }
+
+    err := h.db.Transaction(func(tx *gorm.DB) error {
+        if err := verifySecondFactor(tx, &user, req.Code); err != nil {
+            return err
+        }
+        if err := tx.Model(&models.User{}).Where("id = ?", user.ID).
+            Updates(map[string]interface{}{"two_factor_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error; err != nil {
+            return failRequest("Failed to disable two-factor authentication", err)
+        }
+        if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+            return failRequest("Failed to delete recovery codes", err)
+        }
+        return nil
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    h.logAudit(&user.ID, "2FA_DISABLE", "AUTH", "Two-factor authentication disabled", r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "message": "Two-factor authentication disabled",
+    })
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes, for example
+// when most have been used
+func (h *Handlers) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+    var user models.User
+    if !h.loadCurrentUser(w, r, &user) {
+        return
+    }
+
+    var req models.TwoFactorCodeRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    if !user.TwoFactorEnabled {
+        sendError(w, http.StatusBadRequest, "Two-factor authentication is not enabled", nil)
+        return
+    }
+
+    var codes []string
+    err := h.db.Transaction(func(tx *gorm.DB) error {
+        if err := verifySecondFactor(tx, &user, req.Code); err != nil {
+            return err
+        }
+        var err error
+        if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+            return failRequest("Failed to generate recovery codes", err)
+        }
+        return nil
+    })
+    if err != nil {
+        sendRequestError(w, err)
+        return
+    }
+
+    h.logAudit(&user.ID, "2FA_RECOVERY_CODES", "AUTH", "Recovery codes regenerated", r.RemoteAddr, r.UserAgent())
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// recordMFAFailure counts a wrong code given for a login challenge. Once the
+// failures reach MFA_MAX_ATTEMPTS the challenge is spent, so the password has
+// to be given again. It returns the error to refuse the request with.
+func (h *Handlers) recordMFAFailure(user *models.User, challenge *utils.Claims) error {
+    failure := models.MFAFailure{
+        ChallengeID: challenge.ID,
+        UserID:      user.ID,
+        Failures:    1,
+        ExpiresAt:   challenge.ExpiresAt.Time,
+    }
+    if err := h.db.Clauses(clause.OnConflict{
+        Columns: []clause.Column{{Name: "challenge_id"}},
+        DoUpdates: clause.Assignments(map[string]interface{}{
+            "failures": gorm.Expr("mfa_failures.failures + 1"),
+        }),
+    }).Create(&failure).Error; err != nil {
+        return failRequest("Failed to record failed attempt", err)
+    }
+
+    maxAttempts := h.config.TwoFactor.MaxAttempts
+    if maxAttempts <= 0 {
+        return rejectRequest(http.StatusUnauthorized, "Invalid two-factor code", nil)
+    }
+    if err := h.db.Where("challenge_id = ?", challenge.ID).First(&failure).Error; err != nil {
+        return failRequest("Failed to record failed attempt", err)
+    }
+
+    remaining := maxAttempts - failure.Failures
+    if remaining > 0 {
+        return rejectRequest(http.StatusUnauthorized, "Invalid two-factor code", map[string]int{
+            "attempts_remaining": remaining,
+        })
+    }
+
+    if err := h.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+        JTI:       challenge.ID,
+        UserID:    user.ID,
+        Reason:    "mfa challenge failed",
+        ExpiresAt: challenge.ExpiresAt.Time,
+    }).Error; err != nil {
+        return failRequest("Failed to spend MFA token", err)
+    }
+    return rejectRequest(http.StatusUnauthorized, "Too many invalid two-factor codes, log in again", nil)
+}
+
+// VerifyMFA completes a two-step login. It exchanges the challenge token
+// from Login and a TOTP or recovery code for access and refresh tokens. Each
+// challenge can be completed once, and is spent after MFA_MAX_ATTEMPTS wrong
+// codes.
+func (h *Handlers) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+    var req models.VerifyMFARequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+        return
+    }
+
+    if err := utils.ValidateStruct(req); err != nil {
+        sendError(w, http.StatusBadRequest, "Validation failed", utils.FormatValidationError(err))
+        return
+    }
+
+    challenge, err := utils.ValidateToken(req.MFAToken)
+    if err != nil || challenge.Purpose != utils.PurposeMFAChallenge || challenge.ID == "" {
+        sendError(w, http.StatusUnauthorized, "Invalid or expired MFA token", nil)
+        return
+    }
+
+    // Throttle guesses across all of a user's challenges
+    if !h.mfaUsers.Allow(strconv.FormatUint(uint64(challenge.UserID), 10)) {
+        sendThrottled(w, "Too many two-factor attempts, try again later", time.Minute)
+        return
+    }
+
+    var user models.User
+    if err := h.db.First(&user, challenge.UserID).Error; err != nil {
+        sendError(w, http.StatusUnauthorized, "Invalid or expired MFA token", nil)
+        return
+    }
+    if !user.IsActive {
+        sendError(w, http.StatusForbidden, "Account is deactivated", nil)
+        return
+    }
+    if !user.TwoFactorEnabled {
+        sendError(w, http.StatusUnauthorized, "Invalid or expired MFA token", nil)
+        return
+    }
+
+    // Wrong codes count as failed logins, so they wait out the same delays
+    // and lockout as wrong passwords
+    key := loginKey(user.Email)
+    wait, err := h.loginWait(key)
+    if err != nil {
+        sendError(w, http.StatusInternalServerError, "Failed to check failed logins", err.Error())
+        return
+    }
+    if wait > 0 {
+        sendThrottled(w, "Too many failed login attempts, try again later", wait)
+        return
+    }
+
+    var tokens *models.TokenResponse
+    wrongCode := false
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        var used int64
+        if err := tx.Model(&models.RevokedToken{}).Where("jti = ?", challenge.ID).Count(&used).Error; err != nil {
+            return failRequest("Failed to check MFA token", err)
+        }
+        if used > 0 {
+            return rejectRequest(http.StatusUnauthorized, "Invalid or expired MFA token", nil)
+        }
+
+        if err := verifySecondFactor(tx, &user, req.Code); err != nil {
+            var reqErr *requestError
+            wrongCode = errors.As(err, &reqErr) && reqErr.status == http.StatusUnauthorized
+            return err
+        }
+
+        // Spend the challenge; the unique jti stops a concurrent second use
+        if err := tx.Create(&models.RevokedToken{
+            JTI:       challenge.ID,
+            UserID:    user.ID,
+            Reason:    "mfa challenge completed",
+            ExpiresAt: challenge.ExpiresAt.Time,
+        }).Error; err != nil {
+            return rejectRequest(http.StatusUnauthorized, "Invalid or expired MFA token", nil)
+        }
+
+        session, err := h.startSession(tx, &user, req.DeviceName, true, r)
+        if err != nil {
+            return failRequest("Failed to start session", err)
+        }
+        tokens, _, err = h.issueTokens(tx, &user, session, r)
+        if err != nil {
+            return failRequest("Failed to generate token", err)
+        }
+        return nil
+    })
+    if err != nil {
+        // The transaction rolled back, so the failure is counted outside it
+        var lockedUntil *time.Time
+        if wrongCode {
+            err = h.recordMFAFailure(&user, challenge)
+            var lockErr error
+            if lockedUntil, lockErr = h.recordLoginFailure(key); lockErr != nil {
+                log.Printf("Failed to record failed login for %s: %v", user.Email, lockErr)
+            }
+            if lockedUntil != nil {
+                h.notifyLockout(&user, *lockedUntil, r)
+            }
+        }
+        var reqErr *requestError
+        if errors.As(err, &reqErr) && reqErr.status == http.StatusUnauthorized {
+            h.logAudit(&user.ID, "MFA_FAILED", "AUTH", reqErr.message, r.RemoteAddr, r.UserAgent())
+        }
+        if lockedUntil != nil {
+            sendThrottled(w, "Too many failed login attempts, try again later", time.Until(*lockedUntil))
+            return
+        }
+        sendRequestError(w, err)
+        return
+    }
+
+    if _, err := h.clearLoginFailures(key); err != nil {
+        log.Printf("Failed to clear failed logins for %s: %v", user.Email, err)
+    }
+
+    loginDetails := "User logged in with two-factor authentication"
+    if models.IsStaffRole(user.Role) {
+        loginDetails = "Staff user logged in as " + user.Role + " with two-factor authentication"
+    }
+    h.logAudit(&user.ID, "LOGIN", "AUTH", loginDetails, r.RemoteAddr, r.UserAgent())
+
+    user.Password = ""
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(models.LoginResponse{
+        TokenResponse: *tokens,
+        User:          user,
+    })
+}
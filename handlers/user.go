@@ -18,7 +18,7 @@ func (h *Handlers) GetProfile(w http.ResponseWriter, r *http.Request) {
     }
 
     var user models.User
-    if err := h.db.First(&user, claims.UserID).Error; err != nil {
+    if err := h.db.Preload("Accounts").First(&user, claims.UserID).Error; err != nil {
         if err == gorm.ErrRecordNotFound {
             sendError(w, http.StatusNotFound, "User not found", map[string]string{"original_error": err.Error()})
             return
@@ -0,0 +1,190 @@
+package kms
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// activeKeyFile names the current master key in a FileKMS directory
+const activeKeyFile = "active"
+
+// fileKeyID is the form of master key IDs, which are also file names. Key IDs
+// read from ciphertexts are checked against it before they reach the disk.
+var fileKeyID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// FileKMS keeps master keys as files in a directory, one per key named after
+// its ID, such as 20261017-3f9a1c.key, and the ID of the current key in a
+// file named "active". It stands in for a cloud KMS in development. The
+// directory has to be protected and backed up like any key store: data
+// encrypted under a lost key cannot be recovered.
+type FileKMS struct {
+    dir  string
+    mtx  sync.Mutex
+    keys map[string]cipher.AEAD
+}
+
+// NewFileKMS opens the key directory, creating it and a first master key if
+// there is none yet
+func NewFileKMS(dir string) (*FileKMS, error) {
+    if dir == "" {
+        return nil, fmt.Errorf("KMS_DIR is required for the file KMS provider")
+    }
+    k := &FileKMS{dir: dir, keys: make(map[string]cipher.AEAD)}
+
+    if _, err := os.Stat(filepath.Join(dir, activeKeyFile)); os.IsNotExist(err) {
+        id, err := k.Rotate()
+        if err != nil {
+            return nil, err
+        }
+        log.Printf("WARNING: created master key %s in %s; back this directory up, data encrypted under it cannot be recovered without it", id, dir)
+    }
+    if _, err := k.CurrentKeyID(); err != nil {
+        return nil, err
+    }
+    return k, nil
+}
+
+// CurrentKeyID reads the active file each time, so a rotation by another
+// process takes effect at once
+func (k *FileKMS) CurrentKeyID() (string, error) {
+    data, err := os.ReadFile(filepath.Join(k.dir, activeKeyFile))
+    if err != nil {
+        return "", fmt.Errorf("failed to read active master key: %v", err)
+    }
+    id := strings.TrimSpace(string(data))
+    if id == "" {
+        return "", fmt.Errorf("no active master key in %s", k.dir)
+    }
+    return id, nil
+}
+
+func (k *FileKMS) WrapKey(dataKey []byte) (string, []byte, error) {
+    id, err := k.CurrentKeyID()
+    if err != nil {
+        return "", nil, err
+    }
+    aead, err := k.masterKey(id)
+    if err != nil {
+        return "", nil, err
+    }
+    nonce := make([]byte, aead.NonceSize())
+    if _, err := rand.Read(nonce); err != nil {
+        return "", nil, fmt.Errorf("failed to generate nonce: %v", err)
+    }
+    return id, aead.Seal(nonce, nonce, dataKey, []byte(id)), nil
+}
+
+func (k *FileKMS) UnwrapKey(id string, wrapped []byte) ([]byte, error) {
+    aead, err := k.masterKey(id)
+    if err != nil {
+        return nil, err
+    }
+    if len(wrapped) < aead.NonceSize() {
+        return nil, fmt.Errorf("wrapped data key is too short")
+    }
+    nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+    dataKey, err := aead.Open(nil, nonce, sealed, []byte(id))
+    if err != nil {
+        return nil, fmt.Errorf("failed to unwrap data key with master key %s: %v", id, err)
+    }
+    return dataKey, nil
+}
+
+// masterKey loads a master key, caching it since a key never changes once
+// written
+func (k *FileKMS) masterKey(id string) (cipher.AEAD, error) {
+    k.mtx.Lock()
+    defer k.mtx.Unlock()
+
+    if aead, ok := k.keys[id]; ok {
+        return aead, nil
+    }
+    if !fileKeyID.MatchString(id) {
+        return nil, fmt.Errorf("invalid master key ID %q", id)
+    }
+    data, err := os.ReadFile(filepath.Join(k.dir, id+".key"))
+    if err != nil {
+        return nil, fmt.Errorf("failed to read master key %s: %v", id, err)
+    }
+    key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+    if err != nil || len(key) != 32 {
+        return nil, fmt.Errorf("master key %s is not 32 base64-encoded bytes", id)
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create cipher: %v", err)
+    }
+    aead, err := cipher.NewGCM(block)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create cipher: %v", err)
+    }
+    k.keys[id] = aead
+    return aead, nil
+}
+
+// KeyIDs lists the master keys in the directory, oldest first
+func (k *FileKMS) KeyIDs() ([]string, error) {
+    entries, err := os.ReadDir(k.dir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read KMS directory: %v", err)
+    }
+    var ids []string
+    for _, entry := range entries {
+        if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".key") {
+            ids = append(ids, strings.TrimSuffix(entry.Name(), ".key"))
+        }
+    }
+    sort.Strings(ids)
+    return ids, nil
+}
+
+// Rotate creates a new master key and makes it the current one. Older keys
+// stay, so data keys they wrapped can still be unwrapped.
+func (k *FileKMS) Rotate() (string, error) {
+    key := make([]byte, 32)
+    if _, err := rand.Read(key); err != nil {
+        return "", fmt.Errorf("failed to generate master key: %v", err)
+    }
+    suffix := make([]byte, 3)
+    if _, err := rand.Read(suffix); err != nil {
+        return "", fmt.Errorf("failed to generate key ID: %v", err)
+    }
+    id := time.Now().UTC().Format("20060102") + "-" + hex.EncodeToString(suffix)
+
+    if err := os.MkdirAll(k.dir, 0700); err != nil {
+        return "", fmt.Errorf("failed to create KMS directory: %v", err)
+    }
+    f, err := os.OpenFile(filepath.Join(k.dir, id+".key"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+    if err != nil {
+        return "", fmt.Errorf("failed to write master key: %v", err)
+    }
+    _, err = f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n")
+    if closeErr := f.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return "", fmt.Errorf("failed to write master key: %v", err)
+    }
+
+    // Replace the active file in one step, so nothing reads it half written
+    tmp := filepath.Join(k.dir, activeKeyFile+".tmp")
+    if err := os.WriteFile(tmp, []byte(id+"\n"), 0600); err != nil {
+        return "", fmt.Errorf("failed to activate master key: %v", err)
+    }
+    if err := os.Rename(tmp, filepath.Join(k.dir, activeKeyFile)); err != nil {
+        return "", fmt.Errorf("failed to activate master key: %v", err)
+    }
+    return id, nil
+}
@@ -0,0 +1,27 @@
+package kms
+
+import (
+    "fmt"
+
+    "minibank-go/config"
+)
+
+// KMS keeps the master keys that wrap data keys. Master keys never leave it:
+// callers only see key IDs and wrapped data keys.
+type KMS interface {
+    // CurrentKeyID names the master key that new data keys are wrapped with
+    CurrentKeyID() (string, error)
+    // WrapKey encrypts a data key with the current master key
+    WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+    // UnwrapKey decrypts a data key wrapped with the named master key
+    UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// New returns the KMS selected by KMS_PROVIDER
+func New(cfg config.KMSConfig) (KMS, error) {
+    switch cfg.Provider {
+    case "", "file":
+        return NewFileKMS(cfg.Dir)
+    }
+    return nil, fmt.Errorf("unknown KMS provider %q", cfg.Provider)
+}
@@ -0,0 +1,128 @@
+package ledger
+
+import (
+    "fmt"
+
+    "minibank-go/models"
+    "minibank-go/money"
+
+    "gorm.io/gorm"
+)
+
+// PostDeposit records cash received into a customer account:
+// debit cash in vault, credit the customer.
+func PostDeposit(tx *gorm.DB, account *models.Account, amount money.Amount, reference, description string) (*models.JournalEntry, error) {
+    vault, err := SystemAccount(tx, CashInVault, account.Currency)
+    if err != nil {
+        return nil, err
+    }
+    customer, err := CustomerAccount(tx, account)
+    if err != nil {
+        return nil, err
+    }
+
+    entry := &models.JournalEntry{
+        Reference:   reference,
+        Type:        "deposit",
+        Description: description,
+        Postings: []models.Posting{
+            Debit(vault, amount),
+            Credit(customer, amount),
+        },
+    }
+    return entry, Post(tx, entry)
+}
+
+// PostWithdrawal records cash paid out of a customer account:
+// debit the customer, credit cash in vault.
+func PostWithdrawal(tx *gorm.DB, account *models.Account, amount money.Amount, reference, description string) (*models.JournalEntry, error) {
+    vault, err := SystemAccount(tx, CashInVault, account.Currency)
+    if err != nil {
+        return nil, err
+    }
+    customer, err := CustomerAccount(tx, account)
+    if err != nil {
+        return nil, err
+    }
+
+    entry := &models.JournalEntry{
+        Reference:   reference,
+        Type:        "withdraw",
+        Description: description,
+        Postings: []models.Posting{
+            Debit(customer, amount),
+            Credit(vault, amount),
+        },
+    }
+    return entry, Post(tx, entry)
+}
+
+// PostTransfer records money moving between two customer accounts:
+// debit the sender, credit the recipient.
+func PostTransfer(tx *gorm.DB, from, to *models.Account, amount money.Amount, reference, description string) (*models.JournalEntry, error) {
+    sender, err := CustomerAccount(tx, from)
+    if err != nil {
+        return nil, err
+    }
+    recipient, err := CustomerAccount(tx, to)
+    if err != nil {
+        return nil, err
+    }
+
+    entry := &models.JournalEntry{
+        Reference:   reference,
+        Type:        "transfer",
+        Description: description,
+        Postings: []models.Posting{
+            Debit(sender, amount),
+            Credit(recipient, amount),
+        },
+    }
+    return entry, Post(tx, entry)
+}
+
+// PostCapture records a captured hold paid out to the card network:
+// debit the customer, credit card settlement.
+func PostCapture(tx *gorm.DB, account *models.Account, amount money.Amount, reference, description string) (*models.JournalEntry, error) {
+    customer, err := CustomerAccount(tx, account)
+    if err != nil {
+        return nil, err
+    }
+    settlement, err := SystemAccount(tx, CardSettlement, account.Currency)
+    if err != nil {
+        return nil, err
+    }
+
+    entry := &models.JournalEntry{
+        Reference:   reference,
+        Type:        "capture",
+        Description: description,
+        Postings: []models.Posting{
+            Debit(customer, amount),
+            Credit(settlement, amount),
+        },
+    }
+    return entry, Post(tx, entry)
+}
+
+// PostReversal records a journal entry that exactly undoes an earlier one by
+// posting each of its legs with the opposite sign.
+func PostReversal(tx *gorm.DB, originalID uint, reference, description string) (*models.JournalEntry, error) {
+    var original models.JournalEntry
+    if err := tx.Preload("Postings").First(&original, originalID).Error; err != nil {
+        return nil, fmt.Errorf("failed to load journal entry %d: %w", originalID, err)
+    }
+
+    entry := &models.JournalEntry{
+        Reference:   reference,
+        Type:        "reversal",
+        Description: description,
+    }
+    for _, p := range original.Postings {
+        entry.Postings = append(entry.Postings, models.Posting{
+            LedgerAccountID: p.LedgerAccountID,
+            Amount:          -p.Amount,
+        })
+    }
+    return entry, Post(tx, entry)
+}
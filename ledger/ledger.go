@@ -0,0 +1,130 @@
+package ledger
+
+import (
+    "errors"
+    "fmt"
+    "time"
+
+    "minibank-go/models"
+    "minibank-go/money"
+
+    "gorm.io/gorm"
+)
+
+// System account names. Each exists once per currency.
+const (
+    CashInVault    = "cash_in_vault"
+    Suspense       = "suspense"
+    CardSettlement = "card_settlement"
+)
+
+var systemAccounts = map[string]struct {
+    name          string
+    accountType   string
+    normalBalance string
+}{
+    CashInVault:    {"Cash in vault", "asset", "debit"},
+    Suspense:       {"Suspense", "asset", "debit"},
+    CardSettlement: {"Card settlement", "liability", "credit"},
+}
+
+var ErrUnbalanced = errors.New("journal entry does not balance")
+
+// Debit returns a debit leg against the given ledger account
+func Debit(account *models.LedgerAccount, amount money.Amount) models.Posting {
+    return models.Posting{LedgerAccountID: account.ID, Amount: amount}
+}
+
+// Credit returns a credit leg against the given ledger account
+func Credit(account *models.LedgerAccount, amount money.Amount) models.Posting {
+    return models.Posting{LedgerAccountID: account.ID, Amount: -amount}
+}
+
+func systemCode(name string, currency money.Currency) string {
+    return fmt.Sprintf("system:%s:%s", name, currency)
+}
+
+func customerCode(accountID uint) string {
+    return fmt.Sprintf("account:%d", accountID)
+}
+
+// SystemAccount returns the named system account for a currency, creating it
+// on first use.
+func SystemAccount(tx *gorm.DB, name string, currency money.Currency) (*models.LedgerAccount, error) {
+    def, ok := systemAccounts[name]
+    if !ok {
+        return nil, fmt.Errorf("unknown system account: %s", name)
+    }
+
+    account := models.LedgerAccount{
+        Code:          systemCode(name, currency),
+        Name:          def.name,
+        Type:          def.accountType,
+        NormalBalance: def.normalBalance,
+        Currency:      currency,
+    }
+    if err := tx.Where("code = ?", account.Code).FirstOrCreate(&account).Error; err != nil {
+        return nil, fmt.Errorf("failed to load system account %s: %w", account.Code, err)
+    }
+    return &account, nil
+}
+
+// CustomerAccount returns the liability account backing a customer
+// account's balance, creating it on first use.
+func CustomerAccount(tx *gorm.DB, account *models.Account) (*models.LedgerAccount, error) {
+    ledgerAccount := models.LedgerAccount{
+        Code:          customerCode(account.ID),
+        Name:          fmt.Sprintf("Customer account %s", account.AccountNumber),
+        Type:          "liability",
+        NormalBalance: "credit",
+        AccountID:     &account.ID,
+        Currency:      account.Currency,
+    }
+    if err := tx.Where("code = ?", ledgerAccount.Code).FirstOrCreate(&ledgerAccount).Error; err != nil {
+        return nil, fmt.Errorf("failed to load customer ledger account %s: %w", ledgerAccount.Code, err)
+    }
+    return &ledgerAccount, nil
+}
+
+// Post validates and writes a journal entry with its postings. Every leg must
+// be non-zero and the legs must sum to zero.
+func Post(tx *gorm.DB, entry *models.JournalEntry) error {
+    if len(entry.Postings) < 2 {
+        return fmt.Errorf("%w: at least two postings required", ErrUnbalanced)
+    }
+
+    var sum money.Amount
+    for _, p := range entry.Postings {
+        if p.Amount.IsZero() {
+            return fmt.Errorf("%w: zero amount posting to account %d", ErrUnbalanced, p.LedgerAccountID)
+        }
+        sum += p.Amount
+    }
+    if !sum.IsZero() {
+        return fmt.Errorf("%w: postings sum to %s", ErrUnbalanced, sum)
+    }
+
+    if entry.PostedAt.IsZero() {
+        entry.PostedAt = time.Now()
+    }
+    if err := tx.Create(entry).Error; err != nil {
+        return fmt.Errorf("failed to write journal entry: %w", err)
+    }
+    return nil
+}
+
+// Balance derives an account's balance from its postings, signed so that a
+// positive result is on the account's normal side.
+func Balance(tx *gorm.DB, account *models.LedgerAccount) (money.Amount, error) {
+    var sum money.Amount
+    if err := tx.Model(&models.Posting{}).
+        Where("ledger_account_id = ?", account.ID).
+        Select("COALESCE(SUM(amount), 0)").
+        Scan(&sum).Error; err != nil {
+        return 0, fmt.Errorf("failed to sum postings for %s: %w", account.Code, err)
+    }
+    if account.NormalBalance == "credit" {
+        return -sum, nil
+    }
+    return sum, nil
+}
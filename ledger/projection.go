@@ -0,0 +1,145 @@
+package ledger
+
+import (
+    "fmt"
+
+    "minibank-go/models"
+    "minibank-go/money"
+
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// Mismatch describes an account whose cached balance disagrees with the ledger
+type Mismatch struct {
+    AccountID     uint         `json:"account_id"`
+    CachedBalance money.Amount `json:"cached_balance"`
+    LedgerBalance money.Amount `json:"ledger_balance"`
+}
+
+// Report is the result of verifying the ledger
+type Report struct {
+    TrialBalance    money.Amount `json:"trial_balance"`
+    Balanced        bool         `json:"balanced"`
+    AccountsChecked int          `json:"accounts_checked"`
+    Mismatches      []Mismatch   `json:"mismatches"`
+}
+
+// ledgerBalance returns the ledger balance of a customer account, or zero if
+// the account has never been posted to.
+func ledgerBalance(tx *gorm.DB, account models.Account) (money.Amount, error) {
+    var ledgerAccount models.LedgerAccount
+    err := tx.Where("code = ?", customerCode(account.ID)).First(&ledgerAccount).Error
+    if err == gorm.ErrRecordNotFound {
+        return 0, nil
+    }
+    if err != nil {
+        return 0, err
+    }
+    return Balance(tx, &ledgerAccount)
+}
+
+// Verify checks that all postings sum to zero and that every account's cached
+// balance matches the balance derived from the ledger.
+func Verify(db *gorm.DB) (*Report, error) {
+    report := &Report{Mismatches: []Mismatch{}}
+
+    if err := db.Model(&models.Posting{}).
+        Select("COALESCE(SUM(amount), 0)").
+        Scan(&report.TrialBalance).Error; err != nil {
+        return nil, fmt.Errorf("failed to compute trial balance: %w", err)
+    }
+    report.Balanced = report.TrialBalance.IsZero()
+
+    var accounts []models.Account
+    if err := db.Find(&accounts).Error; err != nil {
+        return nil, fmt.Errorf("failed to load accounts: %w", err)
+    }
+
+    for _, account := range accounts {
+        balance, err := ledgerBalance(db, account)
+        if err != nil {
+            return nil, fmt.Errorf("failed to derive balance for account %d: %w", account.ID, err)
+        }
+        report.AccountsChecked++
+        if balance != account.Balance {
+            report.Mismatches = append(report.Mismatches, Mismatch{
+                AccountID:     account.ID,
+                CachedBalance: account.Balance,
+                LedgerBalance: balance,
+            })
+        }
+    }
+    return report, nil
+}
+
+// rebuildAttempts bounds how often one account is re-read when it changes
+// while its balance is being rebuilt
+const rebuildAttempts = 5
+
+// RebuildBalances overwrites every account's cached balance with the balance
+// derived from the ledger and returns the accounts that changed.
+func RebuildBalances(db *gorm.DB) ([]Mismatch, error) {
+    changed := []Mismatch{}
+    err := db.Transaction(func(tx *gorm.DB) error {
+        var ids []uint
+        if err := tx.Model(&models.Account{}).Order("id ASC").Pluck("id", &ids).Error; err != nil {
+            return fmt.Errorf("failed to load accounts: %w", err)
+        }
+
+        for _, id := range ids {
+            mismatch, err := rebuildBalance(tx, id)
+            if err != nil {
+                return err
+            }
+            if mismatch != nil {
+                changed = append(changed, *mismatch)
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return changed, nil
+}
+
+// rebuildBalance sets one account's cached balance from the ledger, or
+// returns nil if it already matches. The account is read under a row lock,
+// which holds off transfers on databases that have one, and the update only
+// applies at the version read, so a transfer that posted in between is
+// never undone; the account is read again and its balance derived afresh.
+func rebuildBalance(tx *gorm.DB, id uint) (*Mismatch, error) {
+    for attempt := 0; attempt < rebuildAttempts; attempt++ {
+        var account models.Account
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, id).Error; err != nil {
+            return nil, fmt.Errorf("failed to load account %d: %w", id, err)
+        }
+
+        balance, err := ledgerBalance(tx, account)
+        if err != nil {
+            return nil, fmt.Errorf("failed to derive balance for account %d: %w", id, err)
+        }
+        if balance == account.Balance {
+            return nil, nil
+        }
+
+        result := tx.Model(&models.Account{}).
+            Where("id = ? AND version = ?", id, account.Version).
+            Updates(map[string]interface{}{
+                "balance": balance,
+                "version": gorm.Expr("version + 1"),
+            })
+        if result.Error != nil {
+            return nil, fmt.Errorf("failed to update balance for account %d: %w", id, result.Error)
+        }
+        if result.RowsAffected == 1 {
+            return &Mismatch{
+                AccountID:     id,
+                CachedBalance: account.Balance,
+                LedgerBalance: balance,
+            }, nil
+        }
+    }
+    return nil, fmt.Errorf("account %d kept changing while its balance was rebuilt", id)
+}
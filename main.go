@@ -3,11 +3,16 @@ package main
 import (
     "log"
     "net/http"
+    "os"
+    "time"
 
     "minibank-go/config"
     "minibank-go/database"
     "minibank-go/handlers"
+    "minibank-go/kms"
     "minibank-go/middleware"
+    "minibank-go/models"
+    "minibank-go/notify"
     "minibank-go/utils"
 
     "github.com/gorilla/mux"
@@ -26,24 +31,51 @@ func main() {
     // Validate configuration
     config.ValidateConfig(cfg)
 
-    // Initialize encryption
-    if err := utils.InitializeEncryption(cfg.EncryptionKey); err != nil {
+    // Initialize encryption, with data keys wrapped by the configured KMS
+    keys, err := kms.New(cfg.KMS)
+    if err != nil {
+        log.Fatal("Failed to initialize KMS:", err)
+    }
+    if err := utils.InitializeEncryption(cfg.EncryptionKey, keys); err != nil {
         log.Fatal("Failed to initialize encryption:", err)
     }
+    if err := utils.InitializeBlindIndex(cfg.BlindIndexKey); err != nil {
+        log.Fatal("Failed to initialize blind indexes:", err)
+    }
+
+    // Run a subcommand such as `minibank migrate up` instead of serving
+    if len(os.Args) > 1 {
+        runCommand(cfg, os.Args[1:])
+        return
+    }
 
-    // Initialize JWT
-    if err := utils.InitializeJWT(cfg.JWTSecret); err != nil {
+    // Initialize JWT, signing with the key store when one is configured
+    var jwtKeys *utils.JWTKeyStore
+    if cfg.JWTKeysDir != "" {
+        keys, err := utils.LoadJWTKeys(cfg.JWTKeysDir)
+        if err != nil {
+            log.Fatal("Failed to load JWT keys:", err)
+        }
+        jwtKeys = keys
+    }
+    if err := utils.InitializeJWT(cfg.JWTSecret, jwtKeys); err != nil {
         log.Fatal("Failed to initialize JWT:", err)
     }
 
     // Initialize database
-    db, err := database.Initialize(cfg.DatabaseURL)
+    db, err := database.Initialize(cfg.DatabaseURL, cfg.DatabasePool)
     if err != nil {
         log.Fatal("Failed to initialize database:", err)
     }
 
+    // Deliver emails to users
+    notifier, err := notify.New(cfg.Notifications)
+    if err != nil {
+        log.Fatal("Failed to initialize notifications:", err)
+    }
+
     // Initialize handlers with config
-    h := handlers.NewHandlers(db, cfg)
+    h := handlers.NewHandlers(db, cfg, notifier)
 
     // Initialize router
     r := mux.NewRouter()
@@ -56,17 +88,47 @@ func main() {
     r.HandleFunc("/api/register", h.Register).Methods("POST")
     r.HandleFunc("/api/login", h.Login).Methods("POST")
     r.HandleFunc("/api/health", h.HealthCheck).Methods("GET")
+    r.HandleFunc("/.well-known/jwks.json", h.JWKS).Methods("GET")
+    r.HandleFunc("/api/auth/refresh", h.RefreshToken).Methods("POST")
+    r.HandleFunc("/api/auth/2fa/verify", h.VerifyMFA).Methods("POST")
+    r.HandleFunc("/api/invitations/accept", h.AcceptInvitation).Methods("POST")
+    r.HandleFunc("/api/auth/password/forgot", h.ForgotPassword).Methods("POST")
+    r.HandleFunc("/api/auth/password/reset", h.ResetPassword).Methods("POST")
+    r.HandleFunc("/api/auth/email/verify", h.VerifyEmail).Methods("POST")
 
     // Protected routes
     protected := r.PathPrefix("/api").Subrouter()
-    protected.Use(middleware.JWTAuth)
-
-    // Debug endpoint (protected but not admin-only)
-    protected.HandleFunc("/debug/token", h.DebugToken).Methods("GET")
+    protected.Use(middleware.JWTAuth(db))
+    protected.Use(middleware.RestrictAPIKeys("/api/admin/"))
+
+    // Auth routes
+    protected.HandleFunc("/auth/logout", h.Logout).Methods("POST")
+    protected.HandleFunc("/auth/2fa/setup", h.SetupTwoFactor).Methods("POST")
+    protected.HandleFunc("/auth/2fa/confirm", h.ConfirmTwoFactor).Methods("POST")
+    protected.HandleFunc("/auth/2fa/disable", h.DisableTwoFactor).Methods("POST")
+    protected.HandleFunc("/auth/2fa/recovery-codes", h.RegenerateRecoveryCodes).Methods("POST")
+    protected.HandleFunc("/auth/email/resend", h.ResendVerification).Methods("POST")
+
+    // Debug endpoint (protected but not admin-only), only in development
+    if cfg.Environment == "development" {
+        protected.HandleFunc("/debug/token", h.DebugToken).Methods("GET")
+    }
 
     // User routes
     protected.HandleFunc("/user/profile", h.GetProfile).Methods("GET")
     protected.HandleFunc("/user/profile", h.UpdateProfile).Methods("PUT")
+    protected.HandleFunc("/user/sessions", h.ListSessions).Methods("GET")
+    protected.HandleFunc("/user/sessions/revoke-others", h.RevokeOtherSessions).Methods("POST")
+    protected.HandleFunc("/user/sessions/{id:[0-9]+}", h.RevokeSession).Methods("DELETE")
+    protected.HandleFunc("/user/transaction-pin", h.SetTransactionPIN).Methods("PUT")
+    protected.HandleFunc("/user/transaction-pin", h.RemoveTransactionPIN).Methods("DELETE")
+
+    // Account routes
+    protected.HandleFunc("/accounts", h.ListAccounts).Methods("GET")
+    protected.HandleFunc("/accounts", h.OpenAccount).Methods("POST")
+    protected.HandleFunc("/accounts/{id:[0-9]+}", h.GetAccount).Methods("GET")
+    protected.HandleFunc("/accounts/{id:[0-9]+}", h.UpdateAccount).Methods("PUT")
+    protected.HandleFunc("/accounts/{id:[0-9]+}", h.CloseAccount).Methods("DELETE")
 
     // KYC routes
     protected.HandleFunc("/kyc/submit", h.SubmitKYC).Methods("POST")
@@ -74,17 +136,83 @@ func main() {
 
     // Transaction routes
     protected.HandleFunc("/transactions", h.GetTransactions).Methods("GET")
-    protected.HandleFunc("/transactions/deposit", h.Deposit).Methods("POST")
-    protected.HandleFunc("/transactions/withdraw", h.Withdraw).Methods("POST")
-    protected.HandleFunc("/transactions/transfer", h.Transfer).Methods("POST")
-
-    // Admin routes
+    idempotent := middleware.Idempotency(db, cfg.IdempotencyWindow, cfg.IdempotencyLease)
+    protected.Handle("/transactions/deposit", idempotent(http.HandlerFunc(h.Deposit))).Methods("POST")
+    protected.Handle("/transactions/withdraw", idempotent(http.HandlerFunc(h.Withdraw))).Methods("POST")
+    protected.Handle("/transactions/transfer", idempotent(http.HandlerFunc(h.Transfer))).Methods("POST")
+    protected.HandleFunc("/transactions/payee", h.LookupPayee).Methods("GET")
+    protected.HandleFunc("/transactions/scheduled", h.ListScheduledTransfers).Methods("GET")
+    protected.Handle("/transactions/scheduled", idempotent(http.HandlerFunc(h.CreateScheduledTransfer))).Methods("POST")
+    protected.HandleFunc("/transactions/scheduled/{id:[0-9]+}", h.GetScheduledTransfer).Methods("GET")
+    protected.HandleFunc("/transactions/scheduled/{id:[0-9]+}", h.UpdateScheduledTransfer).Methods("PUT")
+    protected.HandleFunc("/transactions/scheduled/{id:[0-9]+}", h.CancelScheduledTransfer).Methods("DELETE")
+
+    // Beneficiary routes
+    protected.HandleFunc("/beneficiaries", h.ListBeneficiaries).Methods("GET")
+    protected.HandleFunc("/beneficiaries", h.AddBeneficiary).Methods("POST")
+    protected.HandleFunc("/beneficiaries/{id:[0-9]+}", h.UpdateBeneficiary).Methods("PUT")
+    protected.HandleFunc("/beneficiaries/{id:[0-9]+}", h.DeleteBeneficiary).Methods("DELETE")
+
+    // Hold routes
+    protected.HandleFunc("/holds", h.ListHolds).Methods("GET")
+    protected.Handle("/holds", idempotent(http.HandlerFunc(h.PlaceHold))).Methods("POST")
+    protected.HandleFunc("/holds/{id:[0-9]+}", h.GetHold).Methods("GET")
+    protected.Handle("/holds/{id:[0-9]+}/capture", idempotent(http.HandlerFunc(h.CaptureHold))).Methods("POST")
+    protected.Handle("/holds/{id:[0-9]+}/void", idempotent(http.HandlerFunc(h.VoidHold))).Methods("POST")
+
+    // Admin routes, each guarded by the permission it needs
     adminRoutes := protected.PathPrefix("/admin").Subrouter()
-    adminRoutes.Use(middleware.AdminAuth)
-    adminRoutes.HandleFunc("/kyc/pending", h.GetPendingKYC).Methods("GET")
-    adminRoutes.HandleFunc("/kyc/verify", h.VerifyKYC).Methods("POST")
-    adminRoutes.HandleFunc("/audit-logs", h.GetAuditLogs).Methods("GET")
-    adminRoutes.HandleFunc("/users", h.GetAllUsers).Methods("GET")
+    if cfg.TwoFactor.RequireForAdmins {
+        adminRoutes.Use(middleware.RequireMFA)
+    }
+    requires := middleware.RequirePermission
+    adminRoutes.Handle("/kyc/pending", requires(models.PermKYCRead)(http.HandlerFunc(h.GetPendingKYC))).Methods("GET")
+    adminRoutes.Handle("/kyc/verify", requires(models.PermKYCVerify)(http.HandlerFunc(h.VerifyKYC))).Methods("POST")
+    adminRoutes.Handle("/kyc/search", requires(models.PermKYCRead)(http.HandlerFunc(h.SearchKYCByDocument))).Methods("POST")
+    adminRoutes.Handle("/kyc/{id:[0-9]+}/matches", requires(models.PermKYCRead)(http.HandlerFunc(h.GetKYCMatches))).Methods("GET")
+    adminRoutes.Handle("/audit-logs", requires(models.PermAuditRead)(http.HandlerFunc(h.GetAuditLogs))).Methods("GET")
+    adminRoutes.Handle("/users", requires(models.PermUsersRead)(http.HandlerFunc(h.GetAllUsers))).Methods("GET")
+    adminRoutes.Handle("/users/{id:[0-9]+}", requires(models.PermUsersManage)(http.HandlerFunc(h.UpdateUserAccess))).Methods("PUT")
+    adminRoutes.Handle("/users/{id:[0-9]+}/unlock", requires(models.PermUsersManage)(http.HandlerFunc(h.UnlockUser))).Methods("POST")
+    adminRoutes.Handle("/users/{id:[0-9]+}/role", requires(models.PermRolesAssign)(http.HandlerFunc(h.AssignRole))).Methods("PUT")
+    adminRoutes.Handle("/roles", requires(models.PermRolesAssign)(http.HandlerFunc(h.ListRoles))).Methods("GET")
+    adminRoutes.Handle("/invitations", requires(models.PermRolesAssign)(http.HandlerFunc(h.ListInvitations))).Methods("GET")
+    adminRoutes.Handle("/invitations", requires(models.PermRolesAssign)(http.HandlerFunc(h.CreateInvitation))).Methods("POST")
+    adminRoutes.Handle("/invitations/{id:[0-9]+}", requires(models.PermRolesAssign)(http.HandlerFunc(h.RevokeInvitation))).Methods("DELETE")
+    adminRoutes.Handle("/ledger/verify", requires(models.PermLedgerRead)(http.HandlerFunc(h.VerifyLedger))).Methods("GET")
+    adminRoutes.Handle("/ledger/rebuild", requires(models.PermLedgerRebuild)(http.HandlerFunc(h.RebuildBalances))).Methods("POST")
+    adminRoutes.Handle("/transactions/reverse", requires(models.PermTransactionsReverse)(http.HandlerFunc(h.ReverseTransaction))).Methods("POST")
+    adminRoutes.Handle("/service-accounts", requires(models.PermServiceAccounts)(http.HandlerFunc(h.ListServiceAccounts))).Methods("GET")
+    adminRoutes.Handle("/service-accounts", requires(models.PermServiceAccounts)(http.HandlerFunc(h.CreateServiceAccount))).Methods("POST")
+    adminRoutes.Handle("/service-accounts/{id:[0-9]+}/keys", requires(models.PermServiceAccounts)(http.HandlerFunc(h.CreateAPIKey))).Methods("POST")
+    adminRoutes.Handle("/api-keys/{id:[0-9]+}", requires(models.PermServiceAccounts)(http.HandlerFunc(h.RevokeAPIKey))).Methods("DELETE")
+
+    // Maker-checker approvals; each request checks the permission its operation needs
+    adminRoutes.HandleFunc("/approvals", h.ListApprovals).Methods("GET")
+    adminRoutes.HandleFunc("/approvals/{id:[0-9]+}", h.GetApproval).Methods("GET")
+    adminRoutes.HandleFunc("/approvals/{id:[0-9]+}/approve", h.ApproveRequest).Methods("POST")
+    adminRoutes.HandleFunc("/approvals/{id:[0-9]+}/reject", h.RejectRequest).Methods("POST")
+    adminRoutes.HandleFunc("/approvals/{id:[0-9]+}/cancel", h.CancelApproval).Methods("POST")
+
+    // Release holds that were never captured
+    go h.RunHoldExpiry(time.Minute)
+
+    // Lapse approval requests nobody decided on in time
+    go h.RunApprovalExpiry(time.Minute)
+
+    // Drop revoked and refresh tokens once they have expired
+    go h.RunTokenCleanup(time.Hour)
+
+    // Execute scheduled transfers as they fall due
+    go h.RunScheduler(cfg.Scheduler.Interval)
+
+    // Move KYC records onto the current master key after a rotation
+    go h.RunKYCReencryption(cfg.KMS.ReencryptInterval)
+
+    // Pick up JWT keys rotated on disk
+    if jwtKeys != nil {
+        go utils.RunJWTKeyReload(time.Minute)
+    }
 
     port := cfg.Port
     if port == "" {
@@ -93,9 +221,8 @@ func main() {
 
     log.Printf("Server starting on port %s", port)
     log.Printf("Environment: %s", cfg.Environment)
-    log.Printf("Database: %s", cfg.DatabaseURL)
+    log.Printf("Database: %s", database.RedactURL(cfg.DatabaseURL))
     if cfg.Environment == "development" {
-        log.Printf("Admin Code: %s", cfg.AdminCode)
         log.Printf("Debug endpoint available at: /api/debug/token")
     }
     log.Fatal(http.ListenAndServe(":"+port, r))
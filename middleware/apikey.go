@@ -0,0 +1,103 @@
+package middleware
+
+import (
+    "crypto/subtle"
+    "log"
+    "net/http"
+    "strings"
+    "time"
+
+    "minibank-go/models"
+    "minibank-go/utils"
+
+    "golang.org/x/time/rate"
+    "gorm.io/gorm"
+)
+
+// APIKeyHeader carries an API key in place of a bearer token
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyLimits holds a bucket per API key, each with the key's own limit
+var apiKeyLimits = NewKeyedLimiter(rate.Inf, 0)
+
+// authenticateAPIKey checks an API key and returns claims for the service
+// account that owns it, carrying the key's scopes that the account's role
+// still grants. On failure it returns the status and message to refuse with.
+func authenticateAPIKey(db *gorm.DB, apiKey string, r *http.Request) (*utils.Claims, int, string) {
+    prefix, secret, ok := models.ParseAPIKey(apiKey)
+    if !ok {
+        return nil, http.StatusUnauthorized, "Invalid API key"
+    }
+
+    var key models.APIKey
+    if err := db.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, http.StatusUnauthorized, "Invalid API key"
+        }
+        log.Printf("API key lookup failed for %s: %v", r.URL.Path, err)
+        return nil, http.StatusInternalServerError, "Failed to validate API key"
+    }
+    if subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(key.SecretHash)) != 1 {
+        log.Printf("Wrong secret for API key %s on %s", prefix, r.URL.Path)
+        return nil, http.StatusUnauthorized, "Invalid API key"
+    }
+    now := time.Now()
+    if !key.Active(now) {
+        log.Printf("Revoked or expired API key %s used for %s", prefix, r.URL.Path)
+        return nil, http.StatusUnauthorized, "API key has expired or been revoked"
+    }
+
+    var owner models.User
+    if err := db.First(&owner, key.UserID).Error; err != nil || !owner.ServiceAccount || !owner.IsActive {
+        log.Printf("API key %s of missing or deactivated service account %d used for %s", prefix, key.UserID, r.URL.Path)
+        return nil, http.StatusUnauthorized, "Service account is deactivated"
+    }
+
+    limit := rate.Inf
+    if key.RateLimit > 0 {
+        limit = rate.Every(time.Minute / time.Duration(key.RateLimit))
+    }
+    if !apiKeyLimits.AllowWith(prefix, limit, key.RateLimit) {
+        return nil, http.StatusTooManyRequests, "API key rate limit exceeded"
+    }
+
+    if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > touchInterval {
+        if err := db.Model(&models.APIKey{}).Where("id = ?", key.ID).Updates(map[string]interface{}{
+            "last_used_at": now,
+            "last_used_ip": ClientIP(r),
+        }).Error; err != nil {
+            log.Printf("Failed to record use of API key %s: %v", prefix, err)
+        }
+    }
+
+    permissions := []string{}
+    for _, p := range models.RolePermissions(owner.Role) {
+        if key.Scopes.Has(p) {
+            permissions = append(permissions, p)
+        }
+    }
+    return &utils.Claims{
+        UserID:      owner.ID,
+        Email:       owner.Email,
+        Role:        owner.Role,
+        Permissions: permissions,
+        APIKeyID:    key.ID,
+    }, 0, ""
+}
+
+// RestrictAPIKeys refuses requests made with an API key outside the paths
+// under prefix. API keys are meant for the staff endpoints, where every route
+// checks a permission the key has to be scoped for.
+func RestrictAPIKeys(prefix string) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            claims := GetUserFromContext(r)
+            if claims != nil && claims.APIKeyID != 0 && !strings.HasPrefix(r.URL.Path, prefix) {
+                log.Printf("API key %d refused for %s", claims.APIKeyID, r.URL.Path)
+                http.Error(w, "API keys can only be used on staff endpoints", http.StatusForbidden)
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    }
+}
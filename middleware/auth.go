@@ -6,72 +6,174 @@ import (
     "log"
     "net/http"
     "strings"
+    "time"
 
+    "minibank-go/models"
     "minibank-go/utils"
+
+    "gorm.io/gorm"
 )
 
 type contextKey string
 
 const UserContextKey contextKey = "user"
 
-func JWTAuth(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        authHeader := r.Header.Get("Authorization")
-        if authHeader == "" {
-            log.Printf("No Authorization header found for %s", r.URL.Path)
-            http.Error(w, "Authorization header required", http.StatusUnauthorized)
-            return
-        }
+// touchInterval is how stale a session's last-seen time or an API key's
+// last-used time may get before a request updates it, so busy clients do not
+// write on every request
+const touchInterval = time.Minute
 
-        bearerToken := strings.Split(authHeader, " ")
-        if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-            log.Printf("Invalid Authorization header format for %s", r.URL.Path)
-            http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
-            return
-        }
+// JWTAuth authenticates requests by their bearer token, or by an API key in
+// the X-API-Key header; both put the same claims in the request context.
+// Tokens without an ID, issued for another purpose such as an MFA challenge,
+// on the revocation list or belonging to a session that has ended are
+// refused.
+func JWTAuth(db *gorm.DB) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            authHeader := r.Header.Get("Authorization")
+            if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+                if authHeader != "" {
+                    http.Error(w, "Use either a bearer token or an API key, not both", http.StatusUnauthorized)
+                    return
+                }
+                claims, status, message := authenticateAPIKey(db, apiKey, r)
+                if claims == nil {
+                    http.Error(w, message, status)
+                    return
+                }
 
-        claims, err := utils.ValidateToken(bearerToken[1])
-        if err != nil {
-            log.Printf("Token validation failed for %s: %v", r.URL.Path, err)
-            http.Error(w, "Invalid token", http.StatusUnauthorized)
-            return
-        }
+                log.Printf("API key %d validated for service account %d (%s), role: %s", claims.APIKeyID, claims.UserID, claims.Email, claims.Role)
 
-        log.Printf("Token validated for user %d (%s), admin: %v", claims.UserID, claims.Email, claims.IsAdmin)
+                ctx := context.WithValue(r.Context(), UserContextKey, claims)
+                next.ServeHTTP(w, r.WithContext(ctx))
+                return
+            }
 
-        ctx := context.WithValue(r.Context(), UserContextKey, claims)
-        next.ServeHTTP(w, r.WithContext(ctx))
-    })
+            if authHeader == "" {
+                log.Printf("No Authorization header found for %s", r.URL.Path)
+                http.Error(w, "Authorization header required", http.StatusUnauthorized)
+                return
+            }
+
+            bearerToken := strings.Split(authHeader, " ")
+            if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+                log.Printf("Invalid Authorization header format for %s", r.URL.Path)
+                http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+                return
+            }
+
+            claims, err := utils.ValidateToken(bearerToken[1])
+            if err != nil {
+                log.Printf("Token validation failed for %s: %v", r.URL.Path, err)
+                http.Error(w, "Invalid token", http.StatusUnauthorized)
+                return
+            }
+
+            if claims.ID == "" {
+                log.Printf("Token without ID refused for %s", r.URL.Path)
+                http.Error(w, "Invalid token", http.StatusUnauthorized)
+                return
+            }
+
+            if claims.Purpose != "" {
+                log.Printf("%s token used as an access token for %s", claims.Purpose, r.URL.Path)
+                http.Error(w, "Invalid token", http.StatusUnauthorized)
+                return
+            }
+
+            var revoked int64
+            if err := db.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&revoked).Error; err != nil {
+                log.Printf("Revocation check failed for %s: %v", r.URL.Path, err)
+                http.Error(w, "Failed to validate token", http.StatusInternalServerError)
+                return
+            }
+            if revoked > 0 {
+                log.Printf("Revoked token used by user %d for %s", claims.UserID, r.URL.Path)
+                http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+                return
+            }
+
+            // Tokens issued before sessions were recorded have none
+            if claims.SessionID != 0 {
+                var session models.Session
+                if err := db.First(&session, claims.SessionID).Error; err != nil && err != gorm.ErrRecordNotFound {
+                    log.Printf("Session check failed for %s: %v", r.URL.Path, err)
+                    http.Error(w, "Failed to validate token", http.StatusInternalServerError)
+                    return
+                }
+                if session.ID == 0 || session.RevokedAt != nil || session.UserID != claims.UserID {
+                    log.Printf("Token from ended session %d used by user %d for %s", claims.SessionID, claims.UserID, r.URL.Path)
+                    http.Error(w, "Session has been revoked", http.StatusUnauthorized)
+                    return
+                }
+                if now := time.Now(); now.Sub(session.LastSeenAt) > touchInterval {
+                    if err := db.Model(&models.Session{}).Where("id = ?", session.ID).Update("last_seen_at", now).Error; err != nil {
+                        log.Printf("Failed to update last-seen time of session %d: %v", session.ID, err)
+                    }
+                }
+            }
+
+            log.Printf("Token validated for user %d (%s), role: %s", claims.UserID, claims.Email, claims.Role)
+
+            ctx := context.WithValue(r.Context(), UserContextKey, claims)
+            next.ServeHTTP(w, r.WithContext(ctx))
+        })
+    }
 }
 
-func AdminAuth(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        claims, ok := r.Context().Value(UserContextKey).(*utils.Claims)
-        if !ok {
-            log.Printf("No user claims found in context for %s", r.URL.Path)
-            w.Header().Set("Content-Type", "application/json")
-            w.WriteHeader(http.StatusUnauthorized)
-            json.NewEncoder(w).Encode(map[string]string{
-                "error": "Unauthorized - No user context",
-            })
-            return
-        }
+// RequirePermission refuses requests whose token does not grant permission
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            claims, ok := r.Context().Value(UserContextKey).(*utils.Claims)
+            if !ok {
+                log.Printf("No user claims found in context for %s", r.URL.Path)
+                w.Header().Set("Content-Type", "application/json")
+                w.WriteHeader(http.StatusUnauthorized)
+                json.NewEncoder(w).Encode(map[string]string{
+                    "error": "Unauthorized - No user context",
+                })
+                return
+            }
+
+            if !claims.HasPermission(permission) {
+                log.Printf("User %d (%s) with role %s denied %s on %s",
+                    claims.UserID, claims.Email, claims.Role, permission, r.URL.Path)
+                w.Header().Set("Content-Type", "application/json")
+                w.WriteHeader(http.StatusForbidden)
+                json.NewEncoder(w).Encode(map[string]string{
+                    "error": "Permission denied",
+                    "message": "This endpoint requires the " + permission + " permission",
+                })
+                return
+            }
 
-        log.Printf("Admin check for user %d (%s): is_admin = %v", claims.UserID, claims.Email, claims.IsAdmin)
+            log.Printf("Permission %s granted to user %d (%s) for %s", permission, claims.UserID, claims.Email, r.URL.Path)
+            next.ServeHTTP(w, r)
+        })
+    }
+}
 
-        if !claims.IsAdmin {
-            log.Printf("User %d attempted to access admin endpoint %s without admin privileges", 
-                claims.UserID, r.URL.Path)
+// RequireMFA refuses staff tokens from logins that did not pass a second
+// factor. It is applied to the staff routes when REQUIRE_ADMIN_2FA is set.
+// Tokens without any permissions are left for the permission check to refuse,
+// and API keys, which are not logins, are let through.
+func RequireMFA(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        claims, ok := r.Context().Value(UserContextKey).(*utils.Claims)
+        if !ok || (len(claims.Permissions) > 0 && !claims.MFA && claims.APIKeyID == 0) {
+            if ok {
+                log.Printf("User %d refused at %s: login did not use two-factor authentication", claims.UserID, r.URL.Path)
+            }
             w.Header().Set("Content-Type", "application/json")
             w.WriteHeader(http.StatusForbidden)
             json.NewEncoder(w).Encode(map[string]string{
-                "error": "Admin access required",
-                "message": "This endpoint requires admin privileges",
+                "error": "Two-factor authentication required",
+                "message": "Enable two-factor authentication and log in again to use this endpoint",
             })
             return
         }
-
-        log.Printf("Admin access granted for user %d (%s) to %s", claims.UserID, claims.Email, r.URL.Path)
         next.ServeHTTP(w, r)
     })
 }
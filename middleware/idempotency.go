@@ -0,0 +1,203 @@
+package middleware
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "io"
+    "log"
+    "net/http"
+    "time"
+
+    "minibank-go/models"
+
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+const (
+    IdempotencyKeyHeader      = "Idempotency-Key"
+    IdempotencyReplayedHeader = "Idempotent-Replayed"
+    maxIdempotencyKeyLength   = 255
+)
+
+// responseRecorder passes a response through to the client while keeping a
+// copy so it can be stored against the idempotency key
+type responseRecorder struct {
+    http.ResponseWriter
+    status int
+    body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+    rec.status = status
+    rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+    if rec.status == 0 {
+        rec.status = http.StatusOK
+    }
+    rec.body.Write(b)
+    return rec.ResponseWriter.Write(b)
+}
+
+func writeIdempotencyError(w http.ResponseWriter, status int, message string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "status":    status,
+        "error":     message,
+        "timestamp": time.Now(),
+    })
+}
+
+// keepsIdempotencyKey reports whether a response is stored against its key
+// and replayed on a retry: successes, and validation failures that the same
+// request would meet again. Anything a retry could get past, such as a 403
+// step_up_required or insufficient balance, a 409 conflict, a 429 or a
+// server error, frees the key instead.
+func keepsIdempotencyKey(status int) bool {
+    switch {
+    case status >= 200 && status < 300:
+        return true
+    case status == http.StatusBadRequest, status == http.StatusNotFound, status == http.StatusUnprocessableEntity:
+        return true
+    }
+    return false
+}
+
+// Idempotency makes the wrapped handler safe to retry. Requests carrying an
+// Idempotency-Key header are recorded per user; a retry with the same key and
+// body replays the stored response, a retry with a different body is
+// rejected with 422, and keys expire after the given window. Only responses
+// that keepsIdempotencyKey accepts are stored. A request that never finished,
+// because the server crashed while handling it, gives up its key once the
+// lease has passed. Requests without the header are passed straight through.
+// It must run after JWTAuth.
+func Idempotency(db *gorm.DB, window, lease time.Duration) func(http.Handler) http.Handler {
+    go purgeExpiredIdempotencyKeys(db)
+
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            key := r.Header.Get(IdempotencyKeyHeader)
+            claims := GetUserFromContext(r)
+            if key == "" || claims == nil {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            if len(key) > maxIdempotencyKeyLength {
+                writeIdempotencyError(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+                return
+            }
+
+            body, err := io.ReadAll(r.Body)
+            if err != nil {
+                writeIdempotencyError(w, http.StatusBadRequest, "Failed to read request body")
+                return
+            }
+            r.Body = io.NopCloser(bytes.NewReader(body))
+
+            hash := sha256.New()
+            hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+            hash.Write(body)
+            requestHash := hex.EncodeToString(hash.Sum(nil))
+
+            record := models.IdempotencyKey{
+                UserID:      claims.UserID,
+                Key:         key,
+                Method:      r.Method,
+                Path:        r.URL.Path,
+                RequestHash: requestHash,
+                Status:      "in_progress",
+                ExpiresAt:   time.Now().Add(window),
+            }
+
+            claimed, err := claimIdempotencyKey(db, &record, lease)
+            if err != nil {
+                log.Printf("Failed to record idempotency key for user %d: %v", claims.UserID, err)
+                writeIdempotencyError(w, http.StatusInternalServerError, "Failed to record idempotency key")
+                return
+            }
+
+            if !claimed {
+                var existing models.IdempotencyKey
+                if err := db.Where("user_id = ? AND idempotency_key = ?", claims.UserID, key).First(&existing).Error; err != nil {
+                    writeIdempotencyError(w, http.StatusInternalServerError, "Failed to load idempotency key")
+                    return
+                }
+
+                switch {
+                case existing.RequestHash != requestHash:
+                    writeIdempotencyError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+                case existing.Status != "completed":
+                    writeIdempotencyError(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+                default:
+                    log.Printf("Replaying response for idempotency key %q of user %d", key, claims.UserID)
+                    if existing.ContentType != "" {
+                        w.Header().Set("Content-Type", existing.ContentType)
+                    }
+                    w.Header().Set(IdempotencyReplayedHeader, "true")
+                    w.WriteHeader(existing.ResponseCode)
+                    w.Write([]byte(existing.ResponseBody))
+                }
+                return
+            }
+
+            // A panicking handler frees the key rather than leaving it stuck
+            defer func() {
+                if p := recover(); p != nil {
+                    db.Delete(&record)
+                    panic(p)
+                }
+            }()
+
+            rec := &responseRecorder{ResponseWriter: w}
+            next.ServeHTTP(rec, r)
+
+            // Failures that roll back and may succeed next time free the key
+            if !keepsIdempotencyKey(rec.status) {
+                db.Delete(&record)
+                return
+            }
+
+            if err := db.Model(&record).Updates(map[string]interface{}{
+                "status":        "completed",
+                "response_code": rec.status,
+                "response_body": rec.body.String(),
+                "content_type":  rec.Header().Get("Content-Type"),
+            }).Error; err != nil {
+                log.Printf("Failed to store response for idempotency key %q of user %d: %v", key, claims.UserID, err)
+            }
+        })
+    }
+}
+
+// claimIdempotencyKey inserts the record unless the user already holds an
+// unexpired record for the key. An expired record is replaced, and so is one
+// still in progress after the lease, which was abandoned by a crash.
+func claimIdempotencyKey(db *gorm.DB, record *models.IdempotencyKey, lease time.Duration) (bool, error) {
+    now := time.Now()
+    if err := db.Where("user_id = ? AND idempotency_key = ?", record.UserID, record.Key).
+        Where(db.Where("expires_at < ?", now).Or("status = ? AND created_at < ?", "in_progress", now.Add(-lease))).
+        Delete(&models.IdempotencyKey{}).Error; err != nil {
+        return false, err
+    }
+
+    result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+    if result.Error != nil {
+        return false, result.Error
+    }
+    return result.RowsAffected == 1, nil
+}
+
+func purgeExpiredIdempotencyKeys(db *gorm.DB) {
+    for {
+        time.Sleep(time.Hour)
+        if err := db.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{}).Error; err != nil {
+            log.Printf("Failed to purge expired idempotency keys: %v", err)
+        }
+    }
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+    "net"
     "net/http"
     "sync"
     "time"
@@ -13,53 +14,90 @@ type visitor struct {
     lastSeen time.Time
 }
 
-var (
-    visitors = make(map[string]*visitor)
-    mtx      sync.RWMutex
-)
+// KeyedLimiter keeps a token bucket for each key, such as a client IP or an
+// email address. Buckets idle for a few minutes are dropped.
+type KeyedLimiter struct {
+    limit    rate.Limit
+    burst    int
+    visitors map[string]*visitor
+    mtx      sync.Mutex
+}
 
-func getVisitor(ip string) *rate.Limiter {
-    mtx.RLock()
-    v, exists := visitors[ip]
-    mtx.RUnlock()
+// NewKeyedLimiter returns a limiter allowing limit events per second per key,
+// with bursts of up to burst
+func NewKeyedLimiter(limit rate.Limit, burst int) *KeyedLimiter {
+    l := &KeyedLimiter{
+        limit:    limit,
+        burst:    burst,
+        visitors: make(map[string]*visitor),
+    }
+    go l.cleanup()
+    return l
+}
 
+// Allow reports whether an event for key may happen now
+func (l *KeyedLimiter) Allow(key string) bool {
+    l.mtx.Lock()
+    v, exists := l.visitors[key]
     if !exists {
-        limiter := rate.NewLimiter(10, 50) // 10 requests per second, burst of 50
-        mtx.Lock()
-        visitors[ip] = &visitor{limiter, time.Now()}
-        mtx.Unlock()
-        return limiter
+        v = &visitor{limiter: rate.NewLimiter(l.limit, l.burst)}
+        l.visitors[key] = v
     }
+    v.lastSeen = time.Now()
+    l.mtx.Unlock()
+
+    return v.limiter.Allow()
+}
 
+// AllowWith is Allow for keys that each have their own limit, such as API
+// keys. A bucket whose limit has changed is adjusted in place.
+func (l *KeyedLimiter) AllowWith(key string, limit rate.Limit, burst int) bool {
+    l.mtx.Lock()
+    v, exists := l.visitors[key]
+    if !exists {
+        v = &visitor{limiter: rate.NewLimiter(limit, burst)}
+        l.visitors[key] = v
+    } else if v.limiter.Limit() != limit || v.limiter.Burst() != burst {
+        v.limiter.SetLimit(limit)
+        v.limiter.SetBurst(burst)
+    }
     v.lastSeen = time.Now()
-    return v.limiter
+    l.mtx.Unlock()
+
+    return v.limiter.Allow()
 }
 
-func cleanupVisitors() {
+func (l *KeyedLimiter) cleanup() {
     for {
         time.Sleep(time.Minute)
-        mtx.Lock()
-        for ip, v := range visitors {
+        l.mtx.Lock()
+        for key, v := range l.visitors {
             if time.Since(v.lastSeen) > 3*time.Minute {
-                delete(visitors, ip)
+                delete(l.visitors, key)
             }
         }
-        mtx.Unlock()
+        l.mtx.Unlock()
     }
 }
 
-func init() {
-    go cleanupVisitors()
+// ClientIP returns the IP address a request came from, without the port
+func ClientIP(r *http.Request) string {
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
 }
 
+var visitors = NewKeyedLimiter(10, 50) // 10 requests per second, burst of 50
+
 func RateLimit(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        limiter := getVisitor(r.RemoteAddr)
-        if !limiter.Allow() {
+        if !visitors.Allow(ClientIP(r)) {
             http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
             return
         }
 
         next.ServeHTTP(w, r)
     })
-}
\ No newline at end of file
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+    "time"
+
+    "minibank-go/money"
+
+    "gorm.io/gorm"
+)
+
+type Account struct {
+    ID            uint           `json:"id" gorm:"primaryKey"`
+    UserID        uint           `json:"user_id" gorm:"index;not null"`
+    User          *User          `json:"user,omitempty" gorm:"foreignKey:UserID"`
+    AccountNumber string         `json:"account_number" gorm:"uniqueIndex;size:34;not null"`
+    Type          string         `json:"type" gorm:"not null"` // savings, current, wallet
+    Nickname      string         `json:"nickname"`
+    Currency      money.Currency `json:"currency" gorm:"size:3;not null;default:INR"`
+    Balance       money.Amount   `json:"balance" gorm:"not null;default:0"`
+    HeldAmount    money.Amount   `json:"held_amount" gorm:"not null;default:0"` // sum of pending holds
+    Available     money.Amount   `json:"available_balance" gorm:"-"`            // balance less holds
+    Status        string         `json:"status" gorm:"not null;default:active"` // active, frozen, closed
+    Version       uint           `json:"-" gorm:"not null;default:1"`            // bumped on every balance change
+    OpenedAt      time.Time      `json:"opened_at" gorm:"not null"`
+    ClosedAt      *time.Time     `json:"closed_at"`
+    CreatedAt     time.Time      `json:"created_at"`
+    UpdatedAt     time.Time      `json:"updated_at"`
+    DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Refresh recomputes the available balance after the balance or holds change
+func (a *Account) Refresh() {
+    a.Available = a.Balance - a.HeldAmount
+}
+
+func (a *Account) AfterFind(tx *gorm.DB) error {
+    a.Refresh()
+    return nil
+}
+
+type OpenAccountRequest struct {
+    Type     string         `json:"type" validate:"required,oneof=savings current wallet"`
+    Currency money.Currency `json:"currency" validate:"omitempty,len=3"`
+    Nickname string         `json:"nickname" validate:"omitempty,max=50"`
+}
+
+type UpdateAccountRequest struct {
+    Nickname string `json:"nickname" validate:"max=50"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+    "database/sql/driver"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to recognise
+const APIKeyPrefix = "mbk"
+
+// ScopeList is a set of permissions, stored as one space-separated column
+type ScopeList []string
+
+// Has reports whether the list contains scope
+func (s ScopeList) Has(scope string) bool {
+    for _, v := range s {
+        if v == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// Value stores the scopes separated by spaces
+func (s ScopeList) Value() (driver.Value, error) {
+    return strings.Join(s, " "), nil
+}
+
+// Scan reads a space-separated column
+func (s *ScopeList) Scan(src interface{}) error {
+    switch v := src.(type) {
+    case nil:
+        *s = nil
+    case []byte:
+        *s = strings.Fields(string(v))
+    case string:
+        *s = strings.Fields(v)
+    default:
+        return fmt.Errorf("cannot scan %T into ScopeList", src)
+    }
+    return nil
+}
+
+// APIKey lets a service account call the staff endpoints without logging in.
+// The key reads mbk_<prefix>_<secret>; the prefix finds the record and only
+// a SHA-256 hash of the secret is stored. A key can only use the scopes it
+// was given that its service account's role still grants.
+type APIKey struct {
+    ID          uint       `json:"id" gorm:"primaryKey"`
+    UserID      uint       `json:"user_id" gorm:"index;not null"` // the service account
+    Name        string     `json:"name" gorm:"size:100;not null"`
+    Prefix      string     `json:"prefix" gorm:"uniqueIndex;size:16;not null"`
+    SecretHash  string     `json:"-" gorm:"size:64;not null"`
+    Scopes      ScopeList  `json:"scopes" gorm:"type:text;not null"`
+    RateLimit   int        `json:"rate_limit" gorm:"not null"` // requests per minute
+    ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+    LastUsedAt  *time.Time `json:"last_used_at"`
+    LastUsedIP  string     `json:"last_used_ip" gorm:"size:45"`
+    RevokedAt   *time.Time `json:"revoked_at"`
+    CreatedByID uint       `json:"created_by_id" gorm:"not null"`
+    CreatedAt   time.Time  `json:"created_at"`
+    UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Active reports whether the key can be used at t
+func (k *APIKey) Active(t time.Time) bool {
+    return k.RevokedAt == nil && t.Before(k.ExpiresAt)
+}
+
+// ParseAPIKey splits a key into its prefix and secret
+func ParseAPIKey(key string) (prefix, secret string, ok bool) {
+    parts := strings.SplitN(key, "_", 3)
+    if len(parts) != 3 || parts[0] != APIKeyPrefix || parts[1] == "" || parts[2] == "" {
+        return "", "", false
+    }
+    return parts[1], parts[2], true
+}
+
+type CreateServiceAccountRequest struct {
+    Name string `json:"name" validate:"required,min=3,max=24"` // lowercase letters, digits and dashes
+    Role string `json:"role" validate:"required"`
+}
+
+type CreateAPIKeyRequest struct {
+    Name      string     `json:"name" validate:"required,max=100"`
+    Scopes    []string   `json:"scopes" validate:"required,min=1"`
+    RateLimit int        `json:"rate_limit" validate:"min=0,max=10000"` // defaults to API_KEY_RATE_LIMIT
+    ExpiresAt *time.Time `json:"expires_at"`                            // defaults to API_KEY_TTL from now
+}
+
+// ServiceAccountResponse is a service account with its API keys
+type ServiceAccountResponse struct {
+    User
+    APIKeys []APIKey `json:"api_keys"`
+}
+
+type APIKeyResponse struct {
+    APIKey
+    Key string `json:"key"` // shown once
+}
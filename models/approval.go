@@ -0,0 +1,40 @@
+package models
+
+import (
+    "encoding/json"
+    "time"
+)
+
+// ApprovalRequest is a sensitive admin operation waiting for a second staff
+// member to sign off on it. The maker submits the operation with its payload;
+// a checker other than the maker approves it, which executes it, or rejects
+// it. Requests not decided before ExpiresAt lapse.
+type ApprovalRequest struct {
+    ID        uint            `json:"id" gorm:"primaryKey"`
+    Operation string          `json:"operation" gorm:"size:50;index;not null"` // kyc.verify, transaction.reverse
+    Payload   json.RawMessage `json:"payload" gorm:"not null"`
+    Summary   string          `json:"summary" gorm:"size:255"`
+    Status    string          `json:"status" gorm:"size:20;index;not null;default:pending"` // pending, executed, rejected, failed, cancelled, expired
+    MakerID   uint            `json:"maker_id" gorm:"index;not null"`
+    CheckerID *uint           `json:"checker_id"`
+    Comment   string          `json:"comment"` // the checker's note
+    Result    json.RawMessage `json:"result,omitempty"`
+    Error     string          `json:"error,omitempty"` // why execution failed
+    ExpiresAt time.Time       `json:"expires_at" gorm:"not null"`
+    DecidedAt *time.Time      `json:"decided_at"`
+    CreatedAt time.Time       `json:"created_at"`
+    UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type ApprovalDecisionRequest struct {
+    Comment string `json:"comment" validate:"max=255"`
+}
+
+type RejectApprovalRequest struct {
+    Comment string `json:"comment" validate:"required,max=255"`
+}
+
+type ApprovalResponse struct {
+    Message  string          `json:"message"`
+    Approval ApprovalRequest `json:"approval"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+    "time"
+)
+
+// Beneficiary is a payee saved to a user's address book. Transfers to a
+// beneficiary are limited until its cooling-off period has passed.
+type Beneficiary struct {
+    ID               uint       `json:"id" gorm:"primaryKey"`
+    UserID           uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_beneficiaries_user_account"`
+    AccountID        uint       `json:"-" gorm:"not null;uniqueIndex:idx_beneficiaries_user_account"`
+    Account          *Account   `json:"-" gorm:"foreignKey:AccountID"`
+    AccountNumber    string     `json:"account_number" gorm:"-"` // masked
+    Name             string     `json:"name" gorm:"size:100;not null"`
+    Nickname         string     `json:"nickname" gorm:"size:50"`
+    CoolingOffEndsAt *time.Time `json:"cooling_off_ends_at,omitempty" gorm:"-"`
+    CreatedAt        time.Time  `json:"added_at"`
+    UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+type AddBeneficiaryRequest struct {
+    To       string `json:"to" validate:"required"` // account number, email or phone
+    Nickname string `json:"nickname" validate:"max=50"`
+}
+
+type UpdateBeneficiaryRequest struct {
+    Nickname string `json:"nickname" validate:"max=50"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+    "time"
+
+    "minibank-go/money"
+)
+
+// Hold reserves part of an account's balance for a later capture, as a card
+// authorization does. A pending hold lowers the available balance but posts
+// nothing to the ledger; only the captured amount is ever posted.
+type Hold struct {
+    ID             uint           `json:"id" gorm:"primaryKey"`
+    AccountID      uint           `json:"account_id" gorm:"index;not null"`
+    Account        *Account       `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+    Reference      string         `json:"reference" gorm:"uniqueIndex;size:64;not null"`
+    Amount         money.Amount   `json:"amount" gorm:"not null"`
+    CapturedAmount money.Amount   `json:"captured_amount" gorm:"not null;default:0"`
+    Currency       money.Currency `json:"currency" gorm:"size:3;not null;default:INR"`
+    Description    string         `json:"description"`
+    Status         string         `json:"status" gorm:"size:20;index;not null;default:pending"` // pending, captured, voided, expired
+    TransactionID  *uint          `json:"transaction_id"`                                     // the capture transaction
+    ExpiresAt      time.Time      `json:"expires_at" gorm:"index;not null"`
+    CapturedAt     *time.Time     `json:"captured_at"`
+    ReleasedAt     *time.Time     `json:"released_at"` // when voided or expired
+    CreatedAt      time.Time      `json:"created_at"`
+    UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+type PlaceHoldRequest struct {
+    AccountID   uint         `json:"account_id"`
+    Amount      money.Amount `json:"amount" validate:"required,money_min=1.00"`
+    Description string       `json:"description" validate:"max=255"`
+}
+
+type CaptureHoldRequest struct {
+    Amount money.Amount `json:"amount"` // defaults to the full hold amount
+}
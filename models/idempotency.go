@@ -0,0 +1,24 @@
+package models
+
+import (
+    "time"
+)
+
+// IdempotencyKey records a money-moving request made with an Idempotency-Key
+// header so that a retry replays the original response instead of moving
+// money twice.
+type IdempotencyKey struct {
+    ID           uint      `json:"id" gorm:"primaryKey"`
+    UserID       uint      `json:"user_id" gorm:"uniqueIndex:idx_idempotency_user_key;not null"`
+    Key          string    `json:"key" gorm:"column:idempotency_key;uniqueIndex:idx_idempotency_user_key;size:255;not null"`
+    Method       string    `json:"method" gorm:"not null"`
+    Path         string    `json:"path" gorm:"not null"`
+    RequestHash  string    `json:"request_hash" gorm:"not null"`
+    Status       string    `json:"status" gorm:"not null;default:in_progress"` // in_progress, completed
+    ResponseCode int       `json:"response_code"`
+    ResponseBody string    `json:"response_body"`
+    ContentType  string    `json:"content_type"`
+    ExpiresAt    time.Time `json:"expires_at" gorm:"index;not null"`
+    CreatedAt    time.Time `json:"created_at"`
+    UpdatedAt    time.Time `json:"updated_at"`
+}
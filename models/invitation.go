@@ -0,0 +1,39 @@
+package models
+
+import (
+    "time"
+)
+
+// Invitation lets a superadmin bring a new staff member in with a role.
+// The token is shown once when the invitation is issued; only its SHA-256
+// hash is stored. It can be accepted once, before it expires.
+type Invitation struct {
+    ID          uint       `json:"id" gorm:"primaryKey"`
+    Email       string     `json:"email" gorm:"index;size:255;not null"`
+    Role        string     `json:"role" gorm:"size:20;not null"`
+    TokenHash   string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+    InvitedByID uint       `json:"invited_by_id" gorm:"not null"`
+    ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+    AcceptedAt  *time.Time `json:"accepted_at"`
+    UserID      *uint      `json:"user_id"` // the user created on acceptance
+    RevokedAt   *time.Time `json:"revoked_at"`
+    CreatedAt   time.Time  `json:"created_at"`
+}
+
+type CreateInvitationRequest struct {
+    Email string `json:"email" validate:"required,email"`
+    Role  string `json:"role" validate:"required"`
+}
+
+type AcceptInvitationRequest struct {
+    Token     string `json:"token" validate:"required"`
+    Phone     string `json:"phone" validate:"required,min=10,max=15"`
+    Password  string `json:"password" validate:"required,min=8"`
+    FirstName string `json:"first_name" validate:"required,min=2"`
+    LastName  string `json:"last_name" validate:"required,min=2"`
+}
+
+type InvitationResponse struct {
+    Invitation
+    Token string `json:"token"` // shown once
+}
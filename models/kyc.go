@@ -1,9 +1,19 @@
 package models
 
 import (
+    "context"
+    "fmt"
     "time"
 
     "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// Identity document types, which keep the blind indexes of each apart
+const (
+    DocumentPAN      = "pan"
+    DocumentAadhaar  = "aadhaar"
+    DocumentPassport = "passport"
 )
 
 type KYC struct {
@@ -13,6 +23,10 @@ type KYC struct {
     PAN            string         `json:"pan" gorm:"not null"`
     AadhaarNumber  string         `json:"aadhaar_number"`
     PassportNumber string         `json:"passport_number"`
+    KeyID          string         `json:"-" gorm:"size:64;not null;default:'';index"` // master key the document numbers are encrypted under; empty before envelope encryption
+    PANIndex       DocumentIndex  `json:"-" gorm:"size:64;index:,unique,where:pan_index <> ''"` // blind indexes of the document numbers, empty when there is no number
+    AadhaarIndex   DocumentIndex  `json:"-" gorm:"size:64;index:,unique,where:aadhaar_index <> ''"`
+    PassportIndex  DocumentIndex  `json:"-" gorm:"size:64;index:,unique,where:passport_index <> ''"`
     DateOfBirth    time.Time      `json:"date_of_birth" gorm:"not null"`
     Address        string         `json:"address" gorm:"not null"`
     City           string         `json:"city" gorm:"not null"`
@@ -27,6 +41,35 @@ type KYC struct {
     DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// DocumentIndex is the blind index of a document number, empty when there
+// is no number. Each number can be on only one KYC record. MySQL cannot
+// leave empty values out of a unique index, so there an empty index is
+// stored as NULL.
+type DocumentIndex string
+
+// GormValue writes an empty index as NULL on MySQL
+func (d DocumentIndex) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+    if d == "" && db.Dialector.Name() == "mysql" {
+        return clause.Expr{SQL: "NULL"}
+    }
+    return clause.Expr{SQL: "?", Vars: []interface{}{string(d)}}
+}
+
+// Scan reads an index, taking NULL as empty
+func (d *DocumentIndex) Scan(src interface{}) error {
+    switch v := src.(type) {
+    case nil:
+        *d = ""
+    case string:
+        *d = DocumentIndex(v)
+    case []byte:
+        *d = DocumentIndex(v)
+    default:
+        return fmt.Errorf("cannot scan %T into a document index", src)
+    }
+    return nil
+}
+
 type KYCRequest struct {
     PAN            string    `json:"pan" validate:"required,len=10"`
     AadhaarNumber  string    `json:"aadhaar_number" validate:"omitempty,len=12"`
@@ -41,5 +84,25 @@ type KYCRequest struct {
 type KYCVerificationRequest struct {
     KYCID           uint   `json:"kyc_id" validate:"required"`
     Status          string `json:"status" validate:"required,oneof=verified rejected"`
-    RejectionReason string `json:"rejection_reason"`
+    RejectionReason string `json:"rejection_reason" validate:"max=255"`
+}
+
+// KYCDocumentSearchRequest looks up KYC records by document number. At least
+// one number is required.
+type KYCDocumentSearchRequest struct {
+    PAN            string `json:"pan" validate:"max=32"`
+    AadhaarNumber  string `json:"aadhaar_number" validate:"max=32"`
+    PassportNumber string `json:"passport_number" validate:"max=32"`
+}
+
+// KYCIdentityMatch is a customer whose KYC record holds one of the document
+// numbers looked for
+type KYCIdentityMatch struct {
+    KYCID     uint     `json:"kyc_id"`
+    Status    string   `json:"status"`
+    UserID    uint     `json:"user_id"`
+    Email     string   `json:"email"`
+    FirstName string   `json:"first_name"`
+    LastName  string   `json:"last_name"`
+    Documents []string `json:"documents"` // the document types that matched
 }
\ No newline at end of file
@@ -0,0 +1,45 @@
+package models
+
+import (
+    "time"
+
+    "minibank-go/money"
+)
+
+// LedgerAccount is an account in the general ledger. Each customer Account
+// has a liability ledger account; system accounts such as cash in vault and suspense
+// hold the other side of every movement.
+type LedgerAccount struct {
+    ID            uint           `json:"id" gorm:"primaryKey"`
+    Code          string         `json:"code" gorm:"uniqueIndex;size:100;not null"`
+    Name          string         `json:"name" gorm:"not null"`
+    Type          string         `json:"type" gorm:"not null"`           // asset, liability, equity
+    NormalBalance string         `json:"normal_balance" gorm:"not null"` // debit, credit
+    AccountID     *uint          `json:"account_id" gorm:"index"`
+    Currency      money.Currency `json:"currency" gorm:"size:3;not null;default:INR"`
+    CreatedAt     time.Time      `json:"created_at"`
+    UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// JournalEntry groups the postings of one business event. Its postings must
+// sum to zero.
+type JournalEntry struct {
+    ID          uint      `json:"id" gorm:"primaryKey"`
+    Reference   string    `json:"reference" gorm:"index;not null"`
+    Type        string    `json:"type" gorm:"not null"` // deposit, withdraw, transfer, opening_balance, capture, reversal
+    Description string    `json:"description"`
+    PostedAt    time.Time `json:"posted_at" gorm:"not null"`
+    Postings    []Posting `json:"postings" gorm:"foreignKey:JournalEntryID"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+// Posting is one leg of a journal entry. Debits are positive amounts and
+// credits are negative amounts.
+type Posting struct {
+    ID              uint           `json:"id" gorm:"primaryKey"`
+    JournalEntryID  uint           `json:"journal_entry_id" gorm:"index;not null"`
+    LedgerAccountID uint           `json:"ledger_account_id" gorm:"index;not null"`
+    LedgerAccount   *LedgerAccount `json:"ledger_account,omitempty" gorm:"foreignKey:LedgerAccountID"`
+    Amount          money.Amount   `json:"amount" gorm:"not null"`
+    CreatedAt       time.Time      `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+    "time"
+)
+
+// LoginFailure counts consecutive failed logins for an email address. It is
+// kept whether or not a user has that email, so the responses to a guessed
+// email cannot tell it apart from a registered one. A successful login or an
+// admin unlock clears it.
+type LoginFailure struct {
+    ID           uint       `json:"id" gorm:"primaryKey"`
+    Email        string     `json:"email" gorm:"uniqueIndex;size:255;not null"`
+    Failures     int        `json:"failures" gorm:"not null;default:0"`
+    LastFailedAt time.Time  `json:"last_failed_at" gorm:"index;not null"`
+    LockedUntil  *time.Time `json:"locked_until"`
+}
+
+// MFAFailure counts wrong codes given for one MFA challenge token. Once
+// they reach MFA_MAX_ATTEMPTS the challenge is spent and the user has to
+// log in again.
+type MFAFailure struct {
+    ID          uint      `json:"id" gorm:"primaryKey"`
+    ChallengeID string    `json:"challenge_id" gorm:"uniqueIndex;size:36;not null"` // jti of the challenge token
+    UserID      uint      `json:"user_id" gorm:"index;not null"`
+    Failures    int       `json:"failures" gorm:"not null;default:0"`
+    ExpiresAt   time.Time `json:"expires_at" gorm:"index;not null"`
+}
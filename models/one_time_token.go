@@ -0,0 +1,38 @@
+package models
+
+import (
+    "time"
+)
+
+// Purposes a OneTimeToken is issued for
+const (
+    PurposePasswordReset     = "password_reset"
+    PurposeEmailVerification = "email_verification"
+)
+
+// OneTimeToken is emailed to a user to prove they control their address,
+// either to reset their password or to verify the address. Only its SHA-256
+// hash is stored. It works once, before it expires, and issuing a new one
+// for the same purpose replaces any the user still holds.
+type OneTimeToken struct {
+    ID        uint       `json:"id" gorm:"primaryKey"`
+    UserID    uint       `json:"user_id" gorm:"index;not null"`
+    Purpose   string     `json:"purpose" gorm:"size:30;not null"`
+    TokenHash string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+    ExpiresAt time.Time  `json:"expires_at" gorm:"index;not null"`
+    UsedAt    *time.Time `json:"used_at"`
+    CreatedAt time.Time  `json:"created_at"`
+}
+
+type ForgotPasswordRequest struct {
+    Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+    Token    string `json:"token" validate:"required"`
+    Password string `json:"password" validate:"required,min=8"`
+}
+
+type VerifyEmailRequest struct {
+    Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+    "sort"
+)
+
+// Roles a user can hold. Every user has exactly one; staff roles add
+// permissions on top of what any customer can do with their own accounts.
+const (
+    RoleCustomer   = "customer"
+    RoleTeller     = "teller"
+    RoleKYCOfficer = "kyc_officer"
+    RoleCompliance = "compliance"
+    RoleAuditor    = "auditor"
+    RoleSuperadmin = "superadmin"
+)
+
+// Permissions guard the staff endpoints
+const (
+    PermKYCRead             = "kyc:read"
+    PermKYCVerify           = "kyc:verify"
+    PermUsersRead           = "users:read"
+    PermUsersManage         = "users:manage" // activate and deactivate users
+    PermRolesAssign         = "roles:assign"
+    PermAuditRead           = "audit:read"
+    PermLedgerRead          = "ledger:read"
+    PermLedgerRebuild       = "ledger:rebuild"
+    PermTransactionsReverse = "transactions:reverse"
+    PermServiceAccounts     = "service_accounts:manage" // create service accounts and their API keys
+)
+
+var rolePermissions = map[string][]string{
+    RoleCustomer: {},
+    RoleTeller: {
+        PermUsersRead, PermKYCRead,
+    },
+    RoleKYCOfficer: {
+        PermUsersRead, PermKYCRead, PermKYCVerify,
+    },
+    RoleCompliance: {
+        PermUsersRead, PermUsersManage, PermKYCRead, PermAuditRead,
+        PermLedgerRead, PermTransactionsReverse,
+    },
+    RoleAuditor: {
+        PermUsersRead, PermKYCRead, PermAuditRead, PermLedgerRead,
+    },
+    RoleSuperadmin: {
+        PermUsersRead, PermUsersManage, PermRolesAssign, PermKYCRead, PermKYCVerify,
+        PermAuditRead, PermLedgerRead, PermLedgerRebuild, PermTransactionsReverse,
+        PermServiceAccounts,
+    },
+}
+
+// RoleInfo describes a role and what it grants
+type RoleInfo struct {
+    Name        string   `json:"name"`
+    Permissions []string `json:"permissions"`
+}
+
+type AssignRoleRequest struct {
+    Role string `json:"role" validate:"required"`
+}
+
+// ValidRole reports whether role is one of the defined roles
+func ValidRole(role string) bool {
+    _, ok := rolePermissions[role]
+    return ok
+}
+
+// IsStaffRole reports whether a role grants any staff permissions
+func IsStaffRole(role string) bool {
+    return len(rolePermissions[role]) > 0
+}
+
+// RolePermissions returns the permissions a role grants. Unknown roles grant
+// none.
+func RolePermissions(role string) []string {
+    return append([]string{}, rolePermissions[role]...)
+}
+
+// IsPermission reports whether permission is granted by any role
+func IsPermission(permission string) bool {
+    for _, perms := range rolePermissions {
+        for _, p := range perms {
+            if p == permission {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// Roles lists every role with its permissions
+func Roles() []RoleInfo {
+    roles := make([]RoleInfo, 0, len(rolePermissions))
+    for name, perms := range rolePermissions {
+        roles = append(roles, RoleInfo{Name: name, Permissions: append([]string{}, perms...)})
+    }
+    sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+    return roles
+}
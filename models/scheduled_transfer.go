@@ -0,0 +1,68 @@
+package models
+
+import (
+    "time"
+
+    "minibank-go/money"
+)
+
+// ScheduledTransfer is a standing order: a transfer made later on the user's
+// behalf, either once at StartAt or repeatedly on a cron schedule.
+type ScheduledTransfer struct {
+    ID             uint                   `json:"id" gorm:"primaryKey"`
+    UserID         uint                   `json:"user_id" gorm:"index;not null"`
+    FromAccountID  uint                   `json:"from_account_id" gorm:"not null"`
+    ToAccountID    *uint                  `json:"to_account_id"`                // one of the user's own accounts
+    To             string                 `json:"to,omitempty" gorm:"size:34"` // payee account number
+    Amount         money.Amount           `json:"amount" gorm:"not null"`
+    Currency       money.Currency         `json:"currency" gorm:"size:3;not null;default:INR"`
+    Description    string                 `json:"description"`
+    Schedule       string                 `json:"schedule,omitempty" gorm:"size:100"` // cron expression, empty for a one-off transfer
+    StartAt        time.Time              `json:"start_at" gorm:"not null"`
+    EndAt          *time.Time             `json:"end_at"`
+    MaxOccurrences int                    `json:"max_occurrences" gorm:"not null;default:0"` // 0 means no limit
+    Occurrences    int                    `json:"occurrences" gorm:"not null;default:0"`     // occurrences settled so far, paid or missed
+    Attempts       int                    `json:"attempts" gorm:"not null;default:0"`        // failed attempts at the current occurrence
+    NextRunAt      *time.Time             `json:"next_run_at" gorm:"index"`
+    LastRunAt      *time.Time             `json:"last_run_at"`
+    LastError      string                 `json:"last_error,omitempty"`
+    Status         string                 `json:"status" gorm:"size:20;index;not null;default:active"` // active, paused, completed, failed, cancelled
+    Version        uint                   `json:"-" gorm:"not null;default:1"`
+    Runs           []ScheduledTransferRun `json:"runs,omitempty" gorm:"foreignKey:ScheduledTransferID"`
+    CreatedAt      time.Time              `json:"created_at"`
+    UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+// ScheduledTransferRun records one attempt at executing a scheduled transfer
+type ScheduledTransferRun struct {
+    ID                  uint      `json:"id" gorm:"primaryKey"`
+    ScheduledTransferID uint      `json:"scheduled_transfer_id" gorm:"not null;uniqueIndex:idx_scheduled_transfer_runs_attempt"`
+    Occurrence          int       `json:"occurrence" gorm:"not null;uniqueIndex:idx_scheduled_transfer_runs_attempt"`
+    Attempt             int       `json:"attempt" gorm:"not null;uniqueIndex:idx_scheduled_transfer_runs_attempt"`
+    DueAt               time.Time `json:"due_at" gorm:"not null"`
+    Status              string    `json:"status" gorm:"size:20;not null"` // succeeded, failed
+    Reference           string    `json:"reference,omitempty"`
+    Error               string    `json:"error,omitempty"`
+    CreatedAt           time.Time `json:"created_at"`
+}
+
+type CreateScheduledTransferRequest struct {
+    FromAccountID  uint         `json:"from_account_id"`
+    ToAccountID    uint         `json:"to_account_id" validate:"required_without=To"`
+    To             string       `json:"to" validate:"required_without=ToAccountID"`
+    Amount         money.Amount `json:"amount" validate:"required,money_min=1.00"`
+    Description    string       `json:"description" validate:"max=255"`
+    Schedule       string       `json:"schedule" validate:"max=100"`
+    StartAt        *time.Time   `json:"start_at" validate:"required_without=Schedule"`
+    EndAt          *time.Time   `json:"end_at"`
+    MaxOccurrences int          `json:"max_occurrences" validate:"min=0"`
+}
+
+type UpdateScheduledTransferRequest struct {
+    Amount         *money.Amount `json:"amount"`
+    Description    *string       `json:"description" validate:"omitempty,max=255"`
+    Schedule       *string       `json:"schedule" validate:"omitempty,max=100"`
+    EndAt          *time.Time    `json:"end_at"`
+    MaxOccurrences *int          `json:"max_occurrences" validate:"omitempty,min=0"`
+    Status         string        `json:"status" validate:"omitempty,oneof=active paused"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+    "time"
+)
+
+// Session is one login on one device. The refresh tokens rotated from that
+// login share its family, and the access tokens issued with them carry its
+// ID, so revoking the session signs that device out.
+type Session struct {
+    ID         uint       `json:"id" gorm:"primaryKey"`
+    UserID     uint       `json:"user_id" gorm:"index;not null"`
+    FamilyID   string     `json:"-" gorm:"uniqueIndex;size:36;not null"` // refresh token family
+    DeviceName string     `json:"device_name" gorm:"size:100"`
+    UserAgent  string     `json:"user_agent"`
+    IPAddress  string     `json:"ip_address" gorm:"size:45"`
+    MFA        bool       `json:"mfa" gorm:"not null;default:false"` // the login passed a second factor
+    LastSeenAt time.Time  `json:"last_seen_at" gorm:"not null"`
+    ExpiresAt  time.Time  `json:"expires_at" gorm:"index;not null"` // when its refresh token runs out
+    RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+    Current    bool       `json:"current" gorm:"-"` // the session of the token making the request
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+    "minibank-go/money"
+)
+
+// Ways of completing a step-up check
+const (
+    StepUpPIN  = "pin"
+    StepUpTOTP = "totp"
+)
+
+// Headers carrying step-up credentials. They are kept out of request bodies
+// so a payment retried with a PIN is still the same request to the
+// idempotency check, and the PIN is never stored with its response.
+const (
+    StepUpPINHeader  = "X-Step-Up-PIN"
+    StepUpTOTPHeader = "X-Step-Up-TOTP"
+)
+
+// StepUp carries the extra proof of identity that payments above
+// STEP_UP_THRESHOLD need: the transaction PIN or a current TOTP code
+type StepUp struct {
+    PIN      string `validate:"omitempty,numeric,min=4,max=6"`
+    TOTPCode string `validate:"omitempty,numeric,len=6"`
+}
+
+// Provided reports whether the request carries any step-up credential
+func (s StepUp) Provided() bool {
+    return s.PIN != "" || s.TOTPCode != ""
+}
+
+// StepUpChallenge is the details of an error refusing a payment for want of
+// step-up authentication. Code tells clients what to do next:
+// step_up_required (ask for a PIN or code and retry), step_up_failed (the PIN
+// or code was wrong) or step_up_locked (wait RetryAfter seconds).
+type StepUpChallenge struct {
+    Code              string       `json:"code"`
+    Methods           []string     `json:"methods"`   // empty until the user sets a PIN or enables 2FA
+    Threshold         money.Amount `json:"threshold"` // payments above this need step-up
+    AttemptsRemaining *int         `json:"attempts_remaining,omitempty"`
+    RetryAfter        int          `json:"retry_after,omitempty"` // seconds
+}
+
+type SetTransactionPINRequest struct {
+    PIN      string `json:"pin" validate:"required,numeric,min=4,max=6"`
+    Password string `json:"password" validate:"required"` // the account password, to confirm it is the owner
+}
+
+type RemoveTransactionPINRequest struct {
+    Password string `json:"password" validate:"required"`
+}
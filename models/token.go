@@ -0,0 +1,102 @@
+package models
+
+import (
+    "time"
+
+    "gorm.io/gorm"
+)
+
+// RefreshToken is a long-lived token that can be exchanged once for a new
+// access token. Only its SHA-256 hash is stored. Each refresh rotates it,
+// and every token descended from the same login shares a family.
+type RefreshToken struct {
+    ID              uint       `json:"id" gorm:"primaryKey"`
+    UserID          uint       `json:"user_id" gorm:"index;not null"`
+    TokenHash       string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+    FamilyID        string     `json:"family_id" gorm:"index;size:36;not null"`
+    AccessJTI       string     `json:"-" gorm:"index;size:36;not null"` // the access token issued alongside
+    AccessExpiresAt time.Time  `json:"-" gorm:"not null"`
+    ExpiresAt       time.Time  `json:"expires_at" gorm:"not null"`
+    RevokedAt       *time.Time `json:"revoked_at"`
+    ReplacedByID    *uint      `json:"replaced_by_id"`
+    MFA             bool       `json:"mfa" gorm:"not null;default:false"` // the login passed a second factor
+    IPAddress       string     `json:"ip_address"`
+    UserAgent       string     `json:"user_agent"`
+    CreatedAt       time.Time  `json:"created_at"`
+}
+
+// RevokedToken lists an access token that must be refused before it
+// expires. Entries are purged once the token would have expired anyway.
+type RevokedToken struct {
+    ID        uint      `json:"id" gorm:"primaryKey"`
+    JTI       string    `json:"jti" gorm:"uniqueIndex;size:36;not null"`
+    UserID    uint      `json:"user_id" gorm:"index;not null"`
+    Reason    string    `json:"reason" gorm:"size:100"`
+    ExpiresAt time.Time `json:"expires_at" gorm:"index;not null"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type RefreshRequest struct {
+    RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutRequest struct {
+    RefreshToken string `json:"refresh_token"` // defaults to the one issued with the current access token
+}
+
+type TokenResponse struct {
+    Token        string `json:"token"`
+    RefreshToken string `json:"refresh_token"`
+    ExpiresIn    int    `json:"expires_in"` // seconds until the access token expires
+}
+
+// RevokeTokens revokes the live refresh tokens matching the condition and
+// every access token issued alongside a matching refresh token that has not
+// expired yet, including those from refresh tokens already rotated or
+// revoked, and ends the sessions they belong to
+func RevokeTokens(tx *gorm.DB, reason string, query interface{}, args ...interface{}) error {
+    now := time.Now()
+    var tokens []RefreshToken
+    if err := tx.Where(query, args...).
+        Where("(access_expires_at > ? OR (revoked_at IS NULL AND expires_at > ?))", now, now).
+        Find(&tokens).Error; err != nil {
+        return err
+    }
+
+    var families []string
+    for _, token := range tokens {
+        families = append(families, token.FamilyID)
+        if token.AccessExpiresAt.After(now) {
+            var existing int64
+            if err := tx.Model(&RevokedToken{}).Where("jti = ?", token.AccessJTI).Count(&existing).Error; err != nil {
+                return err
+            }
+            if existing == 0 {
+                revoked := RevokedToken{
+                    JTI:       token.AccessJTI,
+                    UserID:    token.UserID,
+                    Reason:    reason,
+                    ExpiresAt: token.AccessExpiresAt,
+                }
+                if err := tx.Create(&revoked).Error; err != nil {
+                    return err
+                }
+            }
+        }
+        if token.RevokedAt == nil && token.ExpiresAt.After(now) {
+            if err := tx.Model(&RefreshToken{}).Where("id = ?", token.ID).Update("revoked_at", &now).Error; err != nil {
+                return err
+            }
+        }
+    }
+
+    if len(families) == 0 {
+        return nil
+    }
+    return tx.Model(&Session{}).Where("family_id IN ? AND revoked_at IS NULL", families).Update("revoked_at", &now).Error
+}
+
+// RevokeUserTokens signs a user out everywhere
+func RevokeUserTokens(tx *gorm.DB, userID uint, reason string) error {
+    return RevokeTokens(tx, reason, "user_id = ?", userID)
+}
@@ -3,42 +3,65 @@ package models
 import (
     "time"
 
+    "minibank-go/money"
+
     "gorm.io/gorm"
 )
 
 type Transaction struct {
-    ID            uint           `json:"id" gorm:"primaryKey"`
-    UserID        uint           `json:"user_id" gorm:"not null"`
-    User          User           `json:"user" gorm:"foreignKey:UserID"`
-    Type          string         `json:"type" gorm:"not null"` // deposit, withdraw, transfer_out, transfer_in
-    Amount        float64        `json:"amount" gorm:"not null"`
-    BalanceBefore float64        `json:"balance_before" gorm:"not null"`
-    BalanceAfter  float64        `json:"balance_after" gorm:"not null"`
-    ToUserID      *uint          `json:"to_user_id"`
-    ToUser        *User          `json:"to_user" gorm:"foreignKey:ToUserID"`
-    FromUserID    *uint          `json:"from_user_id"`
-    FromUser      *User          `json:"from_user" gorm:"foreignKey:FromUserID"`
-    Description   string         `json:"description"`
-    Reference     string         `json:"reference"`
-    Status        string         `json:"status" gorm:"default:completed"` // pending, completed, failed
-    IPAddress     string         `json:"ip_address"`
-    CreatedAt     time.Time      `json:"created_at"`
-    UpdatedAt     time.Time      `json:"updated_at"`
-    DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+    ID             uint           `json:"id" gorm:"primaryKey"`
+    AccountID      uint           `json:"account_id" gorm:"index;not null"`
+    Account        *Account       `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+    Type           string         `json:"type" gorm:"not null"` // deposit, withdraw, transfer_out, transfer_in, capture, reversal
+    Amount         money.Amount   `json:"amount" gorm:"not null"`
+    Currency       money.Currency `json:"currency" gorm:"size:3;not null;default:INR"`
+    BalanceBefore  money.Amount   `json:"balance_before" gorm:"not null"`
+    BalanceAfter   money.Amount   `json:"balance_after" gorm:"not null"`
+    ToAccountID    *uint          `json:"to_account_id"`
+    ToAccount      *Account       `json:"to_account,omitempty" gorm:"foreignKey:ToAccountID"`
+    FromAccountID  *uint          `json:"from_account_id"`
+    FromAccount    *Account       `json:"from_account,omitempty" gorm:"foreignKey:FromAccountID"`
+    Description    string         `json:"description"`
+    Reference      string         `json:"reference"`
+    JournalEntryID *uint          `json:"journal_entry_id"`
+    ReversalOfID   *uint          `json:"reversal_of_id" gorm:"uniqueIndex"` // set on the compensating leg of a reversal
+    Status         string         `json:"status" gorm:"default:completed"`   // pending, completed, failed, reversed
+    IPAddress      string         `json:"ip_address"`
+    CreatedAt      time.Time      `json:"created_at"`
+    UpdatedAt      time.Time      `json:"updated_at"`
+    DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 type DepositRequest struct {
-    Amount      float64 `json:"amount" validate:"required,min=1"`
-    Description string  `json:"description"`
+    AccountID   uint         `json:"account_id"`
+    Amount      money.Amount `json:"amount" validate:"required,money_min=1.00"`
+    Description string       `json:"description"`
 }
 
 type WithdrawRequest struct {
-    Amount      float64 `json:"amount" validate:"required,min=1"`
-    Description string  `json:"description"`
+    AccountID   uint         `json:"account_id"`
+    Amount      money.Amount `json:"amount" validate:"required,money_min=1.00"`
+    Description string       `json:"description"`
 }
 
 type TransferRequest struct {
-    ToUserID    uint    `json:"to_user_id" validate:"required,gt=0"`
-    Amount      float64 `json:"amount" validate:"required,min=1"`
-    Description string  `json:"description"`
-}
\ No newline at end of file
+    FromAccountID uint         `json:"from_account_id"`
+    ToAccountID   uint         `json:"to_account_id" validate:"required_without=To"` // one of your own accounts
+    To            string       `json:"to" validate:"required_without=ToAccountID"`   // account number, email or phone
+    Amount        money.Amount `json:"amount" validate:"required,money_min=1.00"`
+    Description   string       `json:"description"`
+}
+
+// PayeeLookupResponse lets a sender confirm who they are paying before the
+// transfer is made, without revealing the payee's full details
+type PayeeLookupResponse struct {
+    AccountNumber string `json:"account_number"`
+    AccountType   string `json:"account_type"`
+    Name          string `json:"name"`
+    Currency      string `json:"currency"`
+}
+
+type ReverseTransactionRequest struct {
+    Reference string `json:"reference" validate:"required"`
+    Reason    string `json:"reason" validate:"required,min=5,max=255"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+    "time"
+)
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when the
+// authenticator is lost. Only its SHA-256 hash is stored.
+type RecoveryCode struct {
+    ID        uint       `json:"id" gorm:"primaryKey"`
+    UserID    uint       `json:"user_id" gorm:"index;not null"`
+    CodeHash  string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+    UsedAt    *time.Time `json:"used_at"`
+    CreatedAt time.Time  `json:"created_at"`
+}
+
+type TwoFactorCodeRequest struct {
+    Code string `json:"code" validate:"required"` // TOTP or recovery code
+}
+
+// TwoFactorSetupRequest and TwoFactorConfirmRequest carry the account
+// password, so a stolen token cannot enroll an authenticator of its own
+type TwoFactorSetupRequest struct {
+    Password string `json:"password" validate:"required"`
+}
+
+type TwoFactorConfirmRequest struct {
+    Code     string `json:"code" validate:"required"`
+    Password string `json:"password" validate:"required"`
+}
+
+type VerifyMFARequest struct {
+    MFAToken   string `json:"mfa_token" validate:"required"`
+    Code       string `json:"code" validate:"required"` // TOTP or recovery code
+    DeviceName string `json:"device_name" validate:"max=100"`
+}
+
+// MFAChallengeResponse is returned by login in place of tokens when the user
+// has two-factor authentication enabled
+type MFAChallengeResponse struct {
+    MFARequired bool   `json:"mfa_required"`
+    MFAToken    string `json:"mfa_token"`
+    ExpiresIn   int    `json:"expires_in"` // seconds until the challenge expires
+}
+
+type TwoFactorSetupResponse struct {
+    Secret     string `json:"secret"`
+    OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+    RecoveryCodes []string `json:"recovery_codes"` // shown once; only hashes are kept
+}
@@ -1,26 +1,57 @@
 package models
 
 import (
+    "errors"
     "time"
 
     "gorm.io/gorm"
 )
 
 type User struct {
-    ID          uint           `json:"id" gorm:"primaryKey"`
-    Email       string         `json:"email" gorm:"uniqueIndex;not null"`
-    Phone       string         `json:"phone" gorm:"uniqueIndex;not null"`
-    Password    string         `json:"-" gorm:"not null"`
-    FirstName   string         `json:"first_name" gorm:"not null"`
-    LastName    string         `json:"last_name" gorm:"not null"`
-    Balance     float64        `json:"balance" gorm:"default:0"`
-    IsActive    bool           `json:"is_active" gorm:"default:true"`
-    IsAdmin     bool           `json:"is_admin" gorm:"default:false"`
-    KYCStatus   string         `json:"kyc_status" gorm:"default:pending"` // pending, verified, rejected
-    Verified    bool           `json:"verified" gorm:"default:false"`
-    CreatedAt   time.Time      `json:"created_at"`
-    UpdatedAt   time.Time      `json:"updated_at"`
-    DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+    ID                uint           `json:"id" gorm:"primaryKey"`
+    Email             string         `json:"email" gorm:"uniqueIndex;size:255;not null"`
+    Phone             string         `json:"phone" gorm:"uniqueIndex;size:32;not null"`
+    Password          string         `json:"-" gorm:"not null"`
+    FirstName         string         `json:"first_name" gorm:"not null"`
+    LastName          string         `json:"last_name" gorm:"not null"`
+    IsActive          bool           `json:"is_active" gorm:"default:true"`
+    Role              string         `json:"role" gorm:"size:20;index;not null;default:customer"`
+    KYCStatus         string         `json:"kyc_status" gorm:"default:pending"` // pending, verified, rejected
+    Verified          bool           `json:"verified" gorm:"default:false"`     // the email address has been confirmed
+    TwoFactorEnabled  bool           `json:"two_factor_enabled" gorm:"not null;default:false"`
+    TOTPSecret        string         `json:"-"`                                             // encrypted; set at enrollment, before it is confirmed
+    TOTPLastStep      int64          `json:"-" gorm:"not null;default:0"`                   // last time step accepted, so a code works once
+    ServiceAccount    bool           `json:"service_account" gorm:"not null;default:false"` // a machine client that signs in with API keys only
+    TransactionPIN    string         `json:"-"`                                             // bcrypt hash; empty until the user sets one
+    StepUpFailures    int            `json:"-" gorm:"not null;default:0"`                   // failed PINs and codes since the last success or lockout
+    StepUpLockedUntil *time.Time     `json:"-"`
+    CreatedAt         time.Time      `json:"created_at"`
+    UpdatedAt         time.Time      `json:"updated_at"`
+    DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
+    Accounts          []Account      `json:"accounts,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// HasTransactionPIN reports whether the user has set a transaction PIN
+func (u *User) HasTransactionPIN() bool {
+    return u.TransactionPIN != ""
+}
+
+// BeforeUpdate revokes every token the user holds when they are deactivated
+// or their role changes, so an old token cannot outlive the access it was
+// issued for. It sees changes made with Update or Updates on a loaded
+// user.
+func (u *User) BeforeUpdate(tx *gorm.DB) error {
+    if !tx.Statement.Changed("IsActive", "Role") {
+        return nil
+    }
+    if u.ID == 0 {
+        return errors.New("user access can only be changed on a loaded user")
+    }
+    return RevokeUserTokens(tx, u.ID, "access changed")
+}
+
+type UpdateUserAccessRequest struct {
+    IsActive *bool `json:"is_active"`
 }
 
 type RegisterRequest struct {
@@ -29,15 +60,16 @@ type RegisterRequest struct {
     Password  string `json:"password" validate:"required,min=8"`
     FirstName string `json:"first_name" validate:"required,min=2"`
     LastName  string `json:"last_name" validate:"required,min=2"`
-    AdminCode string `json:"admin_code,omitempty"` // Optional field for admin registration
 }
 
 type LoginRequest struct {
-    Email    string `json:"email" validate:"required,email"`
-    Password string `json:"password" validate:"required"`
+    Email      string `json:"email" validate:"required,email"`
+    Password   string `json:"password" validate:"required"`
+    DeviceName string `json:"device_name" validate:"max=100"` // defaults to one derived from the User-Agent
 }
 
 type LoginResponse struct {
-    Token string `json:"token"`
-    User  User   `json:"user"`
+    TokenResponse
+    User                   User `json:"user"`
+    TwoFactorSetupRequired bool `json:"two_factor_setup_required,omitempty"` // staff endpoints need 2FA, which this login lacks
 }
\ No newline at end of file
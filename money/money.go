@@ -0,0 +1,227 @@
+package money
+
+import (
+    "database/sql/driver"
+    "encoding/json"
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// Amount is an exact monetary value stored as an integer number of minor
+// units (paise for INR, cents for USD). All supported currencies use two
+// decimal places, so 1 major unit is always Scale minor units.
+type Amount int64
+
+// Scale is the number of minor units in one major unit
+const Scale = 100
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+const (
+    INR Currency = "INR"
+    USD Currency = "USD"
+    EUR Currency = "EUR"
+    GBP Currency = "GBP"
+
+    DefaultCurrency = INR
+)
+
+var supportedCurrencies = map[Currency]bool{
+    INR: true,
+    USD: true,
+    EUR: true,
+    GBP: true,
+}
+
+// Valid reports whether the currency is one we can hold balances in
+func (c Currency) Valid() bool {
+    return supportedCurrencies[c]
+}
+
+// FromMajor returns an amount of whole major units, e.g. FromMajor(10) is 10.00
+func FromMajor(units int64) Amount {
+    return Amount(units * Scale)
+}
+
+// FromMinor returns an amount of minor units, e.g. FromMinor(1050) is 10.50
+func FromMinor(units int64) Amount {
+    return Amount(units)
+}
+
+// Parse converts a decimal string such as "1050", "10.5" or "-0.25" into an
+// Amount. More than two decimal places is rejected rather than rounded.
+func Parse(s string) (Amount, error) {
+    s = strings.TrimSpace(s)
+    if s == "" {
+        return 0, fmt.Errorf("empty amount")
+    }
+
+    negative := false
+    switch s[0] {
+    case '-':
+        negative = true
+        s = s[1:]
+    case '+':
+        s = s[1:]
+    }
+
+    whole, frac, hasPoint := strings.Cut(s, ".")
+    if whole == "" && (!hasPoint || frac == "") {
+        return 0, fmt.Errorf("invalid amount %q", s)
+    }
+    if len(frac) > 2 {
+        return 0, fmt.Errorf("amount %q has more than 2 decimal places", s)
+    }
+    if hasPoint && frac == "" {
+        return 0, fmt.Errorf("invalid amount %q", s)
+    }
+
+    var units int64
+    if whole != "" {
+        if !isDigits(whole) {
+            return 0, fmt.Errorf("invalid amount %q", s)
+        }
+        w, err := strconv.ParseInt(whole, 10, 64)
+        if err != nil || w > math.MaxInt64/Scale {
+            return 0, fmt.Errorf("amount %q out of range", s)
+        }
+        units = w * Scale
+    }
+
+    if frac != "" {
+        if !isDigits(frac) {
+            return 0, fmt.Errorf("invalid amount %q", s)
+        }
+        for len(frac) < 2 {
+            frac += "0"
+        }
+        f, _ := strconv.ParseInt(frac, 10, 64)
+        if units > math.MaxInt64-f {
+            return 0, fmt.Errorf("amount %q out of range", s)
+        }
+        units += f
+    }
+
+    if negative {
+        units = -units
+    }
+    return Amount(units), nil
+}
+
+// MustParse is like Parse but panics on error. It is meant for constants and
+// configuration defaults.
+func MustParse(s string) Amount {
+    a, err := Parse(s)
+    if err != nil {
+        panic(err)
+    }
+    return a
+}
+
+func isDigits(s string) bool {
+    for _, r := range s {
+        if r < '0' || r > '9' {
+            return false
+        }
+    }
+    return s != ""
+}
+
+// Minor returns the amount as an integer number of minor units
+func (a Amount) Minor() int64 {
+    return int64(a)
+}
+
+func (a Amount) IsPositive() bool {
+    return a > 0
+}
+
+func (a Amount) IsNegative() bool {
+    return a < 0
+}
+
+func (a Amount) IsZero() bool {
+    return a == 0
+}
+
+// String formats the amount with exactly two decimal places, e.g. "10.50"
+func (a Amount) String() string {
+    units := int64(a)
+    sign := ""
+    if units < 0 {
+        sign = "-"
+    }
+    abs := uint64(units)
+    if units < 0 {
+        abs = uint64(-(units + 1)) + 1
+    }
+    return fmt.Sprintf("%s%d.%02d", sign, abs/Scale, abs%Scale)
+}
+
+// MarshalJSON encodes the amount as a decimal string so clients never see a
+// binary floating point value.
+func (a Amount) MarshalJSON() ([]byte, error) {
+    return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts either a decimal string ("10.50") or a bare JSON
+// number (10.50). Numbers are parsed from their literal text, never through
+// float64.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+    text := string(data)
+    if text == "null" {
+        return nil
+    }
+    if strings.HasPrefix(text, `"`) {
+        if err := json.Unmarshal(data, &text); err != nil {
+            return err
+        }
+    }
+    parsed, err := Parse(text)
+    if err != nil {
+        return err
+    }
+    *a = parsed
+    return nil
+}
+
+// Value stores the amount as an integer column of minor units
+func (a Amount) Value() (driver.Value, error) {
+    return int64(a), nil
+}
+
+// Scan reads an integer column of minor units. Float values are accepted
+// (and rounded) because SQLite keeps REAL affinity on legacy columns.
+func (a *Amount) Scan(src interface{}) error {
+    switch v := src.(type) {
+    case nil:
+        *a = 0
+    case int64:
+        *a = Amount(v)
+    case float64:
+        *a = Amount(math.Round(v))
+    case []byte:
+        return a.scanString(string(v))
+    case string:
+        return a.scanString(v)
+    default:
+        return fmt.Errorf("cannot scan %T into money.Amount", src)
+    }
+    return nil
+}
+
+func (a *Amount) scanString(s string) error {
+    if units, err := strconv.ParseInt(s, 10, 64); err == nil {
+        *a = Amount(units)
+        return nil
+    }
+    f, err := strconv.ParseFloat(s, 64)
+    if err != nil {
+        return fmt.Errorf("cannot scan %q into money.Amount", s)
+    }
+    *a = Amount(math.Round(f))
+    return nil
+}
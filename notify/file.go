@@ -0,0 +1,35 @@
+package notify
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "sync"
+    "time"
+)
+
+// FileNotifier appends each message to a file, or writes it to stdout when
+// Path is empty. It stands in for email during development and testing.
+type FileNotifier struct {
+    Path string
+    mtx  sync.Mutex
+}
+
+func (n *FileNotifier) Send(msg Message) error {
+    n.mtx.Lock()
+    defer n.mtx.Unlock()
+
+    var out io.Writer = os.Stdout
+    if n.Path != "" {
+        f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+        if err != nil {
+            return fmt.Errorf("failed to open notification file: %w", err)
+        }
+        defer f.Close()
+        out = f
+    }
+
+    _, err := fmt.Fprintf(out, "--- %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+        time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+    return err
+}
@@ -0,0 +1,33 @@
+package notify
+
+import (
+    "fmt"
+
+    "minibank-go/config"
+)
+
+// Message is an email to a single recipient
+type Message struct {
+    To      string
+    Subject string
+    Body    string
+}
+
+// Notifier delivers messages to users out of band
+type Notifier interface {
+    Send(msg Message) error
+}
+
+// New returns the notifier selected by NOTIFY_PROVIDER
+func New(cfg config.NotificationConfig) (Notifier, error) {
+    switch cfg.Provider {
+    case "", "file":
+        return &FileNotifier{Path: cfg.File}, nil
+    case "smtp":
+        if cfg.SMTPHost == "" {
+            return nil, fmt.Errorf("SMTP_HOST is required for the smtp notification provider")
+        }
+        return NewSMTPNotifier(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+    }
+    return nil, fmt.Errorf("unknown notification provider %q", cfg.Provider)
+}
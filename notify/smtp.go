@@ -0,0 +1,55 @@
+package notify
+
+import (
+    "fmt"
+    "mime"
+    "net"
+    "net/mail"
+    "net/smtp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// SMTPNotifier sends messages as plain-text email through an SMTP server.
+// The connection is upgraded with STARTTLS when the server offers it.
+type SMTPNotifier struct {
+    addr string
+    auth smtp.Auth
+    from *mail.Address
+}
+
+// NewSMTPNotifier returns a notifier for the server at host:port. Without a
+// username it sends unauthenticated.
+func NewSMTPNotifier(host string, port int, username, password, from string) (*SMTPNotifier, error) {
+    sender, err := mail.ParseAddress(from)
+    if err != nil {
+        return nil, fmt.Errorf("invalid NOTIFY_FROM %q: %w", from, err)
+    }
+
+    n := &SMTPNotifier{
+        addr: net.JoinHostPort(host, strconv.Itoa(port)),
+        from: sender,
+    }
+    if username != "" {
+        n.auth = smtp.PlainAuth("", username, password, host)
+    }
+    return n, nil
+}
+
+func (n *SMTPNotifier) Send(msg Message) error {
+    var b strings.Builder
+    fmt.Fprintf(&b, "From: %s\r\n", n.from.String())
+    fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+    fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+    fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    b.WriteString("MIME-Version: 1.0\r\n")
+    b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+    b.WriteString("\r\n")
+    b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+    if err := smtp.SendMail(n.addr, n.auth, n.from.Address, []string{msg.To}, []byte(b.String())); err != nil {
+        return fmt.Errorf("failed to send email to %s: %w", msg.To, err)
+    }
+    return nil
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+    "crypto/rand"
+    "fmt"
+    "math/big"
+    "strings"
+)
+
+// Account numbers follow the IBAN layout: a two letter bank code, two mod-97
+// check digits and a 12 digit account identifier, e.g. MB47123456789012.
+const (
+    accountNumberPrefix = "MB"
+    accountNumberDigits = 12
+    accountNumberLength = len(accountNumberPrefix) + 2 + accountNumberDigits
+)
+
+// GenerateAccountNumber returns a random account number with valid check digits
+func GenerateAccountNumber() (string, error) {
+    max := new(big.Int).Exp(big.NewInt(10), big.NewInt(accountNumberDigits), nil)
+    n, err := rand.Int(rand.Reader, max)
+    if err != nil {
+        return "", fmt.Errorf("failed to generate account number: %v", err)
+    }
+    bban := fmt.Sprintf("%0*d", accountNumberDigits, n)
+    return fmt.Sprintf("%s%02d%s", accountNumberPrefix, 98-mod97(bban+accountNumberPrefix+"00"), bban), nil
+}
+
+// NormalizeAccountNumber strips spaces and dashes and upper-cases the input,
+// so "mb47 1234 5678 9012" and "MB47123456789012" compare equal
+func NormalizeAccountNumber(number string) string {
+    number = strings.ToUpper(number)
+    return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// ValidateAccountNumber checks the layout and mod-97 check digits of a
+// normalized account number
+func ValidateAccountNumber(number string) bool {
+    if len(number) != accountNumberLength || !strings.HasPrefix(number, accountNumberPrefix) {
+        return false
+    }
+    for _, c := range number[len(accountNumberPrefix):] {
+        if c < '0' || c > '9' {
+            return false
+        }
+    }
+    // Move the country code and check digits to the end, as IBAN does
+    return mod97(number[4:]+number[:4]) == 1
+}
+
+// MaskAccountNumber hides all but the last four digits
+func MaskAccountNumber(number string) string {
+    if len(number) <= 4 {
+        return number
+    }
+    return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
+
+// mod97 computes the ISO 7064 MOD 97-10 remainder, expanding letters to
+// two digit numbers (A=10 ... Z=35)
+func mod97(s string) int {
+    remainder := 0
+    for _, c := range s {
+        switch {
+        case c >= '0' && c <= '9':
+            remainder = (remainder*10 + int(c-'0')) % 97
+        case c >= 'A' && c <= 'Z':
+            remainder = (remainder*100 + int(c-'A') + 10) % 97
+        }
+    }
+    return remainder
+}
@@ -3,27 +3,78 @@ package utils
 import (
     "crypto/aes"
     "crypto/cipher"
+    "crypto/hmac"
     "crypto/rand"
+    "crypto/sha256"
     "encoding/base64"
+    "encoding/binary"
+    "encoding/hex"
     "fmt"
-    "io"
     "log"
+    "strings"
+
+    "minibank-go/kms"
 
     "golang.org/x/crypto/bcrypt"
 )
 
-var encryptionKey []byte
+// Ciphertexts written by EncryptSensitiveData start with this version
+// header. Older ones have no header: they were written with AES-CFB under
+// ENCRYPTION_KEY and can still be read.
+const envelopeVersion = "v2:"
+
+var (
+    encryptionKey []byte  // the legacy key, for reading old ciphertexts
+    keyring       kms.KMS // wraps the data key of each ciphertext
+    blindIndexKey []byte  // keys the HMACs of BlindIndex
+)
 
-// InitializeEncryption sets up the encryption key
-func InitializeEncryption(key string) error {
+// InitializeEncryption sets up the legacy encryption key and the KMS that
+// wraps data keys
+func InitializeEncryption(key string, keys kms.KMS) error {
     if len(key) != 32 {
         return fmt.Errorf("encryption key must be exactly 32 characters, got %d", len(key))
     }
+    if keys == nil {
+        return fmt.Errorf("a KMS is required")
+    }
     encryptionKey = []byte(key)
+    keyring = keys
     log.Println("Encryption initialized successfully")
     return nil
 }
 
+// InitializeBlindIndex sets the key blind indexes are computed with.
+// Changing it means recomputing every stored index.
+func InitializeBlindIndex(key string) error {
+    if len(key) < 32 {
+        return fmt.Errorf("blind index key must be at least 32 characters, got %d", len(key))
+    }
+    blindIndexKey = []byte(key)
+    return nil
+}
+
+// BlindIndex returns a keyed hash of an identity document number, so records
+// holding the same number can be found without decrypting any of them. The
+// kind, such as pan, keeps equal numbers of different document types apart.
+// Case, spaces and dashes are ignored. An empty number has no index.
+func BlindIndex(kind, number string) (string, error) {
+    if blindIndexKey == nil {
+        return "", fmt.Errorf("blind index key not initialized")
+    }
+
+    number = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(number))
+    if number == "" {
+        return "", nil
+    }
+
+    mac := hmac.New(sha256.New, blindIndexKey)
+    mac.Write([]byte(kind))
+    mac.Write([]byte{0})
+    mac.Write([]byte(number))
+    return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
 func HashPassword(password string) (string, error) {
     bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
     return string(bytes), err
@@ -34,8 +85,17 @@ func CheckPasswordHash(password, hash string) bool {
     return err == nil
 }
 
+// EncryptSensitiveData encrypts data with AES-256-GCM under a fresh data key,
+// which is wrapped by the KMS and stored alongside. The result reads
+// v2:<base64> and the base64 part holds
+//
+//     key ID length (1 byte) | master key ID | wrapped key length (2 bytes) |
+//     wrapped data key | nonce (12 bytes) | ciphertext and tag
+//
+// Everything before the nonce is authenticated along with the data, so
+// tampering with any of it makes decryption fail.
 func EncryptSensitiveData(data string) (string, error) {
-    if encryptionKey == nil {
+    if keyring == nil {
         return "", fmt.Errorf("encryption key not initialized")
     }
 
@@ -43,33 +103,156 @@ func EncryptSensitiveData(data string) (string, error) {
         return "", nil // Return empty string for empty input
     }
 
-    block, err := aes.NewCipher(encryptionKey)
-    if err != nil {
-        return "", fmt.Errorf("failed to create cipher: %v", err)
+    dataKey := make([]byte, 32)
+    if _, err := rand.Read(dataKey); err != nil {
+        return "", fmt.Errorf("failed to generate data key: %v", err)
     }
+    defer clear(dataKey)
 
-    ciphertext := make([]byte, aes.BlockSize+len(data))
-    iv := ciphertext[:aes.BlockSize]
-    
-    if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-        return "", fmt.Errorf("failed to generate IV: %v", err)
+    keyID, wrapped, err := keyring.WrapKey(dataKey)
+    if err != nil {
+        return "", fmt.Errorf("failed to wrap data key: %v", err)
+    }
+    if len(keyID) > 255 || len(wrapped) > 65535 {
+        return "", fmt.Errorf("master key ID or wrapped data key is too long")
     }
 
-    stream := cipher.NewCFBEncrypter(block, iv)
-    stream.XORKeyStream(ciphertext[aes.BlockSize:], []byte(data))
+    header := make([]byte, 0, 3+len(keyID)+len(wrapped))
+    header = append(header, byte(len(keyID)))
+    header = append(header, keyID...)
+    header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+    header = append(header, wrapped...)
+
+    aead, err := newGCM(dataKey)
+    if err != nil {
+        return "", err
+    }
+    nonce := make([]byte, aead.NonceSize())
+    if _, err := rand.Read(nonce); err != nil {
+        return "", fmt.Errorf("failed to generate nonce: %v", err)
+    }
 
-    encoded := base64.URLEncoding.EncodeToString(ciphertext)
-    log.Printf("Successfully encrypted data of length %d", len(data))
-    return encoded, nil
+    sealed := append(header, nonce...)
+    sealed = aead.Seal(sealed, nonce, []byte(data), envelopeAAD(header))
+    return envelopeVersion + base64.RawURLEncoding.EncodeToString(sealed), nil
 }
 
+// DecryptSensitiveData decrypts data written by EncryptSensitiveData, or
+// written with AES-CFB before envelope encryption
 func DecryptSensitiveData(encryptedData string) (string, error) {
-    if encryptionKey == nil {
+    if encryptedData == "" {
+        return "", nil // Return empty string for empty input
+    }
+    if !strings.HasPrefix(encryptedData, envelopeVersion) {
+        return decryptLegacy(encryptedData)
+    }
+    if keyring == nil {
         return "", fmt.Errorf("encryption key not initialized")
     }
 
-    if encryptedData == "" {
-        return "", nil // Return empty string for empty input
+    env, err := parseEnvelope(encryptedData)
+    if err != nil {
+        return "", err
+    }
+    dataKey, err := keyring.UnwrapKey(env.keyID, env.wrapped)
+    if err != nil {
+        return "", err
+    }
+    defer clear(dataKey)
+
+    aead, err := newGCM(dataKey)
+    if err != nil {
+        return "", err
+    }
+    if len(env.sealed) < aead.NonceSize()+aead.Overhead() {
+        return "", fmt.Errorf("ciphertext too short")
+    }
+
+    nonce, ciphertext := env.sealed[:aead.NonceSize()], env.sealed[aead.NonceSize():]
+    plaintext, err := aead.Open(nil, nonce, ciphertext, envelopeAAD(env.header))
+    if err != nil {
+        return "", fmt.Errorf("failed to decrypt: ciphertext is corrupt or has been tampered with")
+    }
+    return string(plaintext), nil
+}
+
+// EncryptionKeyID returns the master key a ciphertext's data key is wrapped
+// with, or "" for data written before envelope encryption
+func EncryptionKeyID(encryptedData string) string {
+    if !strings.HasPrefix(encryptedData, envelopeVersion) {
+        return ""
+    }
+    env, err := parseEnvelope(encryptedData)
+    if err != nil {
+        return ""
+    }
+    return env.keyID
+}
+
+// CurrentEncryptionKeyID returns the master key new data is encrypted under
+func CurrentEncryptionKeyID() (string, error) {
+    if keyring == nil {
+        return "", fmt.Errorf("encryption key not initialized")
+    }
+    return keyring.CurrentKeyID()
+}
+
+// envelope is a v2 ciphertext taken apart
+type envelope struct {
+    keyID   string
+    wrapped []byte // the data key, wrapped by the KMS
+    header  []byte // everything before the nonce
+    sealed  []byte // the nonce, ciphertext and tag
+}
+
+func parseEnvelope(encryptedData string) (*envelope, error) {
+    data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(encryptedData, envelopeVersion))
+    if err != nil {
+        return nil, fmt.Errorf("failed to decode base64: %v", err)
+    }
+    if len(data) < 1 {
+        return nil, fmt.Errorf("ciphertext header is truncated")
+    }
+    n := int(data[0])
+    if len(data) < 1+n+2 {
+        return nil, fmt.Errorf("ciphertext header is truncated")
+    }
+    m := int(binary.BigEndian.Uint16(data[1+n:]))
+    end := 1 + n + 2 + m
+    if len(data) < end {
+        return nil, fmt.Errorf("ciphertext header is truncated")
+    }
+    return &envelope{
+        keyID:   string(data[1 : 1+n]),
+        wrapped: data[1+n+2 : end],
+        header:  data[:end],
+        sealed:  data[end:],
+    }, nil
+}
+
+// envelopeAAD binds the version and header to the ciphertext
+func envelopeAAD(header []byte) []byte {
+    return append([]byte(envelopeVersion), header...)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create cipher: %v", err)
+    }
+    aead, err := cipher.NewGCM(block)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create cipher: %v", err)
+    }
+    return aead, nil
+}
+
+// decryptLegacy reads data encrypted with AES-CFB under ENCRYPTION_KEY, as
+// it was before envelope encryption. CFB is not authenticated, so tampering
+// goes unnoticed; re-encrypting moves data off it.
+func decryptLegacy(encryptedData string) (string, error) {
+    if encryptionKey == nil {
+        return "", fmt.Errorf("encryption key not initialized")
     }
 
     ciphertext, err := base64.URLEncoding.DecodeString(encryptedData)
@@ -93,4 +276,4 @@ func DecryptSensitiveData(encryptedData string) (string, error) {
     stream.XORKeyStream(ciphertext, ciphertext)
 
     return string(ciphertext), nil
-}
\ No newline at end of file
+}
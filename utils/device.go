@@ -0,0 +1,56 @@
+package utils
+
+import (
+    "strings"
+)
+
+// Checked in order, since most browsers also name the ones they are based on
+var (
+    deviceBrowsers = []struct{ token, name string }{
+        {"Edg/", "Edge"},
+        {"OPR/", "Opera"},
+        {"Firefox/", "Firefox"},
+        {"Chrome/", "Chrome"},
+        {"Safari/", "Safari"},
+        {"PostmanRuntime/", "Postman"},
+        {"curl/", "curl"},
+    }
+    devicePlatforms = []struct{ token, name string }{
+        {"iPhone", "iOS"},
+        {"iPad", "iPadOS"},
+        {"Android", "Android"},
+        {"Windows", "Windows"},
+        {"Mac OS X", "macOS"},
+        {"CrOS", "ChromeOS"},
+        {"Linux", "Linux"},
+    }
+)
+
+// DeviceName describes the device behind a User-Agent header for people
+// looking at their sessions, such as "Firefox on Windows"
+func DeviceName(userAgent string) string {
+    var browser, platform string
+    for _, b := range deviceBrowsers {
+        if strings.Contains(userAgent, b.token) {
+            browser = b.name
+            break
+        }
+    }
+    for _, p := range devicePlatforms {
+        if strings.Contains(userAgent, p.token) {
+            platform = p.name
+            break
+        }
+    }
+
+    switch {
+    case browser != "" && platform != "":
+        return browser + " on " + platform
+    case browser != "":
+        return browser
+    case platform != "":
+        return platform
+    default:
+        return "Unknown device"
+    }
+}
@@ -1,24 +1,59 @@
 package utils
 
 import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
     "fmt"
     "log"
     "time"
 
     "github.com/golang-jwt/jwt/v5"
+    "github.com/google/uuid"
 )
 
-var jwtSecret []byte
+var (
+    jwtSecret []byte
+    jwtKeys   *JWTKeyStore // signs and verifies tokens in place of jwtSecret when set
+)
+
+// PurposeMFAChallenge marks a token that only proves the password step of a
+// two-step login. It is not accepted as an access token.
+const PurposeMFAChallenge = "mfa_challenge"
 
 type Claims struct {
-    UserID  uint   `json:"user_id"`
-    Email   string `json:"email"`
-    IsAdmin bool   `json:"is_admin"`
+    UserID      uint     `json:"user_id"`
+    Email       string   `json:"email"`
+    Role        string   `json:"role"`
+    Permissions []string `json:"permissions,omitempty"` // granted by the role when the token was issued
+    MFA         bool     `json:"mfa,omitempty"`         // a second factor was verified at login
+    SessionID   uint     `json:"sid,omitempty"`         // the login session the token belongs to
+    APIKeyID    uint     `json:"-"`                     // set when the request used an API key instead of a token
+    Purpose     string   `json:"purpose,omitempty"`     // empty for access tokens
     jwt.RegisteredClaims
 }
 
-// InitializeJWT sets up the JWT secret
-func InitializeJWT(secret string) error {
+// HasPermission reports whether the token grants a permission
+func (c *Claims) HasPermission(permission string) bool {
+    for _, p := range c.Permissions {
+        if p == permission {
+            return true
+        }
+    }
+    return false
+}
+
+// InitializeJWT sets up token signing. With a key store, tokens are signed
+// by its active key and verified against any of its keys, so verifiers only
+// need the public keys. Without one, the HS256 secret does both.
+func InitializeJWT(secret string, keys *JWTKeyStore) error {
+    if keys != nil {
+        jwtKeys = keys
+        log.Printf("JWT initialized with %d key(s), signing with %s", len(keys.Keys()), keys.signingKey().ID)
+        return nil
+    }
+
     if len(secret) < 32 {
         log.Printf("WARNING: JWT secret should be at least 32 characters for security, got %d", len(secret))
     }
@@ -27,39 +62,121 @@ func InitializeJWT(secret string) error {
     return nil
 }
 
-func GenerateToken(userID uint, email string, isAdmin bool) (string, error) {
-    if jwtSecret == nil {
-        return "", fmt.Errorf("JWT secret not initialized")
+// JWKS returns the public keys tokens can be verified with. It is empty when
+// tokens are signed with a shared secret.
+func JWKS() JWKSet {
+    set := JWKSet{Keys: []JWK{}}
+    if jwtKeys != nil {
+        for _, key := range jwtKeys.Keys() {
+            set.Keys = append(set.Keys, key.JWK())
+        }
     }
+    return set
+}
+
+// RunJWTKeyReload reloads the key store every interval, so keys generated,
+// activated or retired on disk take effect. It never returns.
+func RunJWTKeyReload(interval time.Duration) {
+    for {
+        time.Sleep(interval)
+        if err := jwtKeys.Reload(); err != nil {
+            log.Printf("JWT key reload failed, keeping the keys already loaded: %v", err)
+        }
+    }
+}
 
-    claims := Claims{
+// GenerateToken issues an access token for a session, valid for ttl. Each
+// token carries a unique ID (jti) so it can be revoked before it expires.
+func GenerateToken(userID uint, email, role string, permissions []string, mfa bool, sessionID uint, ttl time.Duration) (string, *Claims, error) {
+    return signToken(&Claims{
+        UserID:      userID,
+        Email:       email,
+        Role:        role,
+        Permissions: permissions,
+        MFA:         mfa,
+        SessionID:   sessionID,
+    }, ttl)
+}
+
+// GenerateChallengeToken issues the short-lived token a user presents with
+// their second factor to finish logging in
+func GenerateChallengeToken(userID uint, email string, ttl time.Duration) (string, *Claims, error) {
+    return signToken(&Claims{
         UserID:  userID,
         Email:   email,
-        IsAdmin: isAdmin,
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
-            Issuer:    "minibank-go",
-        },
+        Purpose: PurposeMFAChallenge,
+    }, ttl)
+}
+
+func signToken(claims *Claims, ttl time.Duration) (string, *Claims, error) {
+    if jwtSecret == nil && jwtKeys == nil {
+        return "", nil, fmt.Errorf("JWT secret not initialized")
     }
 
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    signedToken, err := token.SignedString(jwtSecret)
+    now := time.Now()
+    claims.RegisteredClaims = jwt.RegisteredClaims{
+        ID:        uuid.NewString(),
+        ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+        IssuedAt:  jwt.NewNumericDate(now),
+        Issuer:    "minibank-go",
+    }
+
+    var signedToken string
+    var err error
+    if jwtKeys != nil {
+        key := jwtKeys.signingKey()
+        token := jwt.NewWithClaims(key.method(), claims)
+        token.Header["kid"] = key.ID
+        signedToken, err = token.SignedString(key.private)
+    } else {
+        signedToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+    }
     if err != nil {
-        return "", fmt.Errorf("failed to sign token: %v", err)
+        return "", nil, fmt.Errorf("failed to sign token: %v", err)
     }
 
-    log.Printf("Generated JWT token for user %d (%s)", userID, email)
-    return signedToken, nil
+    log.Printf("Generated JWT token for user %d (%s)", claims.UserID, claims.Email)
+    return signedToken, claims, nil
 }
 
+// GenerateOpaqueToken returns a random URL-safe token, such as a refresh
+// token or an invitation token
+func GenerateOpaqueToken() (string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", fmt.Errorf("failed to generate token: %v", err)
+    }
+    return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex SHA-256 of a token, which is what gets stored
+func HashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+// ValidateToken checks a token's signature and expiry and returns its claims.
+// With a key store, the token must name one of its keys in its kid header
+// and be signed with that key's algorithm.
 func ValidateToken(tokenString string) (*Claims, error) {
-    if jwtSecret == nil {
+    if jwtSecret == nil && jwtKeys == nil {
         return nil, fmt.Errorf("JWT secret not initialized")
     }
 
     claims := &Claims{}
     token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+        if jwtKeys != nil {
+            kid, _ := token.Header["kid"].(string)
+            key := jwtKeys.key(kid)
+            if key == nil {
+                return nil, fmt.Errorf("unknown signing key %q", kid)
+            }
+            if token.Method.Alg() != key.Algorithm {
+                return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+            }
+            return key.public, nil
+        }
+
         // Verify signing method
         if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
             return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
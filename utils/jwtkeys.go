@@ -0,0 +1,351 @@
+package utils
+
+import (
+    "crypto"
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/pem"
+    "fmt"
+    "math/big"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+// JWT signing keys live in a directory of PEM files named after their key
+// ID, such as 20261016-3f9a1c.pem. Every key there verifies tokens, and the
+// key named in the "active" file signs new ones. A file holding only a
+// public key verifies but never signs.
+const activeKeyFile = "active"
+
+// Signing algorithms for JWT keys
+const (
+    AlgorithmRS256 = "RS256"
+    AlgorithmEdDSA = "EdDSA"
+)
+
+// JWTKey is one signing key from the key store
+type JWTKey struct {
+    ID        string
+    Algorithm string
+    CreatedAt time.Time
+    Active    bool
+    private   crypto.Signer // nil for a verify-only key
+    public    crypto.PublicKey
+}
+
+// CanSign reports whether the private half of the key is available
+func (k *JWTKey) CanSign() bool {
+    return k.private != nil
+}
+
+func (k *JWTKey) method() jwt.SigningMethod {
+    if k.Algorithm == AlgorithmEdDSA {
+        return jwt.SigningMethodEdDSA
+    }
+    return jwt.SigningMethodRS256
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517)
+type JWK struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid"`
+    Use string `json:"use"`
+    Alg string `json:"alg"`
+    N   string `json:"n,omitempty"`   // RSA modulus
+    E   string `json:"e,omitempty"`   // RSA exponent
+    Crv string `json:"crv,omitempty"` // Ed25519 curve
+    X   string `json:"x,omitempty"`   // Ed25519 public key
+}
+
+type JWKSet struct {
+    Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public half of the key for publishing
+func (k *JWTKey) JWK() JWK {
+    jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+    switch public := k.public.(type) {
+    case *rsa.PublicKey:
+        jwk.Kty = "RSA"
+        jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+        jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+    case ed25519.PublicKey:
+        jwk.Kty = "OKP"
+        jwk.Crv = "Ed25519"
+        jwk.X = base64.RawURLEncoding.EncodeToString(public)
+    }
+    return jwk
+}
+
+// JWTKeyStore holds the keys loaded from a key directory. It can be reloaded
+// while in use, so keys rotated on disk take effect without a restart.
+type JWTKeyStore struct {
+    dir    string
+    mtx    sync.RWMutex
+    keys   map[string]*JWTKey
+    active *JWTKey
+}
+
+// LoadJWTKeys loads the key store in dir, which must have an active key
+func LoadJWTKeys(dir string) (*JWTKeyStore, error) {
+    s := &JWTKeyStore{dir: dir}
+    if err := s.Reload(); err != nil {
+        return nil, err
+    }
+    return s, nil
+}
+
+// Reload reads the key directory again. On error the keys already loaded
+// stay in use.
+func (s *JWTKeyStore) Reload() error {
+    keys, err := ListJWTKeys(s.dir)
+    if err != nil {
+        return err
+    }
+
+    byID := make(map[string]*JWTKey, len(keys))
+    var active *JWTKey
+    for _, key := range keys {
+        byID[key.ID] = key
+        if key.Active {
+            active = key
+        }
+    }
+    if active == nil {
+        return fmt.Errorf("no active signing key in %s; run `minibank keys rotate` to create one", s.dir)
+    }
+
+    s.mtx.Lock()
+    s.keys, s.active = byID, active
+    s.mtx.Unlock()
+    return nil
+}
+
+// Keys returns every key in the store, oldest first
+func (s *JWTKeyStore) Keys() []*JWTKey {
+    s.mtx.RLock()
+    defer s.mtx.RUnlock()
+
+    keys := make([]*JWTKey, 0, len(s.keys))
+    for _, key := range s.keys {
+        keys = append(keys, key)
+    }
+    sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+    return keys
+}
+
+func (s *JWTKeyStore) key(id string) *JWTKey {
+    s.mtx.RLock()
+    defer s.mtx.RUnlock()
+    return s.keys[id]
+}
+
+func (s *JWTKeyStore) signingKey() *JWTKey {
+    s.mtx.RLock()
+    defer s.mtx.RUnlock()
+    return s.active
+}
+
+// ListJWTKeys reads every key in dir, oldest first. Unlike LoadJWTKeys it
+// does not need an active key.
+func ListJWTKeys(dir string) ([]*JWTKey, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read JWT key directory: %v", err)
+    }
+
+    activeID, err := readActiveKeyID(dir)
+    if err != nil {
+        return nil, err
+    }
+
+    var keys []*JWTKey
+    for _, entry := range entries {
+        if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+            continue
+        }
+        key, err := readJWTKey(filepath.Join(dir, entry.Name()))
+        if err != nil {
+            return nil, err
+        }
+        key.Active = key.ID == activeID
+        keys = append(keys, key)
+    }
+    sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+    if activeID != "" {
+        found := false
+        for _, key := range keys {
+            if key.Active {
+                if !key.CanSign() {
+                    return nil, fmt.Errorf("active JWT key %s has no private key", activeID)
+                }
+                found = true
+            }
+        }
+        if !found {
+            return nil, fmt.Errorf("active JWT key %s not found in %s", activeID, dir)
+        }
+    }
+    return keys, nil
+}
+
+func readActiveKeyID(dir string) (string, error) {
+    data, err := os.ReadFile(filepath.Join(dir, activeKeyFile))
+    if os.IsNotExist(err) {
+        return "", nil
+    }
+    if err != nil {
+        return "", fmt.Errorf("failed to read active JWT key: %v", err)
+    }
+    return strings.TrimSpace(string(data)), nil
+}
+
+// readJWTKey parses a PEM file holding a private key (PKCS #8, or PKCS #1
+// for RSA) or a public key
+func readJWTKey(path string) (*JWTKey, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read JWT key: %v", err)
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read JWT key: %v", err)
+    }
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, fmt.Errorf("%s is not a PEM file", path)
+    }
+
+    key := &JWTKey{
+        ID:        strings.TrimSuffix(filepath.Base(path), ".pem"),
+        CreatedAt: info.ModTime(),
+    }
+    var parsed interface{}
+    switch block.Type {
+    case "PRIVATE KEY":
+        parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+    case "RSA PRIVATE KEY":
+        parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+    case "PUBLIC KEY":
+        parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+    default:
+        return nil, fmt.Errorf("%s holds an unsupported %s", path, block.Type)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+    }
+
+    if signer, ok := parsed.(crypto.Signer); ok {
+        key.private = signer
+        parsed = signer.Public()
+    }
+    switch public := parsed.(type) {
+    case *rsa.PublicKey:
+        if public.N.BitLen() < 2048 {
+            return nil, fmt.Errorf("%s: RSA keys must be at least 2048 bits", path)
+        }
+        key.Algorithm, key.public = AlgorithmRS256, public
+    case ed25519.PublicKey:
+        key.Algorithm, key.public = AlgorithmEdDSA, public
+    default:
+        return nil, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", path)
+    }
+    return key, nil
+}
+
+// GenerateJWTKey creates a new key in dir. It verifies tokens at once but
+// only signs them once activated, so it can be published before use.
+func GenerateJWTKey(dir, algorithm string) (*JWTKey, error) {
+    var private crypto.Signer
+    var err error
+    switch algorithm {
+    case AlgorithmRS256:
+        private, err = rsa.GenerateKey(rand.Reader, 3072)
+    case AlgorithmEdDSA:
+        _, private, err = ed25519.GenerateKey(rand.Reader)
+    default:
+        return nil, fmt.Errorf("unsupported algorithm %q; use %s or %s", algorithm, AlgorithmRS256, AlgorithmEdDSA)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to generate key: %v", err)
+    }
+    der, err := x509.MarshalPKCS8PrivateKey(private)
+    if err != nil {
+        return nil, fmt.Errorf("failed to encode key: %v", err)
+    }
+
+    suffix := make([]byte, 3)
+    if _, err := rand.Read(suffix); err != nil {
+        return nil, fmt.Errorf("failed to generate key ID: %v", err)
+    }
+    id := time.Now().UTC().Format("20060102") + "-" + hex.EncodeToString(suffix)
+
+    if err := os.MkdirAll(dir, 0700); err != nil {
+        return nil, fmt.Errorf("failed to create JWT key directory: %v", err)
+    }
+    f, err := os.OpenFile(filepath.Join(dir, id+".pem"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+    if err != nil {
+        return nil, fmt.Errorf("failed to write key: %v", err)
+    }
+    defer f.Close()
+    if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+        return nil, fmt.Errorf("failed to write key: %v", err)
+    }
+
+    return &JWTKey{
+        ID:        id,
+        Algorithm: algorithm,
+        CreatedAt: time.Now(),
+        private:   private,
+        public:    private.Public(),
+    }, nil
+}
+
+// ActivateJWTKey makes the key with the given ID sign new tokens
+func ActivateJWTKey(dir, id string) error {
+    key, err := readJWTKey(filepath.Join(dir, filepath.Base(id)+".pem"))
+    if err != nil {
+        return err
+    }
+    if !key.CanSign() {
+        return fmt.Errorf("JWT key %s has no private key", id)
+    }
+
+    // Replace the file in one step, so a server reloading meanwhile never
+    // sees it half written
+    tmp := filepath.Join(dir, activeKeyFile+".tmp")
+    if err := os.WriteFile(tmp, []byte(key.ID+"\n"), 0600); err != nil {
+        return fmt.Errorf("failed to activate key: %v", err)
+    }
+    if err := os.Rename(tmp, filepath.Join(dir, activeKeyFile)); err != nil {
+        return fmt.Errorf("failed to activate key: %v", err)
+    }
+    return nil
+}
+
+// RetireJWTKey deletes a key, so tokens it signed are no longer accepted.
+// The active key cannot be retired.
+func RetireJWTKey(dir, id string) error {
+    activeID, err := readActiveKeyID(dir)
+    if err != nil {
+        return err
+    }
+    if id == activeID {
+        return fmt.Errorf("JWT key %s is active; activate another key first", id)
+    }
+    if err := os.Remove(filepath.Join(dir, filepath.Base(id)+".pem")); err != nil {
+        return fmt.Errorf("failed to retire key: %v", err)
+    }
+    return nil
+}
@@ -6,6 +6,8 @@ import (
     "strings"
     "time"
 
+    "minibank-go/money"
+
     "github.com/go-playground/validator/v10"
 )
 
@@ -13,6 +15,21 @@ var validate *validator.Validate
 
 func init() {
     validate = validator.New()
+    validate.RegisterValidation("money_min", validateMoneyMin)
+}
+
+// validateMoneyMin checks a money.Amount field against a decimal minimum,
+// e.g. `validate:"money_min=1.00"`
+func validateMoneyMin(fl validator.FieldLevel) bool {
+    amount, ok := fl.Field().Interface().(money.Amount)
+    if !ok {
+        return false
+    }
+    min, err := money.Parse(fl.Param())
+    if err != nil {
+        return false
+    }
+    return amount >= min
 }
 
 func ValidateStruct(s interface{}) error {
@@ -57,12 +74,16 @@ func FormatValidationError(err error) map[string]string {
             switch fieldError.Tag() {
             case "required":
                 errors[field] = fmt.Sprintf("%s is required", field)
+            case "required_without":
+                errors[field] = fmt.Sprintf("%s is required when %s is not provided", field, strings.ToLower(fieldError.Param()))
             case "email":
                 errors[field] = "Invalid email format"
             case "min":
                 errors[field] = fmt.Sprintf("%s must be at least %s characters", field, fieldError.Param())
             case "max":
                 errors[field] = fmt.Sprintf("%s must be at most %s characters", field, fieldError.Param())
+            case "money_min":
+                errors[field] = fmt.Sprintf("%s must be at least %s", field, fieldError.Param())
             case "len":
                 errors[field] = fmt.Sprintf("%s must be exactly %s characters", field, fieldError.Param())
             default: